@@ -0,0 +1,160 @@
+// Package auth issues and verifies the JWTs handed out by AdminLogin and
+// enforces the role each route allows.
+package auth
+
+import (
+	"backend/config"
+	"backend/store"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Admin roles.
+const (
+	RoleOwner    = "owner"    // manages admins and prices
+	RoleManager  = "manager"  // edits customers and orders
+	RoleDelivery = "delivery" // reads daily summaries of assigned apartments
+)
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	return role == RoleOwner || role == RoleManager || role == RoleDelivery
+}
+
+// Claims carried by every admin token.
+type Claims struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// AdminID returns the admin the token was issued to.
+func (c *Claims) AdminID() string {
+	return c.Subject
+}
+
+type contextKey struct{}
+
+// ErrMissingToken is returned when the request has no bearer token.
+var ErrMissingToken = errors.New("auth: missing bearer token")
+
+// IssueToken signs a token for the admin, valid for config.TokenLifetime.
+func IssueToken(adminID, username, role string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Username: username,
+		Role:     role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   adminID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.TokenLifetime)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(config.JWTSecret)
+}
+
+// ParseRequest validates the "Authorization: Bearer <token>" header.
+func ParseRequest(r *http.Request) (*Claims, error) {
+	header := r.Header.Get("Authorization")
+	raw, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || raw == "" {
+		return nil, ErrMissingToken
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		return config.JWTSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Middleware rejects requests without a valid token or from a disabled
+// account, and stores the claims in the request context for the handlers.
+// The role is re-read from admins so changes apply immediately.
+func Middleware(admins store.AdminStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := Authenticate(r, admins)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="dairyadmin"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, claims)))
+		})
+	}
+}
+
+// Authenticate validates the bearer token and checks the account is still
+// active, loading its current role.
+func Authenticate(r *http.Request, admins store.AdminStore) (*Claims, error) {
+	claims, err := ParseRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := refresh(claims, admins); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ErrDisabled is returned for tokens of disabled or deleted accounts.
+var ErrDisabled = errors.New("auth: account disabled")
+
+func refresh(claims *Claims, admins store.AdminStore) error {
+	role, active, err := admins.AdminRole(claims.AdminID())
+	if err == store.ErrNotFound || (err == nil && !active) {
+		return ErrDisabled
+	}
+	if err != nil {
+		return err
+	}
+	claims.Role = role
+	return nil
+}
+
+// Allow wraps a handler so only the given roles may call it. It must run
+// behind Middleware.
+func Allow(h http.HandlerFunc, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := FromContext(r.Context())
+		if claims == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		for _, role := range roles {
+			if claims.Role == role {
+				h(w, r)
+				return
+			}
+		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
+
+// CanAccessApartment reports whether the admin may see an apartment's data.
+// Owners and managers see every apartment, delivery staff only their own.
+func CanAccessApartment(admins store.AdminStore, claims *Claims, apartmentID string) (bool, error) {
+	if claims == nil {
+		return false, nil
+	}
+	if claims.Role != RoleDelivery {
+		return true, nil
+	}
+	return admins.AdminCanAccess(claims.AdminID(), apartmentID)
+}
+
+// FromContext returns the claims stored by Middleware, or nil.
+func FromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(contextKey{}).(*Claims)
+	return claims
+}
@@ -0,0 +1,83 @@
+package auth_test
+
+import (
+	"backend/auth"
+	"backend/config"
+	"backend/store"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthenticate(t *testing.T) {
+	config.JWTSecret, config.TokenLifetime = []byte("test-secret"), time.Hour
+	m := store.NewMemory()
+	m.AddAdmin("owner-1", auth.RoleOwner, true)
+	m.AddAdmin("demoted-1", auth.RoleDelivery, true)
+	m.AddAdmin("disabled-1", auth.RoleManager, false)
+	admins := m.Stores().Admins
+
+	tests := []struct {
+		name     string
+		adminID  string
+		role     string // role in the token
+		wantRole string // "" when the token must be refused
+	}{
+		{"active account", "owner-1", auth.RoleOwner, auth.RoleOwner},
+		{"role changed since login", "demoted-1", auth.RoleOwner, auth.RoleDelivery},
+		{"disabled account", "disabled-1", auth.RoleManager, ""},
+		{"deleted account", "gone-1", auth.RoleOwner, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := auth.IssueToken(tt.adminID, tt.adminID, tt.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", "/customers", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+
+			claims, err := auth.Authenticate(r, admins)
+			if tt.wantRole == "" {
+				if err == nil {
+					t.Fatalf("token of %s accepted", tt.adminID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Role != tt.wantRole {
+				t.Errorf("role = %s, want %s", claims.Role, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestCanAccessApartment(t *testing.T) {
+	m := store.NewMemory()
+	m.AssignApartment("driver-1", "apt-1")
+	admins := m.Stores().Admins
+
+	tests := []struct {
+		role        string
+		apartmentID string
+		want        bool
+	}{
+		{auth.RoleOwner, "apt-2", true},
+		{auth.RoleManager, "apt-2", true},
+		{auth.RoleDelivery, "apt-1", true},
+		{auth.RoleDelivery, "apt-2", false},
+	}
+	for _, tt := range tests {
+		claims := &auth.Claims{Role: tt.role}
+		claims.Subject = "driver-1"
+		got, err := auth.CanAccessApartment(admins, claims, tt.apartmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s on %s = %v, want %v", tt.role, tt.apartmentID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package billing prices what the orders package says was delivered. The
+// live monthly bill, stored invoices and bulk bill runs all go through it,
+// so they always agree.
+package billing
+
+import (
+	"backend/civil"
+	"backend/orders"
+	"backend/pricing"
+	"backend/store"
+	"time"
+)
+
+// Item is one priced product on one day.
+type Item struct {
+	ProductID    string  `json:"product_id"`
+	Quantity     float64 `json:"quantity"`
+	PricePerUnit float64 `json:"price_per_unit"`
+	TotalPrice   float64 `json:"total_price"`
+}
+
+// Day is the bill of a single day.
+type Day struct {
+	Date     civil.Date `json:"date"`
+	DayBill  float64    `json:"daybill"`
+	Products []Item     `json:"products"`
+}
+
+// Bill is a customer's bill for one month.
+type Bill struct {
+	UserID string
+	Year   int
+	Month  time.Month
+	Days   []Day
+	Total  float64
+}
+
+// DaysDelivered counts the days with at least one product.
+func (b *Bill) DaysDelivered() int {
+	n := 0
+	for _, d := range b.Days {
+		if len(d.Products) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// MonthRange returns the first and last day of a month.
+func MonthRange(year int, month time.Month) (civil.Date, civil.Date) {
+	start := civil.Date{Year: year, Month: month, Day: 1}
+	return start, start.AddMonths(1).AddDays(-1)
+}
+
+// Compute builds a customer's bill for a month.
+func Compute(st store.Stores, userID string, year int, month time.Month) (*Bill, error) {
+	start, end := MonthRange(year, month)
+	plan, err := orders.Load(st, []string{userID}, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Has(userID) {
+		return nil, orders.ErrUnknownUser
+	}
+	prices, err := pricing.Load(st.Prices)
+	if err != nil {
+		return nil, err
+	}
+	return FromPlan(plan, prices, userID, year, month), nil
+}
+
+// FromPlan prices one customer's month from an already loaded plan, which
+// must cover the whole month. Use it to bill many customers at once.
+func FromPlan(plan *orders.Plan, prices *pricing.Book, userID string, year int, month time.Month) *Bill {
+	start, end := MonthRange(year, month)
+	bill := &Bill{UserID: userID, Year: year, Month: month, Days: make([]Day, 0, end.Day)}
+
+	for date := start; !date.After(end); date = date.AddDays(1) {
+		lines, _ := plan.Resolve(userID, date)
+		day := Day{Date: date, Products: make([]Item, 0, len(lines))}
+		for _, line := range lines {
+			price := prices.PriceFor(line.ProductID, userID, plan.ApartmentOn(userID, date), date)
+			item := Item{
+				ProductID:    line.ProductID,
+				Quantity:     line.Quantity,
+				PricePerUnit: price,
+				TotalPrice:   price * line.Quantity,
+			}
+			day.DayBill += item.TotalPrice
+			day.Products = append(day.Products, item)
+		}
+		bill.Days = append(bill.Days, day)
+		bill.Total += day.DayBill
+	}
+	return bill
+}
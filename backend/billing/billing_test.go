@@ -0,0 +1,121 @@
+package billing_test
+
+import (
+	"backend/billing"
+	"backend/civil"
+	"backend/models"
+	"backend/store"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name      string
+		changes   []models.ProductPriceHistory
+		wantFirst float64 // price per unit on the 1st
+		wantLast  float64 // price per unit on the 30th
+		wantTotal float64
+	}{
+		{
+			name:      "no history uses the current price",
+			wantFirst: 30, wantLast: 30,
+			wantTotal: 30 * 30,
+		},
+		{
+			name: "change during the month",
+			changes: []models.ProductPriceHistory{
+				{OldPrice: 28, NewPrice: 30, EffectiveFrom: civil.Date{Year: 2025, Month: 4, Day: 16}},
+			},
+			wantFirst: 28, wantLast: 30,
+			wantTotal: 15*28 + 15*30,
+		},
+		{
+			name: "latest effective change wins",
+			changes: []models.ProductPriceHistory{
+				{OldPrice: 24, NewPrice: 26, EffectiveFrom: civil.Date{Year: 2025, Month: 1, Day: 1}},
+				{OldPrice: 26, NewPrice: 27, EffectiveFrom: civil.Date{Year: 2025, Month: 3, Day: 1}},
+				{OldPrice: 27, NewPrice: 30, EffectiveFrom: civil.Date{Year: 2025, Month: 6, Day: 1}},
+			},
+			wantFirst: 27, wantLast: 27,
+			wantTotal: 30 * 27,
+		},
+		{
+			name: "days before every change use the first old price",
+			changes: []models.ProductPriceHistory{
+				{OldPrice: 25, NewPrice: 30, EffectiveFrom: civil.Date{Year: 2025, Month: 5, Day: 1}},
+			},
+			wantFirst: 25, wantLast: 25,
+			wantTotal: 30 * 25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory().Stores()
+			aptID, _ := st.Apartments.CreateApartment("Lake View")
+			userID, _ := st.Customers.CreateCustomer(models.User{Name: "Asha", ApartmentID: aptID})
+			productID, _ := st.Products.CreateProduct(models.Product{ProductName: "Milk", Unit: "L", CurrentPrice: 30})
+			for _, h := range tt.changes {
+				h.ProductID = productID
+				if err := st.Prices.AddPriceChange(h); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := st.Orders.ReplaceDefaults(userID, store.ModeNormal, civil.Date{}, civil.Date{}, []store.DefaultItem{{ProductID: productID, Quantity: 1}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bill, err := billing.Compute(st, userID, 2025, time.April)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bill.Days) != 30 {
+				t.Fatalf("got %d days, want 30", len(bill.Days))
+			}
+			if got := bill.Days[0].Products[0].PricePerUnit; got != tt.wantFirst {
+				t.Errorf("price on the 1st = %v, want %v", got, tt.wantFirst)
+			}
+			if got := bill.Days[29].Products[0].PricePerUnit; got != tt.wantLast {
+				t.Errorf("price on the 30th = %v, want %v", got, tt.wantLast)
+			}
+			if math.Abs(bill.Total-tt.wantTotal) > 1e-9 {
+				t.Errorf("total = %v, want %v", bill.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestComputeSkipsPausedDays(t *testing.T) {
+	st := store.NewMemory().Stores()
+	aptID, _ := st.Apartments.CreateApartment("Lake View")
+	userID, _ := st.Customers.CreateCustomer(models.User{Name: "Asha", ApartmentID: aptID})
+	productID, _ := st.Products.CreateProduct(models.Product{ProductName: "Milk", Unit: "L", CurrentPrice: 30})
+	st.Orders.ReplaceDefaults(userID, store.ModeNormal, civil.Date{}, civil.Date{}, []store.DefaultItem{{ProductID: productID, Quantity: 2}})
+	st.Orders.AddModifications([]store.Modification{{
+		UserID:    userID,
+		ProductID: productID,
+		StartDate: civil.Date{Year: 2025, Month: time.April, Day: 11},
+		EndDate:   civil.Date{Year: 2025, Month: time.April, Day: 20},
+	}})
+
+	bill, err := billing.Compute(st, userID, 2025, time.April)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bill.DaysDelivered(); got != 20 {
+		t.Errorf("DaysDelivered = %d, want 20", got)
+	}
+	if bill.Total != 20*2*30 {
+		t.Errorf("total = %v, want %v", bill.Total, 20*2*30)
+	}
+}
+
+func TestComputeUnknownUser(t *testing.T) {
+	st := store.NewMemory().Stores()
+	if _, err := billing.Compute(st, "nobody", 2025, time.April); err == nil {
+		t.Fatal("expected an error for an unknown customer")
+	}
+}
@@ -0,0 +1,169 @@
+// Package civil holds calendar dates without a time of day or a time zone.
+//
+// Delivery days, price changes, payments and alternating anchors are all
+// plain dates. Keeping them as time.Time invites off-by-one errors: a
+// midnight in Asia/Kolkata is the previous day in UTC, and dividing a
+// Sub().Hours() by 24 goes wrong across a DST change or a time zone
+// conversion. A Date is just a year, month and day; "today" is only ever
+// asked of a time zone explicitly.
+package civil
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Layout is the format dates are exchanged in with the frontend and the DB.
+const Layout = "2006-01-02"
+
+// Date is a calendar date. The zero value means "no date".
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// Of returns the date of t in t's own location.
+func Of(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{y, m, d}
+}
+
+// Today returns the current date in loc.
+func Today(loc *time.Location) Date {
+	return Of(time.Now().In(loc))
+}
+
+// Parse reads a YYYY-MM-DD date. The date of a longer timestamp, such as the
+// "2025-03-10T00:00:00Z" a DATE column scanned into a string comes back as,
+// is taken as written, without converting it to another time zone.
+func Parse(s string) (Date, error) {
+	if len(s) > len(Layout) && s[len(Layout)] == 'T' {
+		s = s[:len(Layout)]
+	}
+	t, err := time.Parse(Layout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("civil: invalid date %q", s)
+	}
+	return Of(t), nil
+}
+
+// String returns the date as YYYY-MM-DD, or "" for the zero date.
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Format formats d with a time.Format layout, e.g. "2006-01" for its month.
+func (d Date) Format(layout string) string {
+	return d.Time().Format(layout)
+}
+
+// IsZero reports whether d is the zero date.
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// Time returns midnight UTC of d.
+func (d Date) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// In returns midnight of d in loc.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// AddDays returns d moved by n days.
+func (d Date) AddDays(n int) Date {
+	return Of(d.Time().AddDate(0, 0, n))
+}
+
+// AddMonths returns d moved by n months. Days past the end of the target
+// month roll over into the next one, as with time.AddDate.
+func (d Date) AddMonths(n int) Date {
+	return Of(d.Time().AddDate(0, n, 0))
+}
+
+// FirstOfMonth returns the first day of d's month.
+func (d Date) FirstOfMonth() Date {
+	return Date{d.Year, d.Month, 1}
+}
+
+// DaysSince returns the number of days from o to d, negative when d is
+// earlier. It counts calendar days, so it is exact whatever the time zone.
+func (d Date) DaysSince(o Date) int {
+	return int(d.Time().Unix()/86400 - o.Time().Unix()/86400)
+}
+
+// Weekday returns the day of the week of d.
+func (d Date) Weekday() time.Weekday {
+	return d.Time().Weekday()
+}
+
+func (d Date) Before(o Date) bool { return d.DaysSince(o) < 0 }
+func (d Date) After(o Date) bool  { return d.DaysSince(o) > 0 }
+
+// MarshalJSON writes YYYY-MM-DD, or null for the zero date.
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads YYYY-MM-DD; null and "" give the zero date.
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s *string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("civil: date must be a YYYY-MM-DD string")
+	}
+	if s == nil || *s == "" {
+		*d = Date{}
+		return nil
+	}
+	v, err := Parse(*s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Scan reads a DATE column. NULL gives the zero date.
+func (d *Date) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case time.Time:
+		*d = Of(v)
+		return nil
+	case string:
+		return d.scanText(v)
+	case []byte:
+		return d.scanText(string(v))
+	}
+	return fmt.Errorf("civil: cannot scan %T into a date", src)
+}
+
+func (d *Date) scanText(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Value writes the date as YYYY-MM-DD, or NULL for the zero date.
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.String(), nil
+}
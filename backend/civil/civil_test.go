@@ -0,0 +1,111 @@
+package civil_test
+
+import (
+	"backend/civil"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestOfKeepsTheLocalDate(t *testing.T) {
+	ist, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("no zoneinfo:", err)
+	}
+	// 00:30 in India is still the previous day in UTC
+	at := time.Date(2025, 3, 10, 0, 30, 0, 0, ist)
+	if got := civil.Of(at).String(); got != "2025-03-10" {
+		t.Errorf("Of(IST) = %s, want 2025-03-10", got)
+	}
+	if got := civil.Of(at.UTC()).String(); got != "2025-03-09" {
+		t.Errorf("Of(UTC) = %s, want 2025-03-09", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string]string{
+		"2025-03-10":                "2025-03-10",
+		"2025-03-10T00:00:00Z":      "2025-03-10",
+		"2025-03-10T00:00:00+05:30": "2025-03-10",
+	}
+	for in, want := range tests {
+		d, err := civil.Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", in, err)
+		}
+		if d.String() != want {
+			t.Errorf("Parse(%q) = %s, want %s", in, d, want)
+		}
+	}
+	for _, in := range []string{"", "10-03-2025", "2025-02-30"} {
+		if _, err := civil.Parse(in); err == nil {
+			t.Errorf("Parse(%q) should fail", in)
+		}
+	}
+}
+
+func TestDaysSince(t *testing.T) {
+	d := func(s string) civil.Date {
+		v, _ := civil.Parse(s)
+		return v
+	}
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2024-01-03", "2024-01-01", 2},
+		{"2024-01-01", "2024-01-03", -2},
+		{"2024-03-01", "2024-02-28", 2}, // leap year
+		{"2025-11-02", "2025-03-09", 238},
+	}
+	for _, tt := range tests {
+		if got := d(tt.a).DaysSince(d(tt.b)); got != tt.want {
+			t.Errorf("%s - %s = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAddMonths(t *testing.T) {
+	tests := []struct {
+		from string
+		n    int
+		want string
+	}{
+		{"2025-01-15", 1, "2025-02-15"},
+		{"2025-01-01", -1, "2024-12-01"},
+		{"2025-03-01", 1, "2025-04-01"},
+		{"2024-02-01", 1, "2024-03-01"},
+	}
+	for _, tt := range tests {
+		from, _ := civil.Parse(tt.from)
+		if got := from.AddMonths(tt.n).String(); got != tt.want {
+			t.Errorf("%s + %d months = %s, want %s", tt.from, tt.n, got, tt.want)
+		}
+	}
+	if got := (civil.Date{Year: 2025, Month: 3, Day: 31}).FirstOfMonth().String(); got != "2025-03-01" {
+		t.Errorf("FirstOfMonth = %s, want 2025-03-01", got)
+	}
+}
+
+func TestJSONAndScan(t *testing.T) {
+	var v struct {
+		Date civil.Date `json:"date"`
+	}
+	if err := json.Unmarshal([]byte(`{"date":"2025-03-10"}`), &v); err != nil || v.Date.String() != "2025-03-10" {
+		t.Fatalf("Unmarshal = %v, %v", v.Date, err)
+	}
+	if b, _ := json.Marshal(v); string(b) != `{"date":"2025-03-10"}` {
+		t.Errorf("Marshal = %s", b)
+	}
+	if b, _ := json.Marshal(struct{ D civil.Date }{}); string(b) != `{"D":null}` {
+		t.Errorf("zero date marshals as %s, want null", b)
+	}
+
+	var d civil.Date
+	if err := d.Scan(time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)); err != nil || d.String() != "2025-03-10" {
+		t.Errorf("Scan(time) = %v, %v", d, err)
+	}
+	if err := d.Scan(nil); err != nil || !d.IsZero() {
+		t.Errorf("Scan(nil) = %v, %v", d, err)
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"crypto/rand"
+	"log"
+	"os"
+	"time"
+)
+
+// Auth settings, read from the same environment as the database settings.
+var (
+	JWTSecret      []byte
+	TokenLifetime  time.Duration
+	BootstrapToken string
+)
+
+// LoadAuth reads JWT_SECRET, JWT_TTL and ADMIN_BOOTSTRAP_TOKEN.
+// Call it after ConnectDatabase so the .env file has been loaded.
+func LoadAuth() {
+	TokenLifetime = 24 * time.Hour
+	if ttl := os.Getenv("JWT_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			log.Fatalf("❌ Invalid JWT_TTL %q: must be a positive duration like 12h", ttl)
+		}
+		TokenLifetime = d
+	}
+
+	BootstrapToken = os.Getenv("ADMIN_BOOTSTRAP_TOKEN")
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		JWTSecret = []byte(secret)
+		return
+	}
+	if os.Getenv("APP_ENV") == "production" {
+		log.Fatal("❌ JWT_SECRET is not set in production mode")
+	}
+
+	// Development only: a random secret means tokens don't survive a restart.
+	JWTSecret = make([]byte, 32)
+	if _, err := rand.Read(JWTSecret); err != nil {
+		log.Fatalf("❌ Failed to generate JWT secret: %v", err)
+	}
+	log.Println("⚠️  JWT_SECRET not set, using a random secret for this run")
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"log"
+	"os"
+	"time"
+	_ "time/tzdata" // the server may have no zoneinfo installed
+)
+
+// Business details printed on invoices. The defaults are the ones the
+// billing screen shows.
+var (
+	BusinessName  string
+	BusinessPhone string
+	UPIID         string
+	UPIPayee      string
+
+	// Location is the business's time zone. "Today" and every cut-off are
+	// taken in it; dates themselves carry no time zone (see package civil).
+	Location = time.UTC
+)
+
+// LoadBusiness reads BUSINESS_NAME, BUSINESS_PHONE, UPI_ID, UPI_PAYEE and
+// BUSINESS_TZ.
+// Call it after ConnectDatabase so the .env file has been loaded.
+func LoadBusiness() {
+	BusinessName = getenv("BUSINESS_NAME", "Sri Balaji Milk Supply")
+	BusinessPhone = getenv("BUSINESS_PHONE", "9963432665 / 7989495557")
+	UPIID = getenv("UPI_ID", "7989495557@ybl")
+	UPIPayee = getenv("UPI_PAYEE", "T. SHIVA SHANKER")
+
+	loc, err := time.LoadLocation(getenv("BUSINESS_TZ", "Asia/Kolkata"))
+	if err != nil {
+		log.Fatalf("❌ Invalid BUSINESS_TZ: %v", err)
+	}
+	Location = loc
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"backend/cutoff"
+	"log"
+	"strconv"
+)
+
+var (
+	// OrderCutoff closes changes to a delivery day the evening before.
+	OrderCutoff cutoff.Policy
+
+	// ModificationRetention is how many days an ended modification stays
+	// live before the retention job archives it. Zero turns the job off.
+	ModificationRetention int
+)
+
+// LoadOrders reads ORDER_CUTOFF ("HH:MM" on the day before delivery, or
+// "off") and ORDER_CUTOFF_MODE ("reject" or "flag"). The cut-off is in the
+// business time zone unless ORDER_CUTOFF_TZ says otherwise. It also reads
+// MODIFICATION_RETENTION_DAYS. Call it after LoadBusiness.
+func LoadOrders() {
+	p, err := cutoff.Parse(
+		getenv("ORDER_CUTOFF", "21:00"),
+		getenv("ORDER_CUTOFF_TZ", Location.String()),
+		getenv("ORDER_CUTOFF_MODE", cutoff.Reject),
+	)
+	if err != nil {
+		log.Fatalf("❌ Invalid order cut-off: %v", err)
+	}
+	OrderCutoff = p
+	log.Printf("Order cut-off: %s (%s)\n", p, p.Mode)
+
+	days, err := strconv.Atoi(getenv("MODIFICATION_RETENTION_DAYS", "60"))
+	if err != nil || days < 0 {
+		log.Fatalf("❌ Invalid MODIFICATION_RETENTION_DAYS: %q", getenv("MODIFICATION_RETENTION_DAYS", ""))
+	}
+	ModificationRetention = days
+}
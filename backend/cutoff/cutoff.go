@@ -0,0 +1,75 @@
+// Package cutoff decides which delivery days can still be changed. Milk for a
+// day is ordered from the supplier the evening before, so changes to a day
+// close at a fixed time on the previous day, in the business's time zone.
+package cutoff
+
+import (
+	"backend/civil"
+	"fmt"
+	"time"
+	_ "time/tzdata" // the server may have no zoneinfo installed
+)
+
+// Modes of handling a change after the cut-off.
+const (
+	Reject = "reject" // refuse it unless an admin forces it with a reason
+	Flag   = "flag"   // accept it and record it for review
+)
+
+// Policy is a cut-off rule. The zero value lets every change through.
+type Policy struct {
+	Enabled  bool
+	Hour     int // on the day before delivery
+	Minute   int
+	Location *time.Location
+	Mode     string
+}
+
+// Parse builds a policy from an "HH:MM" time, an IANA time zone and a mode.
+// An at of "off" disables the cut-off.
+func Parse(at, zone, mode string) (Policy, error) {
+	if at == "off" {
+		return Policy{}, nil
+	}
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return Policy{}, fmt.Errorf("cutoff: time %q must be HH:MM or off", at)
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return Policy{}, fmt.Errorf("cutoff: time zone %q: %w", zone, err)
+	}
+	if mode != Reject && mode != Flag {
+		return Policy{}, fmt.Errorf("cutoff: mode %q must be %s or %s", mode, Reject, Flag)
+	}
+	return Policy{Enabled: true, Hour: t.Hour(), Minute: t.Minute(), Location: loc, Mode: mode}, nil
+}
+
+// Deadline returns the moment changes to the delivery day date close.
+func (p Policy) Deadline(date civil.Date) time.Time {
+	return time.Date(date.Year, date.Month, date.Day-1, p.Hour, p.Minute, 0, 0, p.Location)
+}
+
+// FirstOpenDay returns the first delivery day that can still be changed at
+// now.
+func (p Policy) FirstOpenDay(now time.Time) civil.Date {
+	day := civil.Of(now.In(p.Location)).AddDays(1)
+	if !now.Before(p.Deadline(day)) {
+		day = day.AddDays(1)
+	}
+	return day
+}
+
+// Closed reports whether a change starting on the delivery day start comes
+// after the cut-off at now.
+func (p Policy) Closed(start civil.Date, now time.Time) bool {
+	return p.Enabled && start.Before(p.FirstOpenDay(now))
+}
+
+// String describes the policy, e.g. "21:00 Asia/Kolkata the day before".
+func (p Policy) String() string {
+	if !p.Enabled {
+		return "off"
+	}
+	return fmt.Sprintf("%02d:%02d %s the day before", p.Hour, p.Minute, p.Location)
+}
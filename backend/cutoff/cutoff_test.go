@@ -0,0 +1,65 @@
+package cutoff_test
+
+import (
+	"backend/civil"
+	"backend/cutoff"
+	"testing"
+	"time"
+)
+
+func TestFirstOpenDay(t *testing.T) {
+	p, err := cutoff.Parse("21:00", "Asia/Kolkata", cutoff.Reject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ist := p.Location
+
+	tests := []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2025, 3, 9, 20, 59, 0, 0, ist), "2025-03-10"},
+		{time.Date(2025, 3, 9, 21, 0, 0, 0, ist), "2025-03-11"},
+		{time.Date(2025, 3, 9, 5, 0, 0, 0, ist), "2025-03-10"},
+		// 20:00 UTC is already 01:30 the next day in India
+		{time.Date(2025, 3, 9, 20, 0, 0, 0, time.UTC), "2025-03-11"},
+		{time.Date(2025, 3, 31, 22, 0, 0, 0, ist), "2025-04-02"},
+	}
+	for _, tt := range tests {
+		if got := p.FirstOpenDay(tt.now).String(); got != tt.want {
+			t.Errorf("FirstOpenDay(%v) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestClosed(t *testing.T) {
+	p, _ := cutoff.Parse("21:00", "Asia/Kolkata", cutoff.Reject)
+	now := time.Date(2025, 3, 9, 22, 0, 0, 0, p.Location)
+	tomorrow := civil.Date{Year: 2025, Month: 3, Day: 10}
+	if !p.Closed(tomorrow, now) {
+		t.Error("tomorrow should be closed after 21:00")
+	}
+	if p.Closed(tomorrow.AddDays(1), now) {
+		t.Error("the day after tomorrow should be open")
+	}
+
+	off, err := cutoff.Parse("off", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off.Closed(tomorrow.AddDays(-5), now) {
+		t.Error("a disabled cut-off closes nothing")
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, c := range [][3]string{
+		{"25:00", "Asia/Kolkata", cutoff.Reject},
+		{"21:00", "Mars/Olympus", cutoff.Reject},
+		{"21:00", "Asia/Kolkata", "warn"},
+	} {
+		if _, err := cutoff.Parse(c[0], c[1], c[2]); err == nil {
+			t.Errorf("Parse(%q, %q, %q) should fail", c[0], c[1], c[2])
+		}
+	}
+}
@@ -1,37 +1,21 @@
 package handlers
 
 import (
+	"backend/auth"
 	"backend/config"
 	"backend/models"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
-
-	// "log"
+	"log"
 	"net/http"
-	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecret = []byte("your_secret_key") // Change this in production!
-
-// Generate JWT Token
-func generateToken(username string) (string, error) {
-	claims := jwt.MapClaims{
-		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hrs
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
-}
-
-
-
-
 // Admin Login Handler
 // Admin Login Handler (Fixed)
-func AdminLogin(w http.ResponseWriter, r *http.Request) {
+func (s *Server) AdminLogin(w http.ResponseWriter, r *http.Request) {
 	var loginData struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
@@ -46,8 +30,8 @@ func AdminLogin(w http.ResponseWriter, r *http.Request) {
 
 	// Get admin from DB
 	var admin models.Admin
-	err = config.DB.QueryRow("SELECT admin_id, username, password_hash FROM admin WHERE username = $1", loginData.Username).
-		Scan(&admin.AdminID, &admin.Username, &admin.PasswordHash)
+	err = s.DB.QueryRow("SELECT admin_id, username, password_hash, role, is_active FROM admin WHERE username = $1", loginData.Username).
+		Scan(&admin.AdminID, &admin.Username, &admin.PasswordHash, &admin.Role, &admin.IsActive)
 	if err != nil {
 		http.Error(w, "Invalid username", http.StatusUnauthorized)
 		return
@@ -62,47 +46,78 @@ func AdminLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !admin.IsActive {
+		http.Error(w, "Account is disabled", http.StatusForbidden)
+		return
+	}
+
 	// Generate JWT Token
-	token, err := generateToken(admin.Username)
+	token, err := auth.IssueToken(admin.AdminID, admin.Username, admin.Role)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	fmt.Println("✅ Login Successful!")
-	response := map[string]string{"token": token}
+	response := map[string]string{"token": token, "role": admin.Role}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// Admin Registration (For first-time setup)
-func AdminRegister(w http.ResponseWriter, r *http.Request) {
-	var registrationData struct {
-    Username string `json:"username"`
-    Password string `json:"password"`
-}
+// Admin Registration
+// Requires a logged-in owner, or the ADMIN_BOOTSTRAP_TOKEN in the
+// X-Bootstrap-Token header while no admin exists yet (first-time setup).
+// The bootstrap admin is always an owner.
+func (s *Server) AdminRegister(w http.ResponseWriter, r *http.Request) {
+	bootstrap := false
+	if claims, err := auth.Authenticate(r, s.Admins); err == nil {
+		if claims.Role != auth.RoleOwner {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	} else {
+		allowed, err := s.bootstrapAllowed(r)
+		if err != nil {
+			log.Printf("Error checking bootstrap token: %v\n", err)
+			http.Error(w, "Failed to check bootstrap token", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		bootstrap = true
+	}
 
-err := json.NewDecoder(r.Body).Decode(&registrationData)
-if err != nil {
-    http.Error(w, "Invalid request format", http.StatusBadRequest)
-    return
-}
+	var registrationData adminRequest
+	err := json.NewDecoder(r.Body).Decode(&registrationData)
+	if err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if bootstrap {
+		registrationData.Role = auth.RoleOwner
+	}
 
-// Hash the password
-hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registrationData.Password), bcrypt.DefaultCost)
-if err != nil {
-    http.Error(w, "Failed to hash password", http.StatusInternalServerError)
-    return
-}
+	if _, status, err := s.createAdmin(registrationData); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
 
-// Insert into the database
-_, err = config.DB.Exec("INSERT INTO admin (admin_id, username, password_hash) VALUES (gen_random_uuid(), $1, $2)",
-    registrationData.Username, string(hashedPassword))
-if err != nil {
-    http.Error(w, "Failed to create admin", http.StatusInternalServerError)
-    return
+	fmt.Fprintln(w, "✅ Admin registered successfully!")
 }
 
-fmt.Fprintln(w, "✅ Admin registered successfully!")
+// bootstrapAllowed reports whether the request carries the bootstrap token and
+// the admin table is still empty, which makes the token single-use.
+func (s *Server) bootstrapAllowed(r *http.Request) (bool, error) {
+	token := r.Header.Get("X-Bootstrap-Token")
+	if config.BootstrapToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(config.BootstrapToken)) != 1 {
+		return false, nil
+	}
 
+	var exists bool
+	if err := s.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM admin)").Scan(&exists); err != nil {
+		return false, err
+	}
+	return !exists, nil
 }
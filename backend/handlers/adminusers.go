@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"backend/auth"
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// adminRequest is the body accepted when creating or updating an admin.
+type adminRequest struct {
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	Role         string   `json:"role"`
+	ApartmentIDs []string `json:"apartment_ids"`
+}
+
+var errLastOwner = errors.New("at least one active owner must remain")
+
+// createAdmin validates and inserts an admin with its apartment assignments.
+// It returns the HTTP status to use when it fails.
+func (s *Server) createAdmin(req adminRequest) (string, int, error) {
+	if req.Role == "" {
+		req.Role = auth.RoleManager
+	}
+	if req.Username == "" || req.Password == "" {
+		return "", http.StatusBadRequest, errors.New("username and password are required")
+	}
+	if !auth.ValidRole(req.Role) {
+		return "", http.StatusBadRequest, errors.New("role must be owner, manager or delivery")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("failed to hash password")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	var adminID string
+	err = tx.QueryRow(`
+		INSERT INTO admin (admin_id, username, password_hash, role)
+		VALUES (gen_random_uuid(), $1, $2, $3)
+		RETURNING admin_id
+	`, req.Username, string(hashedPassword), req.Role).Scan(&adminID)
+	if err != nil {
+		log.Printf("Error inserting admin: %v\n", err)
+		return "", http.StatusConflict, errors.New("failed to create admin, the username may already exist")
+	}
+
+	if err := setAdminApartments(tx, adminID, req.Role, req.ApartmentIDs); err != nil {
+		log.Printf("Error assigning apartments: %v\n", err)
+		return "", http.StatusBadRequest, errors.New("failed to assign apartments")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", http.StatusInternalServerError, errors.New("failed to commit transaction")
+	}
+	return adminID, http.StatusCreated, nil
+}
+
+// setAdminApartments replaces the apartment assignments. Only delivery staff
+// keep assignments; other roles see every apartment.
+func setAdminApartments(tx *sql.Tx, adminID, role string, apartmentIDs []string) error {
+	if _, err := tx.Exec(`DELETE FROM admin_apartments WHERE admin_id = $1`, adminID); err != nil {
+		return err
+	}
+	if role != auth.RoleDelivery || len(apartmentIDs) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO admin_apartments (admin_id, apartment_id)
+		SELECT $1, UNNEST($2::uuid[])
+		ON CONFLICT DO NOTHING
+	`, adminID, pq.Array(apartmentIDs))
+	return err
+}
+
+// ensureOwnerRemains fails if no other active owner than adminID would be left.
+func ensureOwnerRemains(tx *sql.Tx, adminID string) error {
+	var others int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM admin
+		 WHERE role = 'owner' AND is_active AND admin_id::text <> $1
+	`, adminID).Scan(&others)
+	if err != nil {
+		return err
+	}
+	if others == 0 {
+		return errLastOwner
+	}
+	return nil
+}
+
+// queryAdmins loads admins and their apartment assignments.
+func (s *Server) queryAdmins(where string, args ...interface{}) ([]models.Admin, error) {
+	rows, err := s.DB.Query(`
+		SELECT a.admin_id, a.username, a.role, a.is_active, a.created_at,
+		       COALESCE(ARRAY_AGG(aa.apartment_id::text) FILTER (WHERE aa.apartment_id IS NOT NULL), '{}')
+		  FROM admin a
+		  LEFT JOIN admin_apartments aa ON aa.admin_id = a.admin_id
+		 WHERE `+where+`
+		 GROUP BY a.admin_id
+		 ORDER BY a.username
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	admins := make([]models.Admin, 0)
+	for rows.Next() {
+		var admin models.Admin
+		err := rows.Scan(&admin.AdminID, &admin.Username, &admin.Role, &admin.IsActive, &admin.CreatedAt, pq.Array(&admin.ApartmentIDs))
+		if err != nil {
+			return nil, err
+		}
+		admins = append(admins, admin)
+	}
+	return admins, rows.Err()
+}
+
+// GetAdmins lists every admin account.
+func (s *Server) GetAdmins(w http.ResponseWriter, r *http.Request) {
+	admins, err := s.queryAdmins("TRUE")
+	if err != nil {
+		log.Printf("Error fetching admins: %v\n", err)
+		http.Error(w, "Failed to fetch admins", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(admins)
+}
+
+// GetCurrentAdmin returns the logged-in admin, so the frontend knows its role.
+func (s *Server) GetCurrentAdmin(w http.ResponseWriter, r *http.Request) {
+	claims := auth.FromContext(r.Context())
+	admins, err := s.queryAdmins("a.admin_id::text = $1", claims.AdminID())
+	if err != nil || len(admins) == 0 {
+		http.Error(w, "Admin not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(admins[0])
+}
+
+// CreateAdmin adds an admin account.
+func (s *Server) CreateAdmin(w http.ResponseWriter, r *http.Request) {
+	var req adminRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	adminID, status, err := s.createAdmin(req)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Admin created successfully", "admin_id": adminID})
+}
+
+// UpdateAdmin changes an admin's username, role and apartment assignments.
+func (s *Server) UpdateAdmin(w http.ResponseWriter, r *http.Request) {
+	adminID := mux.Vars(r)["id"]
+
+	var req adminRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" || !auth.ValidRole(req.Role) {
+		http.Error(w, "username and a valid role are required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var currentRole string
+	err = tx.QueryRow(`SELECT role FROM admin WHERE admin_id::text = $1 FOR UPDATE`, adminID).Scan(&currentRole)
+	if err != nil {
+		http.Error(w, "Admin not found", http.StatusNotFound)
+		return
+	}
+
+	if currentRole == auth.RoleOwner && req.Role != auth.RoleOwner {
+		if err := ensureOwnerRemains(tx, adminID); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE admin SET username = $1, role = $2 WHERE admin_id::text = $3`, req.Username, req.Role, adminID)
+	if err != nil {
+		log.Printf("Error updating admin: %v\n", err)
+		http.Error(w, "Failed to update admin, the username may already exist", http.StatusConflict)
+		return
+	}
+
+	if err := setAdminApartments(tx, adminID, req.Role, req.ApartmentIDs); err != nil {
+		log.Printf("Error assigning apartments: %v\n", err)
+		http.Error(w, "Failed to assign apartments", http.StatusBadRequest)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Admin updated successfully"})
+}
+
+// ChangeAdminPassword lets an admin change their own password (with the
+// current one), or an owner reset anyone's.
+func (s *Server) ChangeAdminPassword(w http.ResponseWriter, r *http.Request) {
+	adminID := mux.Vars(r)["id"]
+	claims := auth.FromContext(r.Context())
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.NewPassword == "" {
+		http.Error(w, "new_password is required", http.StatusBadRequest)
+		return
+	}
+
+	self := claims.AdminID() == adminID
+	if !self && claims.Role != auth.RoleOwner {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if self {
+		var hash string
+		err := s.DB.QueryRow(`SELECT password_hash FROM admin WHERE admin_id::text = $1`, adminID).Scan(&hash)
+		if err != nil {
+			http.Error(w, "Admin not found", http.StatusNotFound)
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.CurrentPassword)) != nil {
+			http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	res, err := s.DB.Exec(`UPDATE admin SET password_hash = $1 WHERE admin_id::text = $2`, string(hashedPassword), adminID)
+	if err != nil {
+		log.Printf("Error updating password: %v\n", err)
+		http.Error(w, "Failed to update password", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Admin not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password updated successfully"})
+}
+
+// DisableAdmin blocks an account; its existing tokens stop working immediately.
+func (s *Server) DisableAdmin(w http.ResponseWriter, r *http.Request) {
+	s.setAdminActive(w, r, false)
+}
+
+// EnableAdmin re-activates a disabled account.
+func (s *Server) EnableAdmin(w http.ResponseWriter, r *http.Request) {
+	s.setAdminActive(w, r, true)
+}
+
+func (s *Server) setAdminActive(w http.ResponseWriter, r *http.Request, active bool) {
+	adminID := mux.Vars(r)["id"]
+	claims := auth.FromContext(r.Context())
+
+	if !active && claims.AdminID() == adminID {
+		http.Error(w, "You cannot disable your own account", http.StatusConflict)
+		return
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var role string
+	err = tx.QueryRow(`SELECT role FROM admin WHERE admin_id::text = $1 FOR UPDATE`, adminID).Scan(&role)
+	if err != nil {
+		http.Error(w, "Admin not found", http.StatusNotFound)
+		return
+	}
+	if !active && role == auth.RoleOwner {
+		if err := ensureOwnerRemains(tx, adminID); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE admin SET is_active = $1 WHERE admin_id::text = $2`, active, adminID); err != nil {
+		log.Printf("Error updating admin status: %v\n", err)
+		http.Error(w, "Failed to update admin status", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	status := "enabled"
+	if !active {
+		status = "disabled"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": fmt.Sprintf("Admin %s successfully", status)})
+}
+
+// DeleteAdmin removes an account for good.
+func (s *Server) DeleteAdmin(w http.ResponseWriter, r *http.Request) {
+	adminID := mux.Vars(r)["id"]
+	claims := auth.FromContext(r.Context())
+
+	if claims.AdminID() == adminID {
+		http.Error(w, "You cannot delete your own account", http.StatusConflict)
+		return
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var role string
+	err = tx.QueryRow(`SELECT role FROM admin WHERE admin_id::text = $1 FOR UPDATE`, adminID).Scan(&role)
+	if err != nil {
+		http.Error(w, "Admin not found", http.StatusNotFound)
+		return
+	}
+	if role == auth.RoleOwner {
+		if err := ensureOwnerRemains(tx, adminID); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM admin WHERE admin_id::text = $1`, adminID); err != nil {
+		log.Printf("Error deleting admin: %v\n", err)
+		http.Error(w, "Failed to delete admin", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "Admin deleted successfully!")
+}
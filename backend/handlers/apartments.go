@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"backend/config"
+	"backend/auth"
 	"backend/models"
+	"backend/store"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -12,31 +13,26 @@ import (
 )
 
 // Get all apartments
-func GetApartments(w http.ResponseWriter, r *http.Request) {
-	rows, err := config.DB.Query("SELECT apartment_id, apartment_name, created_at FROM apartments")
+// Delivery staff only get the apartments assigned to them.
+func (s *Server) GetApartments(w http.ResponseWriter, r *http.Request) {
+	adminID := ""
+	if claims := auth.FromContext(r.Context()); claims != nil && claims.Role == auth.RoleDelivery {
+		adminID = claims.AdminID()
+	}
+
+	apartments, err := s.Apartments.ListApartments(adminID, includeArchived(r))
 	if err != nil {
+		log.Printf("Error fetching apartments: %v\n", err)
 		http.Error(w, "Failed to fetch apartments", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var apartments []models.Apartment
-	for rows.Next() {
-		var apartment models.Apartment
-		err := rows.Scan(&apartment.ApartmentID, &apartment.ApartmentName, &apartment.CreatedAt)
-		if err != nil {
-			http.Error(w, "Error scanning apartments", http.StatusInternalServerError)
-			return
-		}
-		apartments = append(apartments, apartment)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(apartments)
 }
 
 // Add a new apartment
-func CreateApartment(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateApartment(w http.ResponseWriter, r *http.Request) {
 	var apartment models.Apartment
 	err := json.NewDecoder(r.Body).Decode(&apartment)
 	if err != nil {
@@ -44,9 +40,7 @@ func CreateApartment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = config.DB.Exec("INSERT INTO apartments (apartment_id, apartment_name) VALUES (gen_random_uuid(), $1)",
-		apartment.ApartmentName)
-	if err != nil {
+	if _, err := s.Apartments.CreateApartment(apartment.ApartmentName); err != nil {
 		log.Printf("Error inserting apartment: %v\n", err)
 		http.Error(w, "Failed to add apartment", http.StatusInternalServerError)
 		return
@@ -55,17 +49,58 @@ func CreateApartment(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "Apartment added successfully!")
 }
 
-// Delete an apartment
-func DeleteApartment(w http.ResponseWriter, r *http.Request) {
+// Delete an apartment. The apartment is archived rather than removed, once
+// none of its customers is active.
+func (s *Server) DeleteApartment(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	apartmentID := params["id"]
 
-	_, err := config.DB.Exec("DELETE FROM apartments WHERE apartment_id = $1", apartmentID)
-	if err != nil {
-		log.Printf("Error deleting apartment: %v\n", err)
+	switch err := s.Apartments.ArchiveApartment(apartmentID); err {
+	case nil:
+	case store.ErrNotFound:
+		http.Error(w, "Apartment not found", http.StatusNotFound)
+		return
+	case store.ErrInUse:
+		http.Error(w, "Apartment still has active customers", http.StatusConflict)
+		return
+	default:
+		log.Printf("Error archiving apartment: %v\n", err)
 		http.Error(w, "Failed to delete apartment", http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Fprintln(w, "Apartment deleted successfully!")
+	fmt.Fprintln(w, "Apartment archived successfully!")
+}
+
+// RestoreApartment brings an archived apartment back. Its customers stay
+// archived until they are restored one by one.
+func (s *Server) RestoreApartment(w http.ResponseWriter, r *http.Request) {
+	switch err := s.Apartments.RestoreApartment(mux.Vars(r)["id"]); err {
+	case nil:
+	case store.ErrNotFound:
+		http.Error(w, "Apartment not found", http.StatusNotFound)
+		return
+	default:
+		log.Printf("Error restoring apartment: %v\n", err)
+		http.Error(w, "Failed to restore apartment", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "Apartment restored successfully!")
+}
+
+// requireApartmentAccess answers 403 and returns false when the logged-in
+// admin may not see the apartment.
+func (s *Server) requireApartmentAccess(w http.ResponseWriter, r *http.Request, apartmentID string) bool {
+	ok, err := auth.CanAccessApartment(s.Admins, auth.FromContext(r.Context()), apartmentID)
+	if err != nil {
+		log.Printf("Error checking apartment access: %v\n", err)
+		http.Error(w, "Failed to check apartment access", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
 }
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"archive/zip"
+	"backend/billing"
+	"backend/config"
+	"backend/invoicepdf"
+	"backend/orders"
+	"backend/pricing"
+	"backend/store"
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+)
+
+// GetMonthlyBillPDF renders the GetMonthlyBill data of one customer as a PDF invoice.
+func (s *Server) GetMonthlyBillPDF(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	customerID := q.Get("customer_id")
+	if customerID == "" || q.Get("month") == "" || q.Get("year") == "" {
+		http.Error(w, "Missing required parameters", http.StatusBadRequest)
+		return
+	}
+	startDate, err := monthStart(q.Get("year"), q.Get("month"))
+	if err != nil {
+		http.Error(w, "Invalid month or year", http.StatusBadRequest)
+		return
+	}
+
+	// 1) Who the invoice is for
+	user, err := s.Customers.GetCustomer(customerID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching customer %s: %v\n", customerID, err)
+		http.Error(w, "Failed to fetch customer", http.StatusInternalServerError)
+		return
+	}
+	cust := invoicepdf.Customer{Name: user.Name, RoomNumber: user.RoomNumber}
+	if apt, err := s.Apartments.GetApartment(user.ApartmentID); err == nil {
+		cust.ApartmentName = apt.ApartmentName
+	}
+
+	// 2) The same bill GetMonthlyBill returns
+	bill, err := billing.Compute(s.Stores, customerID, startDate.Year, startDate.Month)
+	if err != nil {
+		log.Printf("Error computing bill for %s: %v\n", customerID, err)
+		http.Error(w, "Failed to compute bill", http.StatusInternalServerError)
+		return
+	}
+	products, err := s.loadProductLabels()
+	if err != nil {
+		log.Printf("Error fetching products: %v\n", err)
+		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		return
+	}
+
+	// 3) Render before writing anything so failures still get a proper status
+	var buf bytes.Buffer
+	if err := invoicepdf.Render(&buf, businessDetails(), cust, bill, products); err != nil {
+		log.Printf("Error rendering invoice for %s: %v\n", customerID, err)
+		http.Error(w, "Failed to render invoice", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", invoiceFilename(cust, startDate.Format("2006-01"))))
+	w.Write(buf.Bytes())
+}
+
+// GetApartmentMonthlyBillsZip renders the invoice of every customer of an
+// apartment and returns them as one zip file.
+func (s *Server) GetApartmentMonthlyBillsZip(w http.ResponseWriter, r *http.Request) {
+	aptID := mux.Vars(r)["id"]
+	q := r.URL.Query()
+	if q.Get("month") == "" || q.Get("year") == "" {
+		http.Error(w, "Missing required parameters", http.StatusBadRequest)
+		return
+	}
+	startDate, err := monthStart(q.Get("year"), q.Get("month"))
+	if err != nil {
+		http.Error(w, "Invalid month or year", http.StatusBadRequest)
+		return
+	}
+
+	apt, err := s.Apartments.GetApartment(aptID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Apartment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching apartment %s: %v\n", aptID, err)
+		http.Error(w, "Failed to fetch apartment", http.StatusInternalServerError)
+		return
+	}
+
+	aptName := apt.ApartmentName
+
+	// 1) Customers in delivery order, archived ones included since they may
+	// have been delivered to that month
+	users, err := s.Customers.ListCustomers(store.CustomerFilter{ApartmentID: aptID, IncludeArchived: true})
+	if err != nil {
+		log.Printf("Error fetching users: %v\n", err)
+		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		return
+	}
+
+	// 2) One plan, one price book and one product list for the whole apartment
+	start, end := billing.MonthRange(startDate.Year, startDate.Month)
+	plan, err := orders.LoadApartment(s.Stores, aptID, start, end)
+	if err != nil {
+		log.Printf("Error resolving orders for apartment %s: %v\n", aptID, err)
+		http.Error(w, "Failed to resolve orders", http.StatusInternalServerError)
+		return
+	}
+	prices, err := pricing.Load(s.Prices)
+	if err != nil {
+		log.Printf("Error loading prices: %v\n", err)
+		http.Error(w, "Failed to load prices", http.StatusInternalServerError)
+		return
+	}
+	products, err := s.loadProductLabels()
+	if err != nil {
+		log.Printf("Error fetching products: %v\n", err)
+		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		return
+	}
+
+	// 3) One PDF per customer in the archive
+	period := startDate.Format("2006-01")
+	biz := businessDetails()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, u := range users {
+		cust := invoicepdf.Customer{Name: u.Name, ApartmentName: aptName, RoomNumber: u.RoomNumber}
+		bill := billing.FromPlan(plan, prices, u.UserID, startDate.Year, startDate.Month)
+		if u.Archived && bill.DaysDelivered() == 0 {
+			continue
+		}
+		f, err := zw.Create(invoiceFilename(cust, period))
+		if err == nil {
+			err = invoicepdf.Render(f, biz, cust, bill, products)
+		}
+		if err != nil {
+			log.Printf("Error rendering invoice for %s: %v\n", u.UserID, err)
+			http.Error(w, "Failed to render invoices", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("Error writing invoice archive: %v\n", err)
+		http.Error(w, "Failed to render invoices", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", safeFilename(aptName)+"-"+period+".zip"))
+	w.Write(buf.Bytes())
+}
+
+// loadProductLabels returns the name, acronym and unit of every product,
+// archived ones included.
+func (s *Server) loadProductLabels() (map[string]invoicepdf.Product, error) {
+	list, err := s.Products.ListProducts(true)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make(map[string]invoicepdf.Product, len(list))
+	for _, p := range list {
+		products[p.ProductID] = invoicepdf.Product{Name: p.ProductName, Acronym: p.Acronym, Unit: p.Unit}
+	}
+	return products, nil
+}
+
+func businessDetails() invoicepdf.Business {
+	return invoicepdf.Business{
+		Name:     config.BusinessName,
+		Phone:    config.BusinessPhone,
+		UPIID:    config.UPIID,
+		UPIPayee: config.UPIPayee,
+	}
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// invoiceFilename names a customer's PDF after their room and name.
+func invoiceFilename(c invoicepdf.Customer, period string) string {
+	return safeFilename(c.RoomNumber+"-"+c.Name) + "-" + period + ".pdf"
+}
+
+func safeFilename(s string) string {
+	return unsafeFilenameChars.ReplaceAllString(s, "_")
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"backend/auth"
+	"backend/store"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// moveRequest is the body of the move endpoint. A priority_order of zero or
+// past the end appends the customer to the target apartment.
+type moveRequest struct {
+	ApartmentID   string `json:"apartment_id"`
+	PriorityOrder int    `json:"priority_order"`
+}
+
+func moveJSON(mv store.CustomerMove) map[string]interface{} {
+	return map[string]interface{}{
+		"move_id":           mv.MoveID,
+		"user_id":           mv.UserID,
+		"from_apartment_id": mv.FromApartmentID,
+		"to_apartment_id":   mv.ToApartmentID,
+		"from_priority":     mv.FromPriority,
+		"to_priority":       mv.ToPriority,
+		"moved_on":          mv.MovedOn.String(),
+		"admin_id":          mv.AdminID,
+		"created_at":        mv.CreatedAt,
+	}
+}
+
+// MoveCustomer moves a customer to another apartment as of today. The old
+// apartment's delivery order closes up behind it and the customer is slotted
+// in at priority_order in the new one. Orders, payments and invoices follow
+// the customer; days before the move keep the old apartment's prices.
+func (s *Server) MoveCustomer(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+
+	var req moveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	// 1) The customer must be active and the target a different, active apartment
+	customer, err := s.Customers.GetCustomer(userID)
+	if err != nil {
+		writeMoveError(w, err, "Customer not found")
+		return
+	}
+	if customer.Archived {
+		http.Error(w, "Customer is archived", http.StatusConflict)
+		return
+	}
+	errs := fieldErrors{}
+	if req.ApartmentID == "" {
+		errs.add("apartment_id", "required")
+	} else if req.ApartmentID == customer.ApartmentID {
+		errs.add("apartment_id", "customer already lives there")
+	}
+	if req.PriorityOrder < 0 {
+		errs.add("priority_order", "must not be negative")
+	}
+	if errs.write(w) {
+		return
+	}
+	target, err := s.Apartments.GetApartment(req.ApartmentID)
+	if err != nil {
+		writeMoveError(w, err, "Apartment not found")
+		return
+	}
+	if target.Archived {
+		http.Error(w, "Apartment is archived", http.StatusConflict)
+		return
+	}
+
+	// 2) The admin must look after both apartments
+	if !s.requireApartmentAccess(w, r, customer.ApartmentID) || !s.requireApartmentAccess(w, r, target.ApartmentID) {
+		return
+	}
+
+	// 3) Move and record it, in one transaction
+	mv := store.CustomerMove{
+		UserID:        userID,
+		ToApartmentID: target.ApartmentID,
+		ToPriority:    req.PriorityOrder,
+		MovedOn:       s.today(),
+	}
+	if claims := auth.FromContext(r.Context()); claims != nil {
+		mv.AdminID = claims.AdminID()
+	}
+	mv, err = s.Customers.MoveCustomer(mv)
+	if err != nil {
+		writeMoveError(w, err, "Customer not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(moveJSON(mv))
+}
+
+// GetCustomerMoves lists the apartments a customer has moved between, oldest
+// first.
+func (s *Server) GetCustomerMoves(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	if _, err := s.Customers.GetCustomer(userID); err != nil {
+		writeMoveError(w, err, "Customer not found")
+		return
+	}
+
+	moves, err := s.Customers.CustomerMoves([]string{userID})
+	if err != nil {
+		writeMoveError(w, err, "")
+		return
+	}
+	resp := make([]map[string]interface{}, 0, len(moves))
+	for _, mv := range moves {
+		resp = append(resp, moveJSON(mv))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeMoveError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, notFound, http.StatusNotFound)
+	default:
+		log.Printf("Customer move error: %v\n", err)
+		http.Error(w, "Failed to move customer", http.StatusInternalServerError)
+	}
+}
@@ -1,58 +1,43 @@
 package handlers
 
 import (
-	"backend/config"
+	"backend/civil"
 	"backend/models"
+	"backend/orders"
+	"backend/recurrence"
+	"backend/store"
 
 	// "database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/mux"
 )
 
 // Get all customers
-func GetCustomers(w http.ResponseWriter, r *http.Request) {
-	rows, err := config.DB.Query("SELECT user_id, name, apartment_id, room_number, phone_number, email, created_at FROM users ORDER BY created_at DESC")
+func (s *Server) GetCustomers(w http.ResponseWriter, r *http.Request) {
+	customers, err := s.Customers.ListCustomers(store.CustomerFilter{NewestFirst: true, IncludeArchived: includeArchived(r)})
 	if err != nil {
 		http.Error(w, "Failed to fetch customers", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var customers []models.User
-	for rows.Next() {
-		var customer models.User
-		err := rows.Scan(&customer.UserID, &customer.Name, &customer.ApartmentID, &customer.RoomNumber, &customer.PhoneNumber, &customer.Email, &customer.CreatedAt)
-		if err != nil {
-			http.Error(w, "Error scanning customers", http.StatusInternalServerError)
-			return
-		}
-		customers = append(customers, customer)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(customers)
 }
 
-func GetApartCustomers(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetApartCustomers(w http.ResponseWriter, r *http.Request) {
 	apartmentID := r.URL.Query().Get("apartment_id")
 	if apartmentID == "" {
 		http.Error(w, "apartment_id is required", http.StatusBadRequest)
 		return
 	}
 
-	// Query to fetch users from the correct table, sorted by priority_order
-	query := `
-		SELECT user_id, name, apartment_id, room_number, phone_number, email, created_at, priority_order 
-		FROM users 
-		WHERE apartment_id = $1 
-		ORDER BY priority_order ASC
-	`
-	rows, err := config.DB.Query(query, apartmentID)
+	// Users of the apartment, sorted by priority_order
+	users, err := s.Customers.ListCustomers(store.CustomerFilter{ApartmentID: apartmentID, IncludeArchived: includeArchived(r)})
 
 	// Log the actual database error if the query fails
 	if err != nil {
@@ -60,20 +45,6 @@ func GetApartCustomers(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Failed to fetch users: %v", err), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	// Store the users in a slice
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		err := rows.Scan(&user.UserID, &user.Name, &user.ApartmentID, &user.RoomNumber, &user.PhoneNumber, &user.Email, &user.CreatedAt, &user.PriorityOrder)
-		if err != nil {
-			log.Println("Row Scanning Error:", err)
-			http.Error(w, "Error scanning users", http.StatusInternalServerError)
-			return
-		}
-		users = append(users, user)
-	}
 
 	// Return users in JSON format
 	w.Header().Set("Content-Type", "application/json")
@@ -81,7 +52,7 @@ func GetApartCustomers(w http.ResponseWriter, r *http.Request) {
 }
 // Add a new customer
 
-func CreateCustomer(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 	var customer models.User
 	err := json.NewDecoder(r.Body).Decode(&customer)
 	if err != nil {
@@ -89,39 +60,9 @@ func CreateCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch last priority (append position)
-	var lastPriority int
-	err = config.DB.QueryRow("SELECT COALESCE(MAX(priority_order), 0) + 1 FROM users WHERE apartment_id = $1", customer.ApartmentID).Scan(&lastPriority)
-	if err != nil {
-		log.Printf("Error fetching last priority: %v\n", err)
-		http.Error(w, "Failed to determine priority order", http.StatusInternalServerError)
-		return
-	}
-
-	// If no valid priority_order is specified, assign lastPriority
-	if customer.PriorityOrder <= 0 || customer.PriorityOrder >= lastPriority {
-		customer.PriorityOrder = lastPriority
-	} else {
-		// Shift others starting from the given priority
-		_, err := config.DB.Exec(`
-			UPDATE users
-			SET priority_order = priority_order + 1
-			WHERE apartment_id = $1 AND priority_order >= $2
-		`, customer.ApartmentID, customer.PriorityOrder)
-
-		if err != nil {
-			log.Printf("Error shifting priorities: %v\n", err)
-			http.Error(w, "Failed to shift priorities", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Insert new customer with assigned/updated priority
-	_, err = config.DB.Exec(`
-		INSERT INTO users (user_id, name, apartment_id, room_number, phone_number, email, priority_order) 
-		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6)
-	`, customer.Name, customer.ApartmentID, customer.RoomNumber, customer.PhoneNumber, customer.Email, customer.PriorityOrder)
-
+	// Insert at the requested priority, shifting others, or append when it
+	// is missing or out of range
+	_, err = s.Customers.CreateCustomer(customer)
 	if err != nil {
 		log.Printf("Error inserting customer: %v\n", err)
 		http.Error(w, "Failed to add customer", http.StatusInternalServerError)
@@ -167,7 +108,7 @@ func CreateCustomer(w http.ResponseWriter, r *http.Request) {
 
 
 
-func UpdateCustomer(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	userID := params["id"]
 
@@ -181,64 +122,32 @@ func UpdateCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := config.DB.Begin()
-	if err != nil {
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+	// Changing apartment goes through the move endpoint, which keeps both
+	// apartments' delivery orders intact and records the move
+	current, err := s.Customers.GetCustomer(userID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Customer not found", http.StatusNotFound)
 		return
 	}
-
-	// Step 1: Get current priority of this user
-	var currentPriority int
-	err = tx.QueryRow("SELECT priority_order FROM users WHERE user_id = $1", userID).Scan(&currentPriority)
 	if err != nil {
-		tx.Rollback()
-		http.Error(w, "Customer not found", http.StatusNotFound)
+		log.Printf("Error fetching customer %s: %v\n", userID, err)
+		http.Error(w, "Failed to update customer", http.StatusInternalServerError)
 		return
 	}
-
-	newPriority := customer.PriorityOrder
-
-	if newPriority != currentPriority {
-		// Reorder others
-		if newPriority < currentPriority {
-			// Shift others down
-			_, err = tx.Exec(`
-				UPDATE users 
-				SET priority_order = priority_order + 1
-				WHERE apartment_id = $1 AND priority_order >= $2 AND priority_order < $3 AND user_id != $4
-			`, customer.ApartmentID, newPriority, currentPriority, userID)
-		} else {
-			// Shift others up
-			_, err = tx.Exec(`
-				UPDATE users 
-				SET priority_order = priority_order - 1
-				WHERE apartment_id = $1 AND priority_order <= $2 AND priority_order > $3 AND user_id != $4
-			`, customer.ApartmentID, newPriority, currentPriority, userID)
-		}
-
-		if err != nil {
-			tx.Rollback()
-			http.Error(w, "Failed to reorder other customers", http.StatusInternalServerError)
-			return
-		}
+	if customer.ApartmentID != "" && customer.ApartmentID != current.ApartmentID {
+		http.Error(w, "Use POST /customers/{id}/move to change apartment", http.StatusConflict)
+		return
 	}
 
-	// Step 2: Update this customer
-	_, err = tx.Exec(`
-		UPDATE users 
-		SET name = $1, apartment_id = $2, room_number = $3, phone_number = $4, email = $5, priority_order = $6
-		WHERE user_id = $7
-	`, customer.Name, customer.ApartmentID, customer.RoomNumber, customer.PhoneNumber, customer.Email, newPriority, userID)
-
-	if err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to update customer", http.StatusInternalServerError)
+	// Move the customer to its new priority and save it, in one transaction
+	err = s.Customers.UpdateCustomer(userID, customer)
+	if err == store.ErrNotFound {
+		http.Error(w, "Customer not found", http.StatusNotFound)
 		return
 	}
-
-	err = tx.Commit()
 	if err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		log.Printf("Error updating customer: %v\n", err)
+		http.Error(w, "Failed to update customer", http.StatusInternalServerError)
 		return
 	}
 
@@ -272,68 +181,63 @@ func UpdateCustomer(w http.ResponseWriter, r *http.Request) {
 
 
 
-// Delete a customer
-func DeleteCustomer(w http.ResponseWriter, r *http.Request) {
+// Delete a customer. The customer is archived rather than removed, so past
+// bills keep it, and can be restored.
+func (s *Server) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	userID := params["id"]
 
-	// Get apartment_id and priority_order of the customer being deleted
-	var apartmentID string
-	var deletedPriority int
-	err := config.DB.QueryRow("SELECT apartment_id, priority_order FROM users WHERE user_id = $1", userID).
-		Scan(&apartmentID, &deletedPriority)
-
-	if err != nil {
-		log.Printf("Error fetching customer details: %v\n", err)
+	// Archive the customer and shift the ones after it up, in one transaction
+	err := s.Customers.ArchiveCustomer(userID, s.today())
+	if err == store.ErrNotFound {
 		http.Error(w, "Customer not found", http.StatusNotFound)
 		return
 	}
-
-	// Start a transaction
-	tx, err := config.DB.Begin()
-	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
-		http.Error(w, "Failed to delete customer", http.StatusInternalServerError)
+	if err == store.ErrInUse {
+		http.Error(w, "Customer still has a default order or upcoming modifications", http.StatusConflict)
 		return
 	}
-
-	// Delete the customer
-	_, err = tx.Exec("DELETE FROM users WHERE user_id = $1", userID)
 	if err != nil {
-		log.Printf("Error deleting customer: %v\n", err)
-		tx.Rollback()
+		log.Printf("Error archiving customer: %v\n", err)
 		http.Error(w, "Failed to delete customer", http.StatusInternalServerError)
 		return
 	}
 
-	// Shift priority_order of remaining users in the same apartment
-	_, err = tx.Exec(`
-		UPDATE users 
-		SET priority_order = priority_order - 1 
-		WHERE apartment_id = $1 AND priority_order > $2
-	`, apartmentID, deletedPriority)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "Customer archived and priorities updated successfully!")
+}
+
+// RestoreCustomer brings an archived customer back, last in its apartment's
+// delivery order.
+func (s *Server) RestoreCustomer(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
 
+	customer, err := s.Customers.GetCustomer(userID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Printf("Error updating priorities: %v\n", err)
-		tx.Rollback()
-		http.Error(w, "Failed to reorder customers", http.StatusInternalServerError)
+		log.Printf("Error fetching customer %s: %v\n", userID, err)
+		http.Error(w, "Failed to restore customer", http.StatusInternalServerError)
+		return
+	}
+	if apt, err := s.Apartments.GetApartment(customer.ApartmentID); err == nil && apt.Archived {
+		http.Error(w, "Restore the customer's apartment first", http.StatusConflict)
 		return
 	}
 
-	// Commit the transaction
-	err = tx.Commit()
-	if err != nil {
-		log.Printf("Error committing transaction: %v\n", err)
-		http.Error(w, "Failed to finalize deletion", http.StatusInternalServerError)
+	if err := s.Customers.RestoreCustomer(userID); err != nil {
+		log.Printf("Error restoring customer: %v\n", err)
+		http.Error(w, "Failed to restore customer", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintln(w, "Customer deleted and priorities updated successfully!")
+	fmt.Fprintln(w, "Customer restored successfully!")
 }
 
 
-func CreatebulkCustomers(w http.ResponseWriter, r *http.Request) { 
+func (s *Server) CreatebulkCustomers(w http.ResponseWriter, r *http.Request) { 
 	var customers []models.User
 
 	// Decode the incoming JSON request into an array of customers
@@ -343,21 +247,8 @@ func CreatebulkCustomers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Prepare the SQL statement for batch insertion
-	query := "INSERT INTO users (user_id, name, apartment_id, room_number, phone_number, email) VALUES "
-	values := []interface{}{}
-	placeholders := []string{}
-
-	for i, customer := range customers {
-		index := i * 5
-		placeholders = append(placeholders, fmt.Sprintf("(gen_random_uuid(), $%d, $%d, $%d, $%d, $%d)", index+1, index+2, index+3, index+4, index+5))
-		values = append(values, customer.Name, customer.ApartmentID, customer.RoomNumber, customer.PhoneNumber, customer.Email)
-	}
-
-	// Final query string
-	query += strings.Join(placeholders, ", ")
-
-	_, err = config.DB.Exec(query, values...)
+	// Insert them all in one transaction
+	err = s.Customers.CreateCustomers(customers)
 	if err != nil {
 		log.Printf("Error inserting customers: %v\n", err)
 		http.Error(w, "Failed to add customers", http.StatusInternalServerError)
@@ -413,7 +304,7 @@ func CreatebulkCustomers(w http.ResponseWriter, r *http.Request) {
 // 	json.NewEncoder(w).Encode(map[string]string{"message": "Default order created successfully!"})
 // }
 
-func CreateDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	customerID := params["id"]
 
@@ -421,6 +312,9 @@ func CreateDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
 
 	var request struct {
 		IsAlternating bool                     `json:"is_alternating_order"`
+		OrderMode     string                   `json:"order_mode"`         // optional, wins over is_alternating_order
+		Anchor        string                   `json:"alternating_anchor"` // optional YYYY-MM-DD
+		EffectiveFrom string                   `json:"effective_from"`     // optional YYYY-MM-DD, default today
 		Products      []map[string]interface{} `json:"products"`
 	}
 
@@ -434,87 +328,62 @@ func CreateDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
 	payloadBytes, _ := json.MarshalIndent(request, "", "  ")
 	log.Println("Received Payload:\n", string(payloadBytes))
 
-	// Step 1: Delete previous default orders
-	log.Println("Deleting existing default_order_items...")
-	_, err = config.DB.Exec("DELETE FROM default_order_items WHERE user_id = $1", customerID)
-	if err != nil {
-		log.Println("Error deleting default_order_items:", err)
-		http.Error(w, "Failed to clear normal default orders", http.StatusInternalServerError)
+	mode, ok := requestedMode(w, request.OrderMode, request.IsAlternating)
+	if !ok {
 		return
 	}
-
-	log.Println("Deleting existing alternating_default_order_items...")
-	_, err = config.DB.Exec("DELETE FROM alternating_default_order_items WHERE user_id = $1", customerID)
-	if err != nil {
-		log.Println("Error deleting alternating_default_order_items:", err)
-		http.Error(w, "Failed to clear alternating default orders", http.StatusInternalServerError)
+	if !s.checkCustomerActive(w, customerID) {
 		return
 	}
 
-	// Step 2: Insert new default orders
-	if request.IsAlternating {
-		log.Println("Inserting alternating default order items...")
-		for i, item := range request.Products {
-			productID, ok1 := item["product_id"].(string)
-			quantityFloat, ok2 := item["quantity"].(float64) // always float64 from JSON
-			dayType, ok3 := item["day_type"].(string)
-
-			if !ok1 || !ok2 || !ok3 {
-				log.Printf("Invalid data at index %d: %v\n", i, item)
-				http.Error(w, "Invalid product entry in alternating order", http.StatusBadRequest)
-				return
-			}
+	from, override, ok := s.defaultsEffectiveFrom(w, r, customerID, request.EffectiveFrom)
+	if !ok {
+		return
+	}
+	anchor, ok := s.alternatingAnchor(w, customerID, mode == store.ModeAlternating, request.Anchor, from)
+	if !ok {
+		return
+	}
 
-			log.Printf("Inserting: product_id=%s, quantity=%d, day_type=%s\n", productID, int(quantityFloat), dayType)
+	// Step 1: Validate the new default order
+	items := make([]store.DefaultItem, 0, len(request.Products))
+	for i, item := range request.Products {
+		productID, ok1 := item["product_id"].(string)
+		quantityFloat, ok2 := item["quantity"].(float64) // always float64 from JSON
+		dayType, _ := item["day_type"].(string)
 
-			_, err := config.DB.Exec(
-				"INSERT INTO alternating_default_order_items (user_id, product_id, quantity, day_type) VALUES ($1, $2, $3, $4)",
-				customerID, productID, int(quantityFloat), dayType,
-			)
-			if err != nil {
-				log.Println("Insert failed for alternating item:", err)
-				http.Error(w, "Failed to insert alternating default order", http.StatusInternalServerError)
-				return
-			}
+		rule, ok := itemRecurrence(w, item["recurrence"])
+		if !ok {
+			return
 		}
-	} else {
-		log.Println("Inserting normal default order items...")
-		for i, item := range request.Products {
-			productID, ok1 := item["product_id"].(string)
-			quantityFloat, ok2 := item["quantity"].(float64)
-
-			if !ok1 || !ok2 {
-				log.Printf("Invalid data at index %d: %v\n", i, item)
-				http.Error(w, "Invalid product entry in normal order", http.StatusBadRequest)
-				return
-			}
-
-			log.Printf("Inserting: product_id=%s, quantity=%d\n", productID, int(quantityFloat))
-
-			_, err := config.DB.Exec(
-				"INSERT INTO default_order_items (user_id, product_id, quantity) VALUES ($1, $2, $3)",
-				customerID, productID, int(quantityFloat),
-			)
-			if err != nil {
-				log.Println("Insert failed for default item:", err)
-				http.Error(w, "Failed to insert default order", http.StatusInternalServerError)
-				return
-			}
+		if rule == "" && mode != store.ModeNormal {
+			rule = orders.DayTypeRule(mode, anchor, dayType)
+		}
+		if !ok1 || !ok2 || (mode != store.ModeNormal && rule == "") {
+			log.Printf("Invalid data at index %d: %v\n", i, item)
+			http.Error(w, "Invalid product entry in "+mode+" order", http.StatusBadRequest)
+			return
 		}
+
+		items = append(items, store.DefaultItem{
+			ProductID:  productID,
+			Quantity:   float64(int(quantityFloat)),
+			Recurrence: rule,
+		})
 	}
 
-	// Step 3: Update user flag
-	log.Printf("Updating user %s is_alternating_order = %v\n", customerID, request.IsAlternating)
-	_, err = config.DB.Exec("UPDATE users SET is_alternating_order = $1 WHERE user_id = $2", request.IsAlternating, customerID)
-	if err != nil {
-		log.Println("Failed to update user flag:", err)
-		http.Error(w, "Failed to update user type", http.StatusInternalServerError)
+	// Step 2: Replace every kind of default order from the effective day and update the user mode
+	log.Printf("Saving default order for %s from %s, order_mode = %s\n", customerID, from, mode)
+	if err := s.Orders.ReplaceDefaults(customerID, mode, anchor, from, items); err != nil {
+		log.Println("Failed to save default order:", err)
+		http.Error(w, "Failed to save default order", http.StatusInternalServerError)
 		return
 	}
+	s.recordOverrides(w, override)
 
 	log.Println("Default order saved successfully.")
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Default order created successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "Default order created successfully", "effective_from": from.String()})
 }
 
 
@@ -586,7 +455,7 @@ func CreateDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
 // }
 
 
-func CreateDefaultOrder(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateDefaultOrder(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	customerID := params["id"]
 
@@ -601,41 +470,36 @@ func CreateDefaultOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if a default order already exists
-	var exists bool
-	err = config.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM default_order_items WHERE user_id = $1)", customerID).Scan(&exists)
+	existing, err := s.defaultItems(customerID, store.ModeNormal)
 	if err != nil {
 		http.Error(w, "Failed to check default order", http.StatusInternalServerError)
 		return
 	}
-	if exists {
+	if len(orders.Latest(existing)) > 0 {
 		http.Error(w, "Default order already exists for this user", http.StatusConflict)
 		return
 	}
+	from, override, ok := s.defaultsEffectiveFrom(w, r, customerID, "")
+	if !ok {
+		return
+	}
 
-	// Insert into default_order_items
+	// Save the products and mark the user as non-alternating
+	items := make([]store.DefaultItem, 0, len(request.Products))
 	for _, item := range request.Products {
-		_, err := config.DB.Exec(
-			"INSERT INTO default_order_items (user_id, product_id, quantity) VALUES ($1, $2, $3)",
-			customerID, item.ProductID, item.Quantity,
-		)
-		if err != nil {
-			http.Error(w, "Failed to insert product", http.StatusInternalServerError)
-			return
-		}
+		items = append(items, store.DefaultItem{ProductID: item.ProductID, Quantity: item.Quantity})
 	}
-
-	// Update user to mark as non-alternating
-	_, err = config.DB.Exec("UPDATE users SET is_alternating_order = false WHERE user_id = $1", customerID)
-	if err != nil {
-		http.Error(w, "Failed to update user type", http.StatusInternalServerError)
+	if err := s.Orders.ReplaceDefaults(customerID, store.ModeNormal, civil.Date{}, from, items); err != nil {
+		http.Error(w, "Failed to insert product", http.StatusInternalServerError)
 		return
 	}
+	s.recordOverrides(w, override)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Default order created successfully"})
 }
 
-func CreateAlternatingDefaultOrder(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateAlternatingDefaultOrder(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	customerID := params["id"]
 
@@ -650,35 +514,40 @@ func CreateAlternatingDefaultOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if alternating default order already exists
-	var exists bool
-	err = config.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM alternating_default_order_items WHERE user_id = $1)", customerID).Scan(&exists)
+	existing, err := s.defaultItems(customerID, store.ModeAlternating)
 	if err != nil {
 		http.Error(w, "Failed to check alternating default order", http.StatusInternalServerError)
 		return
 	}
-	if exists {
+	if len(orders.Latest(existing)) > 0 {
 		http.Error(w, "Alternating default order already exists for this user", http.StatusConflict)
 		return
 	}
+	from, override, ok := s.defaultsEffectiveFrom(w, r, customerID, "")
+	if !ok {
+		return
+	}
 
-	// Insert into alternating_default_order_items
+	anchor, ok := s.alternatingAnchor(w, customerID, true, "", from)
+	if !ok {
+		return
+	}
+
+	// Save the products and mark the user as alternating
+	items := make([]store.DefaultItem, 0, len(request.Products))
 	for _, item := range request.Products {
-		_, err := config.DB.Exec(
-			"INSERT INTO alternating_default_order_items (user_id, product_id, quantity, day_type) VALUES ($1, $2, $3, $4)",
-			customerID, item.ProductID, item.Quantity, item.DayType,
-		)
-		if err != nil {
-			http.Error(w, "Failed to insert alternating product", http.StatusInternalServerError)
+		rule := orders.DayTypeRule(store.ModeAlternating, anchor, item.DayType)
+		if rule == "" {
+			http.Error(w, "day_type must be EVEN or ODD", http.StatusBadRequest)
 			return
 		}
+		items = append(items, store.DefaultItem{ProductID: item.ProductID, Quantity: item.Quantity, Recurrence: rule})
 	}
-
-	// Update user to mark as alternating
-	_, err = config.DB.Exec("UPDATE users SET is_alternating_order = true WHERE user_id = $1", customerID)
-	if err != nil {
-		http.Error(w, "Failed to update user type", http.StatusInternalServerError)
+	if err := s.Orders.ReplaceDefaults(customerID, store.ModeAlternating, anchor, from, items); err != nil {
+		http.Error(w, "Failed to insert alternating product", http.StatusInternalServerError)
 		return
 	}
+	s.recordOverrides(w, override)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Alternating default order created successfully"})
@@ -738,13 +607,16 @@ func CreateAlternatingDefaultOrder(w http.ResponseWriter, r *http.Request) {
 // 	json.NewEncoder(w).Encode(map[string]string{"message": "Default order updated successfully!"})
 // }
 
-func UpdateDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UpdateDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	customerID := params["id"]
 
 	var request struct {
-		IsAlternating bool `json:"is_alternating_order"`
-		// Flexible structure to handle both types
+		IsAlternating bool   `json:"is_alternating_order"`
+		OrderMode     string `json:"order_mode"`         // optional, wins over is_alternating_order
+		Anchor        string `json:"alternating_anchor"` // optional YYYY-MM-DD
+		EffectiveFrom string `json:"effective_from"`     // optional YYYY-MM-DD, default today
+		// Flexible structure to handle every mode
 		Products []map[string]interface{} `json:"products"`
 	}
 
@@ -753,54 +625,53 @@ func UpdateDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request format", http.StatusBadRequest)
 		return
 	}
-
-	// Step 1: Delete both types of default order entries
-	_, err = config.DB.Exec("DELETE FROM default_order_items WHERE user_id = $1", customerID)
-	if err != nil {
-		http.Error(w, "Failed to delete normal default orders", http.StatusInternalServerError)
+	mode, ok := requestedMode(w, request.OrderMode, request.IsAlternating)
+	if !ok {
+		return
+	}
+	if !s.checkCustomerActive(w, customerID) {
 		return
 	}
 
-	_, err = config.DB.Exec("DELETE FROM alternating_default_order_items WHERE user_id = $1", customerID)
-	if err != nil {
-		http.Error(w, "Failed to delete alternating default orders", http.StatusInternalServerError)
+	from, override, ok := s.defaultsEffectiveFrom(w, r, customerID, request.EffectiveFrom)
+	if !ok {
+		return
+	}
+	anchor, ok := s.alternatingAnchor(w, customerID, mode == store.ModeAlternating, request.Anchor, from)
+	if !ok {
 		return
 	}
 
-	// Step 2: Insert into appropriate table based on flag
-	if request.IsAlternating {
-		for _, item := range request.Products {
-			_, err := config.DB.Exec(
-				"INSERT INTO alternating_default_order_items (user_id, product_id, quantity, day_type) VALUES ($1, $2, $3, $4)",
-				customerID, item["product_id"], item["quantity"], item["day_type"],
-			)
-			if err != nil {
-				http.Error(w, "Failed to insert alternating default order", http.StatusInternalServerError)
-				return
-			}
+	// Step 1: Read the products for the chosen type
+	items := make([]store.DefaultItem, 0, len(request.Products))
+	for _, item := range request.Products {
+		productID, _ := item["product_id"].(string)
+		quantity, _ := item["quantity"].(float64)
+		dayType, _ := item["day_type"].(string)
+		rule, ok := itemRecurrence(w, item["recurrence"])
+		if !ok {
+			return
 		}
-	} else {
-		for _, item := range request.Products {
-			_, err := config.DB.Exec(
-				"INSERT INTO default_order_items (user_id, product_id, quantity) VALUES ($1, $2, $3)",
-				customerID, item["product_id"], item["quantity"],
-			)
-			if err != nil {
-				http.Error(w, "Failed to insert default order", http.StatusInternalServerError)
-				return
-			}
+		if rule == "" && mode != store.ModeNormal {
+			rule = orders.DayTypeRule(mode, anchor, dayType)
 		}
+		if productID == "" || (mode != store.ModeNormal && rule == "") {
+			http.Error(w, "Invalid product entry in default order", http.StatusBadRequest)
+			return
+		}
+		items = append(items, store.DefaultItem{ProductID: productID, Quantity: quantity, Recurrence: rule})
 	}
 
-	// Step 3: Update user type
-	_, err = config.DB.Exec("UPDATE users SET is_alternating_order = $1 WHERE user_id = $2", request.IsAlternating, customerID)
-	if err != nil {
-		http.Error(w, "Failed to update user type", http.StatusInternalServerError)
+	// Step 2: Replace every kind of default order from the effective day and update the user mode
+	if err := s.Orders.ReplaceDefaults(customerID, mode, anchor, from, items); err != nil {
+		log.Printf("Error updating default order: %v\n", err)
+		http.Error(w, "Failed to update default order", http.StatusInternalServerError)
 		return
 	}
+	s.recordOverrides(w, override)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Default order updated successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "Default order updated successfully", "effective_from": from.String()})
 }
 
 // func GetDefaultOrder(w http.ResponseWriter, r *http.Request) {
@@ -859,88 +730,58 @@ func UpdateDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
 // }
 
 
-func GetDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	customerID := params["id"]
 
-	var isAlternating bool
-	err := config.DB.QueryRow("SELECT is_alternating_order FROM users WHERE user_id = $1", customerID).Scan(&isAlternating)
+	customer, err := s.Customers.GetCustomer(customerID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to check user type", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	mode := orders.Mode(*customer)
+	items, err := s.defaultItems(customerID, mode)
+	if err != nil {
+		http.Error(w, "Failed to fetch default order", http.StatusInternalServerError)
+		return
+	}
 
-	if isAlternating {
-		// Fetch alternating default order
-		rows, err := config.DB.Query(`
-			SELECT product_id, quantity, day_type
-			FROM alternating_default_order_items
-			WHERE user_id = $1
-		`, customerID)
-		if err != nil {
-			http.Error(w, "Failed to fetch alternating default order", http.StatusInternalServerError)
-			return
+	var products []map[string]interface{}
+	var effectiveFrom civil.Date
+	for _, it := range orders.Latest(items) {
+		effectiveFrom = it.EffectiveFrom
+		product := map[string]interface{}{
+			"product_id": it.ProductID,
+			"quantity":   it.Quantity,
 		}
-		defer rows.Close()
-
-		var products []map[string]interface{}
-		for rows.Next() {
-			var productID string
-			var quantity float64
-			var dayType string
-			err := rows.Scan(&productID, &quantity, &dayType)
-			if err != nil {
-				http.Error(w, "Error scanning alternating default order", http.StatusInternalServerError)
-				return
-			}
-			products = append(products, map[string]interface{}{
-				"product_id": productID,
-				"quantity":   quantity,
-				"day_type":   dayType,
-			})
-		}
-
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"user_id":             customerID,
-			"is_alternating_order": true,
-			"products":            products,
-		})
-	} else {
-		// Fetch normal default order
-		rows, err := config.DB.Query(`
-			SELECT product_id, quantity
-			FROM default_order_items
-			WHERE user_id = $1
-		`, customerID)
-		if err != nil {
-			http.Error(w, "Failed to fetch default order", http.StatusInternalServerError)
-			return
+		if dayType := orders.DayTypeOf(*customer, it); dayType != "" {
+			product["day_type"] = dayType
 		}
-		defer rows.Close()
-
-		var products []map[string]interface{}
-		for rows.Next() {
-			var productID string
-			var quantity float64
-			err := rows.Scan(&productID, &quantity)
-			if err != nil {
-				http.Error(w, "Error scanning default order", http.StatusInternalServerError)
-				return
-			}
-			products = append(products, map[string]interface{}{
-				"product_id": productID,
-				"quantity":   quantity,
-			})
+		if it.Recurrence != "" {
+			product["recurrence"] = it.Recurrence
 		}
+		products = append(products, product)
+	}
 
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"user_id":             customerID,
-			"is_alternating_order": false,
-			"products":            products,
-		})
+	resp := map[string]interface{}{
+		"user_id":              customerID,
+		"is_alternating_order": customer.IsAlternatingOrder,
+		"order_mode":           mode,
+		"products":             products,
+	}
+	if mode == store.ModeAlternating {
+		resp["alternating_anchor"] = orders.Anchor(*customer).String()
+	}
+	if !effectiveFrom.IsZero() {
+		resp["effective_from"] = effectiveFrom.String()
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 
@@ -1099,3 +940,171 @@ func GetDefaultOrderUnified(w http.ResponseWriter, r *http.Request) {
 // 	w.Header().Set("Content-Type", "application/json")
 // 	json.NewEncoder(w).Encode(response)
 // }
+
+// defaultItems returns the customer's default items, or none when the
+// customer's default order is not of the given mode.
+func (s *Server) defaultItems(userID string, mode string) ([]store.DefaultItem, error) {
+	customer, err := s.Customers.GetCustomer(userID)
+	if err != nil {
+		return nil, err
+	}
+	if orders.Mode(*customer) != mode {
+		return nil, nil
+	}
+	return s.Orders.Defaults([]string{userID})
+}
+
+// alternatingAnchor picks the day the ODD/EVEN items of an alternating
+// default order taking effect on from count from: the requested date, else
+// orders.NewAnchor. It answers the request and returns false when the date is
+// invalid or the customer is unknown.
+func (s *Server) alternatingAnchor(w http.ResponseWriter, customerID string, alternating bool, requested string, from civil.Date) (civil.Date, bool) {
+	if !alternating {
+		return civil.Date{}, true
+	}
+	if requested != "" {
+		anchor, err := civil.Parse(requested)
+		if err != nil {
+			http.Error(w, "Invalid alternating_anchor, expected YYYY-MM-DD", http.StatusBadRequest)
+			return civil.Date{}, false
+		}
+		return anchor, true
+	}
+
+	customer, err := s.Customers.GetCustomer(customerID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return civil.Date{}, false
+	}
+	if err != nil {
+		log.Printf("Error fetching customer %s: %v\n", customerID, err)
+		http.Error(w, "Failed to check user type", http.StatusInternalServerError)
+		return civil.Date{}, false
+	}
+	return orders.NewAnchor(*customer, from), true
+}
+
+// checkCustomerActive answers 404 for an unknown customer and 409 for an
+// archived one, whose default order can no longer change, and returns false
+// in both cases.
+func (s *Server) checkCustomerActive(w http.ResponseWriter, customerID string) bool {
+	customer, err := s.Customers.GetCustomer(customerID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return false
+	case err != nil:
+		log.Printf("Error fetching customer %s: %v\n", customerID, err)
+		http.Error(w, "Failed to fetch customer", http.StatusInternalServerError)
+		return false
+	case customer.Archived:
+		http.Error(w, "Customer is archived", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// defaultsEffectiveFrom picks the day a default order change takes effect:
+// the requested date, else the first day the order cut-off leaves open.
+// Earlier days keep the default order they had. A change reaching back past
+// the cut-off goes through checkCutoff like any other late change, and the
+// override it returns is for recordOverrides once the change is saved. It
+// answers the request and returns false when the date is invalid, the
+// cut-off refuses it, or a finalized invoice covers it or any later day.
+func (s *Server) defaultsEffectiveFrom(w http.ResponseWriter, r *http.Request, customerID string, requested string) (civil.Date, *store.CutoffOverride, bool) {
+	now := s.now()
+	from := s.today()
+	if s.Cutoff.Enabled {
+		from = s.Cutoff.FirstOpenDay(now)
+	}
+	if requested != "" {
+		var err error
+		if from, err = civil.Parse(requested); err != nil {
+			http.Error(w, "Invalid effective_from, expected YYYY-MM-DD", http.StatusBadRequest)
+			return civil.Date{}, nil, false
+		}
+	}
+
+	// The late days are those from effective_from up to the cut-off
+	lastClosed := s.Cutoff.FirstOpenDay(now).AddDays(-1)
+	if lastClosed.Before(from) {
+		lastClosed = from
+	}
+	override, ok := s.checkCutoff(w, r, customerID, from, lastClosed)
+	if !ok || !s.checkOrdersUnlocked(w, customerID, from, civil.Date{}) {
+		return civil.Date{}, nil, false
+	}
+	return from, override, true
+}
+
+// requestedMode reads the mode of a default order request. order_mode wins;
+// without it is_alternating_order picks alternating or normal, as before
+// weekly orders existed.
+func requestedMode(w http.ResponseWriter, mode string, isAlternating bool) (string, bool) {
+	switch {
+	case mode == "" && isAlternating:
+		return store.ModeAlternating, true
+	case mode == "":
+		return store.ModeNormal, true
+	case !store.ValidMode(mode):
+		http.Error(w, "order_mode must be normal, alternating or weekly", http.StatusBadRequest)
+		return "", false
+	}
+	return mode, true
+}
+
+// itemRecurrence reads the optional recurrence of a default item and returns
+// it in stored RRULE form. It accepts an RRULE string or one of
+// {"every_days": 3, "anchor": "2025-03-01"}, {"month_days": [1, 15]} and
+// {"rrule": "FREQ=..."}. In alternating and weekly orders it takes the place
+// of the item's day_type.
+func itemRecurrence(w http.ResponseWriter, v interface{}) (string, bool) {
+	if v == nil {
+		return "", true
+	}
+
+	var rule *recurrence.Rule
+	var err error
+	switch spec := v.(type) {
+	case string:
+		rule, err = recurrence.Parse(spec)
+	case map[string]interface{}:
+		rule, err = recurrenceFromSpec(spec)
+	default:
+		err = fmt.Errorf("recurrence must be an RRULE string or an object")
+	}
+	if err != nil {
+		http.Error(w, "Invalid recurrence: "+err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return rule.String(), true
+}
+
+func recurrenceFromSpec(spec map[string]interface{}) (*recurrence.Rule, error) {
+	if text, ok := spec["rrule"].(string); ok {
+		return recurrence.Parse(text)
+	}
+	if n, ok := spec["every_days"].(float64); ok {
+		anchorText, _ := spec["anchor"].(string)
+		anchor, err := civil.Parse(anchorText)
+		if err != nil {
+			return nil, fmt.Errorf("every_days needs an anchor date (YYYY-MM-DD)")
+		}
+		if n < 1 || n != float64(int(n)) {
+			return nil, fmt.Errorf("every_days must be a positive whole number")
+		}
+		return recurrence.EveryDays(int(n), anchor), nil
+	}
+	if list, ok := spec["month_days"].([]interface{}); ok && len(list) > 0 {
+		days := make([]int, 0, len(list))
+		for _, d := range list {
+			n, ok := d.(float64)
+			if !ok || n != float64(int(n)) || n == 0 || n < -31 || n > 31 {
+				return nil, fmt.Errorf("month_days must be days 1..31, or -1 for the last day")
+			}
+			days = append(days, int(n))
+		}
+		return recurrence.MonthDays(days...), nil
+	}
+	return nil, fmt.Errorf("expected rrule, every_days or month_days")
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"backend/auth"
+	"backend/civil"
+	"backend/config"
+	"backend/cutoff"
+	"backend/store"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// checkOrderChange applies the order cut-off and then the invoice lock to a
+// change of the customer's deliveries from start to end. A change after the
+// cut-off goes through when the request carries an override_reason query
+// parameter, or when the cut-off only flags late changes. It then returns the
+// override, which the caller hands to recordOverrides once the change is
+// saved; nil means the change was in time.
+func (s *Server) checkOrderChange(w http.ResponseWriter, r *http.Request, userID string, start, end civil.Date) (*store.CutoffOverride, bool) {
+	o, ok := s.checkCutoff(w, r, userID, start, end)
+	if !ok || !s.checkOrdersUnlocked(w, userID, start, end) {
+		return nil, false
+	}
+	return o, true
+}
+
+func (s *Server) checkCutoff(w http.ResponseWriter, r *http.Request, userID string, start, end civil.Date) (*store.CutoffOverride, bool) {
+	now := s.now()
+	if !s.Cutoff.Closed(start, now) {
+		return nil, true
+	}
+
+	reason := strings.TrimSpace(r.URL.Query().Get("override_reason"))
+	forced := reason != ""
+	if !forced && s.Cutoff.Mode != cutoff.Flag {
+		first := s.Cutoff.FirstOpenDay(now)
+		http.Error(w, fmt.Sprintf("Changes before %s are closed (cut-off %s); send override_reason to force",
+			first.String(), s.Cutoff), http.StatusConflict)
+		return nil, false
+	}
+
+	o := store.CutoffOverride{
+		UserID:    userID,
+		StartDate: start,
+		EndDate:   end,
+		Action:    r.Method + " " + r.URL.Path,
+		Reason:    reason,
+		Forced:    forced,
+	}
+	if claims := auth.FromContext(r.Context()); claims != nil {
+		o.AdminID = claims.AdminID()
+	}
+	return &o, true
+}
+
+// recordOverrides stores the cut-off overrides of a saved change and marks
+// the response with an X-Order-Cutoff header. The change stands either way,
+// so a failure to record is only logged.
+func (s *Server) recordOverrides(w http.ResponseWriter, overrides ...*store.CutoffOverride) {
+	for _, o := range overrides {
+		if o == nil {
+			continue
+		}
+		if err := s.Orders.RecordCutoffOverride(*o); err != nil {
+			log.Printf("Error recording cut-off override of %s: %v\n", o.UserID, err)
+		}
+		if o.Forced {
+			w.Header().Set("X-Order-Cutoff", "overridden")
+		} else if w.Header().Get("X-Order-Cutoff") == "" {
+			w.Header().Set("X-Order-Cutoff", "flagged")
+		}
+	}
+}
+
+// GetCutoffOverrides lists changes made after the cut-off between from and to
+// (YYYY-MM-DD, inclusive), by default over the last 7 days.
+func (s *Server) GetCutoffOverrides(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, ok := optionalDate(w, q.Get("from"), "from")
+	if !ok {
+		return
+	}
+	to, ok := optionalDate(w, q.Get("to"), "to")
+	if !ok {
+		return
+	}
+	if to.IsZero() {
+		to = s.today()
+	}
+	if from.IsZero() {
+		from = to.AddDays(-7)
+	}
+
+	list, err := s.Orders.CutoffOverrides(from.In(config.Location), to.AddDays(1).In(config.Location))
+	if err != nil {
+		log.Printf("Error listing cut-off overrides: %v\n", err)
+		http.Error(w, "Failed to fetch cut-off overrides", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]map[string]interface{}, 0, len(list))
+	for _, o := range list {
+		resp = append(resp, map[string]interface{}{
+			"override_id": o.OverrideID,
+			"admin_id":    o.AdminID,
+			"user_id":     o.UserID,
+			"start_date":  o.StartDate.String(),
+			"end_date":    o.EndDate.String(),
+			"action":      o.Action,
+			"reason":      o.Reason,
+			"forced":      o.Forced,
+			"created_at":  o.CreatedAt,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cutoff":    s.Cutoff.String(),
+		"mode":      s.Cutoff.Mode,
+		"overrides": resp,
+	})
+}
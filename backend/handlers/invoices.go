@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"backend/auth"
+	"backend/civil"
+	"backend/invoices"
+	"backend/orders"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GenerateInvoice stores (or refreshes) the draft invoice of a customer for a month.
+func (s *Server) GenerateInvoice(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CustomerID string `json:"customer_id"`
+		Month      int    `json:"month"`
+		Year       int    `json:"year"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.CustomerID == "" || req.Month < 1 || req.Month > 12 || req.Year == 0 {
+		http.Error(w, "customer_id, month and year are required", http.StatusBadRequest)
+		return
+	}
+
+	inv, err := invoices.Generate(s.Stores, req.CustomerID, req.Year, time.Month(req.Month))
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(inv)
+}
+
+// GetInvoices lists invoices, optionally filtered by customer_id, month and year.
+func (s *Server) GetInvoices(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	month, _ := strconv.Atoi(q.Get("month"))
+	year, _ := strconv.Atoi(q.Get("year"))
+
+	list, err := invoices.List(s.Stores, q.Get("customer_id"), year, month)
+	if err != nil {
+		log.Printf("Error listing invoices: %v\n", err)
+		http.Error(w, "Failed to fetch invoices", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetInvoice returns one invoice with its lines.
+func (s *Server) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	inv, err := invoices.Get(s.Stores, mux.Vars(r)["id"])
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inv)
+}
+
+// FinalizeInvoice freezes a draft invoice and locks the customer's month.
+func (s *Server) FinalizeInvoice(w http.ResponseWriter, r *http.Request) {
+	claims := auth.FromContext(r.Context())
+	if err := invoices.Finalize(s.Stores, mux.Vars(r)["id"], claims.AdminID()); err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Invoice finalized successfully"})
+}
+
+// ReopenInvoice turns a finalized invoice back into a draft.
+func (s *Server) ReopenInvoice(w http.ResponseWriter, r *http.Request) {
+	if err := invoices.Reopen(s.Stores, mux.Vars(r)["id"]); err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Invoice reopened successfully"})
+}
+
+// GetInvoiceDiff compares a stored invoice with a fresh computation.
+func (s *Server) GetInvoiceDiff(w http.ResponseWriter, r *http.Request) {
+	diff, err := invoices.Compare(s.Stores, mux.Vars(r)["id"])
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+func writeInvoiceError(w http.ResponseWriter, err error) {
+	switch err {
+	case invoices.ErrNotFound:
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+	case orders.ErrUnknownUser:
+		http.Error(w, "Customer not found", http.StatusNotFound)
+	case invoices.ErrFinalized:
+		http.Error(w, "Invoice is finalized, reopen it first", http.StatusConflict)
+	case invoices.ErrNotFinalized:
+		http.Error(w, "Invoice is not finalized", http.StatusConflict)
+	default:
+		log.Printf("Invoice error: %v\n", err)
+		http.Error(w, "Failed to process invoice", http.StatusInternalServerError)
+	}
+}
+
+// checkOrdersUnlocked answers 409 and returns false when a finalized invoice
+// covers part of the date range the request wants to modify.
+func (s *Server) checkOrdersUnlocked(w http.ResponseWriter, userID string, start, end civil.Date) bool {
+	switch err := invoices.CheckUnlocked(s.Stores, userID, start, end); err {
+	case nil:
+		return true
+	case invoices.ErrLocked:
+		http.Error(w, "A finalized invoice covers these dates, reopen it first", http.StatusConflict)
+	default:
+		log.Printf("Error checking invoice lock: %v\n", err)
+		http.Error(w, "Failed to check invoice lock", http.StatusInternalServerError)
+	}
+	return false
+}
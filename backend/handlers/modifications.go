@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"backend/civil"
+	"backend/store"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// modificationBatch is one ModifyOrder/PauseOrder/ResumeOrder/
+// ModifyAlternatingOrder call as the frontend sees it.
+type modificationBatch struct {
+	OrderID          string                   `json:"order_id"`
+	UserID           string                   `json:"user_id"`
+	StartDate        string                   `json:"start_date"`
+	EndDate          string                   `json:"end_date"`
+	ModificationType string                   `json:"modification_type"`
+	Alternating      bool                     `json:"alternating"`
+	CreatedAt        time.Time                `json:"created_at"`
+	Products         []map[string]interface{} `json:"products"`
+
+	start, end civil.Date
+}
+
+// groupBatches folds rows into batches by order_id, keeping the order the
+// rows came in.
+func groupBatches(mods []store.Modification) []*modificationBatch {
+	batches := []*modificationBatch{}
+	byOrder := make(map[string]*modificationBatch)
+	for _, m := range mods {
+		b, ok := byOrder[m.OrderID]
+		if !ok {
+			kind := m.Kind
+			if kind == "" {
+				kind = store.KindReplace
+			}
+			b = &modificationBatch{
+				OrderID:          m.OrderID,
+				UserID:           m.UserID,
+				StartDate:        m.StartDate.String(),
+				EndDate:          m.EndDate.String(),
+				ModificationType: kind,
+				Alternating:      m.Alternating,
+				CreatedAt:        m.CreatedAt,
+				start:            m.StartDate,
+				end:              m.EndDate,
+			}
+			byOrder[m.OrderID] = b
+			batches = append(batches, b)
+		}
+		if m.CreatedAt.After(b.CreatedAt) {
+			b.CreatedAt = m.CreatedAt
+		}
+		product := map[string]interface{}{
+			"product_id": m.ProductID,
+			"quantity":   m.Quantity,
+		}
+		if m.Alternating {
+			product["day_type"] = m.DayType
+		}
+		b.Products = append(b.Products, product)
+	}
+	return batches
+}
+
+// GetCustomerModifications lists a customer's modification batches, newest first.
+func (s *Server) GetCustomerModifications(w http.ResponseWriter, r *http.Request) {
+	customerID := mux.Vars(r)["id"]
+	if _, err := s.Customers.GetCustomer(customerID); errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		writeModificationError(w, err)
+		return
+	}
+
+	mods, err := s.Orders.CustomerModifications(customerID)
+	if err != nil {
+		writeModificationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groupBatches(mods))
+}
+
+// DeleteModification cancels one modification batch, so its days fall back
+// to whatever they resolved to before it was written.
+func (s *Server) DeleteModification(w http.ResponseWriter, r *http.Request) {
+	mods, err := s.Orders.ModificationBatch(mux.Vars(r)["order_id"])
+	if err != nil {
+		writeModificationError(w, err)
+		return
+	}
+	s.removeBatches(w, r, groupBatches(mods), "Modification deleted successfully")
+}
+
+// UndoLastModification cancels the customer's most recent change: the newest
+// batch, together with the batches written by the same call (a resume of a
+// weekly order writes one per day). The client may send the order_id it
+// believes is the latest; if another change was made meanwhile the undo is
+// refused instead of removing it.
+func (s *Server) UndoLastModification(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrderID string `json:"order_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	customerID := mux.Vars(r)["id"]
+	if _, err := s.Customers.GetCustomer(customerID); errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		writeModificationError(w, err)
+		return
+	}
+	mods, err := s.Orders.CustomerModifications(customerID)
+	if err != nil {
+		writeModificationError(w, err)
+		return
+	}
+	batches := groupBatches(mods)
+	if len(batches) == 0 {
+		http.Error(w, "Nothing to undo", http.StatusNotFound)
+		return
+	}
+	last := batches[:1]
+	for _, b := range batches[1:] {
+		if b.CreatedAt.Equal(last[0].CreatedAt) {
+			last = append(last, b)
+		}
+	}
+	expected := req.OrderID == ""
+	for _, b := range last {
+		expected = expected || b.OrderID == req.OrderID
+	}
+	if !expected {
+		http.Error(w, "The order was changed again since, reload before undoing", http.StatusConflict)
+		return
+	}
+	s.removeBatches(w, r, last, "Last change undone successfully")
+}
+
+// removeBatches deletes the batches unless the order cut-off or a finalized
+// invoice covers some of their dates, and answers with the removed batches.
+func (s *Server) removeBatches(w http.ResponseWriter, r *http.Request, batches []*modificationBatch, message string) {
+	orderIDs := make([]string, 0, len(batches))
+	overrides := make([]*store.CutoffOverride, 0, len(batches))
+	for _, b := range batches {
+		override, ok := s.checkOrderChange(w, r, b.UserID, b.start, b.end)
+		if !ok {
+			return
+		}
+		orderIDs = append(orderIDs, b.OrderID)
+		overrides = append(overrides, override)
+	}
+	if err := s.Orders.DeleteModificationBatches(orderIDs...); err != nil {
+		writeModificationError(w, err)
+		return
+	}
+	s.recordOverrides(w, overrides...)
+	for _, b := range batches {
+		log.Printf("Removed modification batch %s of %s (%s to %s)\n", b.OrderID, b.UserID, b.StartDate, b.EndDate)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       message,
+		"modifications": batches,
+	})
+}
+
+func writeModificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Modification not found", http.StatusNotFound)
+	default:
+		log.Printf("Modification error: %v\n", err)
+		http.Error(w, "Failed to process modification", http.StatusInternalServerError)
+	}
+}
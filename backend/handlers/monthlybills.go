@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"backend/billing"
+	"backend/orders"
+	"backend/pricing"
+	"backend/store"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// billSummary is one customer's line in a bulk bill run.
+type billSummary struct {
+	CustomerID    string  `json:"customer_id"`
+	Name          string  `json:"name"`
+	ApartmentID   string  `json:"apartment_id"`
+	ApartmentName string  `json:"apartment_name"`
+	RoomNumber    string  `json:"room_number"`
+	DaysDelivered int     `json:"days_delivered"`
+	Total         float64 `json:"total"`
+
+	archived bool
+}
+
+// GetApartmentMonthlyBills bills every customer of an apartment for a month.
+// Add ?format=csv for a spreadsheet instead of JSON.
+func (s *Server) GetApartmentMonthlyBills(w http.ResponseWriter, r *http.Request) {
+	aptID := mux.Vars(r)["id"]
+	apt, err := s.Apartments.GetApartment(aptID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Apartment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching apartment %s: %v\n", aptID, err)
+		http.Error(w, "Failed to fetch apartment", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeMonthlyBills(w, r, aptID, "bills-"+apt.ApartmentName)
+}
+
+// GetAllMonthlyBills bills every customer of every apartment for a month.
+// Add ?format=csv for a spreadsheet instead of JSON.
+func (s *Server) GetAllMonthlyBills(w http.ResponseWriter, r *http.Request) {
+	s.writeMonthlyBills(w, r, "", "bills-all")
+}
+
+// writeMonthlyBills bills the customers of one apartment, or everyone when
+// aptID is empty, with the same plan and prices GetMonthlyBill uses.
+func (s *Server) writeMonthlyBills(w http.ResponseWriter, r *http.Request, aptID, filename string) {
+	q := r.URL.Query()
+	month, year := q.Get("month"), q.Get("year")
+	if month == "" || year == "" {
+		http.Error(w, "Missing required parameters", http.StatusBadRequest)
+		return
+	}
+	startDate, err := monthStart(year, month)
+	if err != nil {
+		http.Error(w, "Invalid month or year", http.StatusBadRequest)
+		return
+	}
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	// 1) Customers in apartment and delivery order, archived ones included
+	// since they may have been delivered to that month
+	apartments, err := s.Apartments.ListApartments("", true)
+	if err != nil {
+		log.Printf("Error fetching apartments: %v\n", err)
+		http.Error(w, "Failed to fetch apartments", http.StatusInternalServerError)
+		return
+	}
+	aptNames := make(map[string]string, len(apartments))
+	for _, a := range apartments {
+		aptNames[a.ApartmentID] = a.ApartmentName
+	}
+	users, err := s.Customers.ListCustomers(store.CustomerFilter{ApartmentID: aptID, IncludeArchived: true})
+	if err != nil {
+		log.Printf("Error fetching users: %v\n", err)
+		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		return
+	}
+
+	summaries := make([]billSummary, 0, len(users))
+	for _, u := range users {
+		aptName, ok := aptNames[u.ApartmentID]
+		if !ok {
+			continue
+		}
+		summaries = append(summaries, billSummary{
+			CustomerID:    u.UserID,
+			Name:          u.Name,
+			ApartmentID:   u.ApartmentID,
+			ApartmentName: aptName,
+			RoomNumber:    u.RoomNumber,
+			archived:      u.Archived,
+		})
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].ApartmentName < summaries[j].ApartmentName
+	})
+
+	// 2) One plan and one price book for the whole run
+	start, end := billing.MonthRange(startDate.Year, startDate.Month)
+	var plan *orders.Plan
+	if aptID != "" {
+		plan, err = orders.LoadApartment(s.Stores, aptID, start, end)
+	} else {
+		plan, err = orders.LoadAll(s.Stores, start, end)
+	}
+	if err != nil {
+		log.Printf("Error resolving orders: %v\n", err)
+		http.Error(w, "Failed to resolve orders", http.StatusInternalServerError)
+		return
+	}
+	prices, err := pricing.Load(s.Prices)
+	if err != nil {
+		log.Printf("Error loading prices: %v\n", err)
+		http.Error(w, "Failed to load prices", http.StatusInternalServerError)
+		return
+	}
+
+	// 3) Bill each customer exactly as GetMonthlyBill would, leaving out
+	// archived customers who got nothing that month
+	grandTotal := 0.0
+	billed := summaries[:0]
+	for _, b := range summaries {
+		bill := billing.FromPlan(plan, prices, b.CustomerID, startDate.Year, startDate.Month)
+		b.DaysDelivered = bill.DaysDelivered()
+		b.Total = bill.Total
+		if b.archived && b.DaysDelivered == 0 {
+			continue
+		}
+		grandTotal += bill.Total
+		billed = append(billed, b)
+	}
+	summaries = billed
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%s.csv", filename, startDate.Format("2006-01"))))
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"customer_id", "name", "apartment", "room_number", "days_delivered", "total"})
+		for _, b := range summaries {
+			cw.Write([]string{
+				b.CustomerID, b.Name, b.ApartmentName, b.RoomNumber,
+				strconv.Itoa(b.DaysDelivered),
+				strconv.FormatFloat(math.Round(b.Total*100)/100, 'f', 2, 64),
+			})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			log.Printf("Error writing bills CSV: %v\n", err)
+		}
+		return
+	}
+
+	resp := map[string]interface{}{
+		"apartment_id": aptID,
+		"month":        month,
+		"year":         year,
+		"total_bill":   grandTotal,
+		"customers":    summaries,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"backend/auth"
+	"backend/civil"
+	"backend/models"
+	"backend/orders"
+	"backend/payments"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// RecordPayment stores a payment received from a customer.
+func (s *Server) RecordPayment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CustomerID  string  `json:"customer_id"`
+		InvoiceID   string  `json:"invoice_id"`
+		Amount      float64 `json:"amount"`
+		PaymentDate string  `json:"payment_date"`
+		Mode        string  `json:"mode"`
+		Reference   string  `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	// An invalid date stays zero, which Record reports as invalid
+	date, _ := civil.Parse(req.PaymentDate)
+	payment, err := payments.Record(s.Stores, models.Payment{
+		UserID:      req.CustomerID,
+		InvoiceID:   req.InvoiceID,
+		Amount:      req.Amount,
+		PaymentDate: date,
+		Mode:        req.Mode,
+		Reference:   req.Reference,
+		RecordedBy:  auth.FromContext(r.Context()).AdminID(),
+	})
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payment)
+}
+
+// GetPayments lists payments, optionally filtered by customer_id and a
+// from/to date range (YYYY-MM-DD).
+func (s *Server) GetPayments(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, ok := optionalDate(w, q.Get("from"), "from")
+	if !ok {
+		return
+	}
+	to, ok := optionalDate(w, q.Get("to"), "to")
+	if !ok {
+		return
+	}
+
+	list, err := payments.List(s.Stores, q.Get("customer_id"), from, to)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// DeletePayment removes a payment recorded by mistake.
+func (s *Server) DeletePayment(w http.ResponseWriter, r *http.Request) {
+	if err := payments.Delete(s.Stores, mux.Vars(r)["id"]); err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Payment deleted successfully"})
+}
+
+// GetCustomerBalance returns what a customer owes, as of today or ?as_of=YYYY-MM-DD.
+func (s *Server) GetCustomerBalance(w http.ResponseWriter, r *http.Request) {
+	asOf, ok := optionalDate(w, r.URL.Query().Get("as_of"), "as_of")
+	if !ok {
+		return
+	}
+	if asOf.IsZero() {
+		asOf = s.today()
+	}
+
+	balance, err := payments.GetBalance(s.Stores, mux.Vars(r)["id"], asOf)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balance)
+}
+
+// GetCustomerStatement returns the month-by-month statement of a customer.
+// ?from= and ?to= take YYYY-MM; they default to the customer's first month
+// and the current month.
+func (s *Server) GetCustomerStatement(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var from civil.Date
+	to := s.today()
+	var err error
+	if v := q.Get("from"); v != "" {
+		if from, err = civil.Parse(v + "-01"); err != nil {
+			http.Error(w, "Invalid from, expected YYYY-MM", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = civil.Parse(v + "-01"); err != nil {
+			http.Error(w, "Invalid to, expected YYYY-MM", http.StatusBadRequest)
+			return
+		}
+	}
+
+	statement, err := payments.GetStatement(s.Stores, mux.Vars(r)["id"], from, to)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statement)
+}
+
+func writePaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, payments.ErrInvalid):
+		http.Error(w, strings.TrimPrefix(err.Error(), payments.ErrInvalid.Error()+": "), http.StatusBadRequest)
+	case err == payments.ErrNotFound:
+		http.Error(w, "Payment not found", http.StatusNotFound)
+	case err == orders.ErrUnknownUser:
+		http.Error(w, "Customer not found", http.StatusNotFound)
+	default:
+		log.Printf("Payment error: %v\n", err)
+		http.Error(w, "Failed to process payment", http.StatusInternalServerError)
+	}
+}
+
+// optionalDate parses an optional YYYY-MM-DD query value. It answers 400 and
+// returns false when the value is malformed.
+func optionalDate(w http.ResponseWriter, value, name string) (civil.Date, bool) {
+	if value == "" {
+		return civil.Date{}, true
+	}
+	t, err := civil.Parse(value)
+	if err != nil {
+		http.Error(w, "Invalid "+name+", expected YYYY-MM-DD", http.StatusBadRequest)
+		return civil.Date{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"backend/civil"
+	"backend/invoices"
+	"backend/pricing"
+	"backend/store"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// priceListRequest is the body of the create endpoint. Exactly one of
+// apartment_id and user_id is set; an empty effective_to never ends.
+type priceListRequest struct {
+	ProductID     string   `json:"product_id"`
+	ApartmentID   string   `json:"apartment_id"`
+	UserID        string   `json:"user_id"`
+	Price         *float64 `json:"price"`
+	EffectiveFrom string   `json:"effective_from"`
+	EffectiveTo   string   `json:"effective_to"`
+}
+
+func priceListJSON(e store.PriceListEntry) map[string]interface{} {
+	var effectiveTo interface{}
+	if !e.EffectiveTo.IsZero() {
+		effectiveTo = e.EffectiveTo.String()
+	}
+	return map[string]interface{}{
+		"entry_id":       e.EntryID,
+		"product_id":     e.ProductID,
+		"apartment_id":   e.ApartmentID,
+		"user_id":        e.UserID,
+		"price":          e.Price,
+		"effective_from": e.EffectiveFrom.String(),
+		"effective_to":   effectiveTo,
+		"created_at":     e.CreatedAt,
+	}
+}
+
+// GetPriceList lists the price list entries of an apartment and/or a
+// customer given as apartment_id and user_id, or every entry.
+func (s *Server) GetPriceList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	list, err := s.Prices.PriceListEntries(q.Get("apartment_id"), q.Get("user_id"))
+	if err != nil {
+		writePriceListError(w, err)
+		return
+	}
+
+	resp := make([]map[string]interface{}, 0, len(list))
+	for _, e := range list {
+		resp = append(resp, priceListJSON(e))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CreatePriceListEntry gives an apartment or a single customer its own price
+// for a product over a date range.
+func (s *Server) CreatePriceListEntry(w http.ResponseWriter, r *http.Request) {
+	var req priceListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	// 1) Validate the body
+	errs := fieldErrors{}
+	known, err := s.knownProducts()
+	if err != nil {
+		writePriceListError(w, err)
+		return
+	}
+	if !known[req.ProductID] {
+		errs.add("product_id", "unknown product")
+	}
+	switch {
+	case (req.ApartmentID == "") == (req.UserID == ""):
+		errs.add("apartment_id", "set exactly one of apartment_id and user_id")
+	case req.ApartmentID != "":
+		if _, err := s.Apartments.GetApartment(req.ApartmentID); errors.Is(err, store.ErrNotFound) {
+			errs.add("apartment_id", "unknown apartment")
+		} else if err != nil {
+			writePriceListError(w, err)
+			return
+		}
+	default:
+		if _, err := s.Customers.GetCustomer(req.UserID); errors.Is(err, store.ErrNotFound) {
+			errs.add("user_id", "unknown customer")
+		} else if err != nil {
+			writePriceListError(w, err)
+			return
+		}
+	}
+	switch {
+	case req.Price == nil:
+		errs.add("price", "required")
+	case *req.Price < 0:
+		errs.add("price", "must not be negative")
+	}
+	from, err := civil.Parse(req.EffectiveFrom)
+	if err != nil {
+		errs.add("effective_from", "expected YYYY-MM-DD")
+	}
+	var to civil.Date
+	if req.EffectiveTo != "" {
+		if to, err = civil.Parse(req.EffectiveTo); err != nil {
+			errs.add("effective_to", "expected YYYY-MM-DD")
+		} else if to.Before(from) {
+			errs.add("effective_to", "must not be before effective_from")
+		}
+	}
+	if errs.write(w) {
+		return
+	}
+
+	// 2) Refuse prices that would alter a finalized invoice
+	e := store.PriceListEntry{
+		ProductID:     req.ProductID,
+		ApartmentID:   req.ApartmentID,
+		UserID:        req.UserID,
+		Price:         *req.Price,
+		EffectiveFrom: from,
+		EffectiveTo:   to,
+	}
+	if !s.checkPriceListUnlocked(w, e) {
+		return
+	}
+
+	// 3) Store the entry
+	id, err := s.Prices.CreatePriceListEntry(e)
+	if err != nil {
+		writePriceListError(w, err)
+		return
+	}
+	e.EntryID = id
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(priceListJSON(e))
+}
+
+// DeletePriceListEntry removes an entry; its days go back to the next
+// price in line.
+func (s *Server) DeletePriceListEntry(w http.ResponseWriter, r *http.Request) {
+	e, err := s.Prices.GetPriceListEntry(mux.Vars(r)["id"])
+	if err != nil {
+		writePriceListError(w, err)
+		return
+	}
+	if !s.checkPriceListUnlocked(w, *e) {
+		return
+	}
+	if err := s.Prices.DeletePriceListEntry(e.EntryID); err != nil {
+		writePriceListError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Price list entry deleted successfully"})
+}
+
+// GetPriceMatrix shows the price of every product on date (default today):
+// the base price, what each apartment pays, and every customer with a price
+// of their own.
+func (s *Server) GetPriceMatrix(w http.ResponseWriter, r *http.Request) {
+	date := s.today()
+	if v := r.URL.Query().Get("date"); v != "" {
+		var err error
+		if date, err = civil.Parse(v); err != nil {
+			http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 1) Products, apartments, customers and every price
+	products, err := s.Products.ListProducts(false)
+	if err != nil {
+		writePriceListError(w, err)
+		return
+	}
+	apartments, err := s.Apartments.ListApartments("", false)
+	if err != nil {
+		writePriceListError(w, err)
+		return
+	}
+	customers, err := s.Customers.ListCustomers(store.CustomerFilter{})
+	if err != nil {
+		writePriceListError(w, err)
+		return
+	}
+	prices, err := pricing.Load(s.Prices)
+	if err != nil {
+		writePriceListError(w, err)
+		return
+	}
+
+	// 2) One row per product
+	rows := make([]map[string]interface{}, 0, len(products))
+	for _, p := range products {
+		byApartment := make([]map[string]interface{}, 0, len(apartments))
+		for _, a := range apartments {
+			price, source := prices.Quote(p.ProductID, "", a.ApartmentID, date)
+			byApartment = append(byApartment, map[string]interface{}{
+				"apartment_id":   a.ApartmentID,
+				"apartment_name": a.ApartmentName,
+				"price":          price,
+				"source":         source,
+			})
+		}
+		byCustomer := make([]map[string]interface{}, 0)
+		for _, c := range customers {
+			price, source := prices.Quote(p.ProductID, c.UserID, c.ApartmentID, date)
+			if source != pricing.SourceCustomer {
+				continue
+			}
+			byCustomer = append(byCustomer, map[string]interface{}{
+				"user_id":      c.UserID,
+				"name":         c.Name,
+				"apartment_id": c.ApartmentID,
+				"price":        price,
+			})
+		}
+		rows = append(rows, map[string]interface{}{
+			"product_id":   p.ProductID,
+			"product_name": p.ProductName,
+			"base_price":   prices.PriceAsOf(p.ProductID, date),
+			"apartments":   byApartment,
+			"customers":    byCustomer,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"date":     date.String(),
+		"products": rows,
+	})
+}
+
+// checkPriceListUnlocked answers 409 and returns false when a finalized
+// invoice of a customer the entry applies to bills its product between its
+// effective dates.
+func (s *Server) checkPriceListUnlocked(w http.ResponseWriter, e store.PriceListEntry) bool {
+	switch err := invoices.CheckPriceListChange(s.Stores, e); err {
+	case nil:
+		return true
+	case invoices.ErrLocked:
+		http.Error(w, "A finalized invoice already bills this product within the entry's dates", http.StatusConflict)
+	default:
+		log.Printf("Error checking invoice lock: %v\n", err)
+		http.Error(w, "Failed to check invoice lock", http.StatusInternalServerError)
+	}
+	return false
+}
+
+func writePriceListError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Price list entry not found", http.StatusNotFound)
+	default:
+		log.Printf("Price list error: %v\n", err)
+		http.Error(w, "Failed to process price list", http.StatusInternalServerError)
+	}
+}
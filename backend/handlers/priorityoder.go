@@ -1,27 +1,59 @@
 package handlers
 
 import (
+	"backend/store"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
-	"backend/config"
 )
 
-// Request struct for updating priority
+// Request struct for updating priority. UserIDs is the apartment's full
+// delivery order and Version the route_version it was loaded at.
 type UpdatePriorityRequest struct {
-	ApartmentID string         `json:"apartment_id"`
-	Customers   []PriorityData `json:"customers"`
+	ApartmentID string   `json:"apartment_id"`
+	Version     *int     `json:"version"`
+	UserIDs     []string `json:"user_ids"`
 }
 
-// Individual priority struct
-type PriorityData struct {
-	UserID        string `json:"user_id"`
-	PriorityOrder int    `json:"priority_order"`
+// GetRouteOrder returns an apartment's active customers in delivery order,
+// with the version to send back when reordering them.
+func (s *Server) GetRouteOrder(w http.ResponseWriter, r *http.Request) {
+	apartmentID := r.URL.Query().Get("apartment_id")
+	if apartmentID == "" {
+		http.Error(w, "apartment_id is required", http.StatusBadRequest)
+		return
+	}
+	if !s.requireApartmentAccess(w, r, apartmentID) {
+		return
+	}
+
+	// Read the version first: a change in between makes the list newer than
+	// the version, and the next reorder is refused rather than lost
+	apt, err := s.Apartments.GetApartment(apartmentID)
+	if err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	customers, err := s.Customers.ListCustomers(store.CustomerFilter{ApartmentID: apartmentID})
+	if err != nil {
+		writeRouteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"apartment_id": apt.ApartmentID,
+		"version":      apt.RouteVersion,
+		"customers":    customers,
+	})
 }
 
-// UpdateCustomerPriorities updates customer priority in bulk
-func UpdateCustomerPriorities(w http.ResponseWriter, r *http.Request) {
+// UpdateCustomerPriorities saves a new delivery order for an apartment. The
+// list must hold every active customer exactly once; they are numbered 1..N
+// in one transaction. A version other than the apartment's current one means
+// someone changed the order since it was loaded, and is refused.
+func (s *Server) UpdateCustomerPriorities(w http.ResponseWriter, r *http.Request) {
 	// Parse JSON request body
 	var req UpdatePriorityRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -30,47 +62,72 @@ func UpdateCustomerPriorities(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate input
-	if req.ApartmentID == "" || len(req.Customers) == 0 {
-		http.Error(w, "apartment_id and customers are required", http.StatusBadRequest)
+	// 1) Validate the list against the apartment's current customers
+	errs := fieldErrors{}
+	if req.ApartmentID == "" {
+		errs.add("apartment_id", "required")
+	}
+	if req.Version == nil {
+		errs.add("version", "required")
+	}
+	if len(req.UserIDs) == 0 {
+		errs.add("user_ids", "required")
+	}
+	if errs.write(w) {
 		return
 	}
-
-	// Start a transaction
-	tx, err := config.DB.Begin()
-	if err != nil {
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+	if !s.requireApartmentAccess(w, r, req.ApartmentID) {
 		return
 	}
-
-	// Prepare update statement
-	stmt, err := tx.Prepare("UPDATE users SET priority_order = $1 WHERE user_id = $2 AND apartment_id = $3")
+	if _, err := s.Apartments.GetApartment(req.ApartmentID); err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	customers, err := s.Customers.ListCustomers(store.CustomerFilter{ApartmentID: req.ApartmentID})
 	if err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to prepare statement", http.StatusInternalServerError)
+		writeRouteError(w, err)
 		return
 	}
-	defer stmt.Close()
-
-	// Update each user's priority in a loop
-	for _, customer := range req.Customers {
-		_, err := stmt.Exec(customer.PriorityOrder, customer.UserID, req.ApartmentID)
-		if err != nil {
-			log.Println("Error updating priority for user:", customer.UserID, err)
-			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to update priority for user %s", customer.UserID), http.StatusInternalServerError)
-			return
+	unlisted := make(map[string]bool, len(customers))
+	for _, c := range customers {
+		unlisted[c.UserID] = true
+	}
+	for _, id := range req.UserIDs {
+		if !unlisted[id] {
+			errs.add("user_ids", "%s is listed twice or is not an active customer of the apartment", id)
 		}
+		delete(unlisted, id)
+	}
+	if len(unlisted) > 0 {
+		errs.add("user_ids", "%d active customers are missing", len(unlisted))
+	}
+	if errs.write(w) {
+		return
 	}
 
-	// Commit transaction
-	err = tx.Commit()
+	// 2) Number them 1..N, unless the order changed meanwhile
+	version, err := s.Customers.ReorderCustomers(req.ApartmentID, req.UserIDs, *req.Version)
 	if err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		writeRouteError(w, err)
 		return
 	}
 
 	// Send success response
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Priorities updated successfully"})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Priorities updated successfully",
+		"version": version,
+	})
+}
+
+func writeRouteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Apartment not found", http.StatusNotFound)
+	case errors.Is(err, store.ErrStale):
+		http.Error(w, "The delivery order changed since it was loaded; reload it and try again", http.StatusConflict)
+	default:
+		log.Println("Error updating priorities:", err)
+		http.Error(w, "Failed to update priorities", http.StatusInternalServerError)
+	}
 }
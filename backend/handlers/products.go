@@ -1,8 +1,11 @@
 package handlers
 
 import (
-	"backend/config"
+	"backend/civil"
+	"backend/invoices"
 	"backend/models"
+	"backend/pricing"
+	"backend/store"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -13,24 +16,22 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// Get all products
-func GetProducts(w http.ResponseWriter, r *http.Request) {
-	rows, err := config.DB.Query("SELECT product_id, product_name, unit, current_price, image_url,acronym FROM products")
+// Get all products, priced as of today
+func (s *Server) GetProducts(w http.ResponseWriter, r *http.Request) {
+	products, err := s.Products.ListProducts(includeArchived(r))
 	if err != nil {
 		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var products []models.Product
-	for rows.Next() {
-		var product models.Product
-		err := rows.Scan(&product.ProductID, &product.ProductName, &product.Unit, &product.CurrentPrice, &product.ImageURL,&product.Acronym)
-		if err != nil {
-			http.Error(w, "Error scanning products", http.StatusInternalServerError)
-			return
-		}
-		products = append(products, product)
+	prices, err := pricing.Load(s.Prices)
+	if err != nil {
+		log.Printf("Error loading prices: %v\n", err)
+		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		return
+	}
+	today := s.today()
+	for i := range products {
+		products[i].CurrentPrice = prices.PriceAsOf(products[i].ProductID, today)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -40,7 +41,7 @@ func GetProducts(w http.ResponseWriter, r *http.Request) {
 
 // Add a new product (upload image to Cloudinary)
 // Add a new product (upload image to Cloudinary)
-func CreateProduct(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateProduct(w http.ResponseWriter, r *http.Request) {
     var product models.Product
     
     // Log the incoming request method and endpoint
@@ -75,8 +76,7 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
    // fmt.Printf("[DEBUG] Image URL received: %s\n", product.ImageURL)
     
     // Insert into database
-    _, err = config.DB.Exec("INSERT INTO products (product_id, product_name, unit, current_price, image_url, acronym) VALUES (gen_random_uuid(), $1, $2, $3, $4, $5)",
-        product.ProductName, product.Unit, product.CurrentPrice, product.ImageURL, product.Acronym)
+    _, err = s.Products.CreateProduct(product)
     
     if err != nil {
      //   fmt.Printf("[ERROR] Failed to execute database insert query: %v\n", err)
@@ -96,7 +96,7 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 
 
 // Update product details & track price changes
-func UpdateProduct(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID := params["id"]
 
@@ -115,22 +115,44 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch the current price before updating
-	var oldPrice float64
-	err = config.DB.QueryRow("SELECT current_price FROM products WHERE product_id = $1", productID).Scan(&oldPrice)
+	current, err := s.Products.GetProduct(productID)
 	if err != nil {
 		http.Error(w, "Product not found", http.StatusNotFound)
 		return
 	}
+	prices, err := pricing.Load(s.Prices)
+	if err != nil {
+		log.Printf("Error loading prices: %v\n", err)
+		http.Error(w, "Failed to load prices", http.StatusInternalServerError)
+		return
+	}
 
-	// Check if the price has changed
-	if oldPrice != requestData.CurrentPrice {
-		// Insert into product_price_history table
-		log.Printf("entering here")
-		_, err = config.DB.Exec(
-			"INSERT INTO product_price_history (price_id, product_id, old_price, new_price, effective_from, updated_at) VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW())",
-			productID, oldPrice, requestData.CurrentPrice, requestData.EffectiveFrom,
-		)
+	// A new price is scheduled from effective_from instead of being written
+	// over the product; without effective_from the price must be today's
+	var effectiveFrom civil.Date
+	if requestData.EffectiveFrom != "" {
+		if effectiveFrom, err = civil.Parse(requestData.EffectiveFrom); err != nil {
+			http.Error(w, "Invalid effective_from, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	} else if prices.PriceAsOf(productID, s.today()) != requestData.CurrentPrice {
+		http.Error(w, "effective_from is required to change the price", http.StatusBadRequest)
+		return
+	}
+
+	// Insert into product_price_history table, unless the same price is
+	// already in effect (or scheduled) on that day
+	if !effectiveFrom.IsZero() && prices.PriceAsOf(productID, effectiveFrom) != requestData.CurrentPrice {
+		// Refuse changes that would alter a finalized invoice
+		if !s.checkPriceUnlocked(w, productID, effectiveFrom) {
+			return
+		}
+		err = s.Prices.AddPriceChange(models.ProductPriceHistory{
+			ProductID:     productID,
+			OldPrice:      prices.PriceAsOf(productID, effectiveFrom.AddDays(-1)),
+			NewPrice:      requestData.CurrentPrice,
+			EffectiveFrom: effectiveFrom,
+		})
 		if err != nil {
 			log.Printf("Error inserting into price history: %v\n", err)
 			http.Error(w, "Failed to track price change", http.StatusInternalServerError)
@@ -138,13 +160,16 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Update the product in the products table
-	query := `
-		UPDATE products
-		SET product_name = $1, unit = $2, current_price = $3, image_url = $4 ,acronym =$5
-		WHERE product_id = $6
-	`
-	_, err = config.DB.Exec(query, requestData.ProductName, requestData.Unit, requestData.CurrentPrice, requestData.ImageURL,requestData.Acronym, productID)
+	// Update the product in the products table. The stored price stays the
+	// one from before the first change.
+	err = s.Products.UpdateProduct(models.Product{
+		ProductID:    productID,
+		ProductName:  requestData.ProductName,
+		Unit:         requestData.Unit,
+		CurrentPrice: current.CurrentPrice,
+		ImageURL:     requestData.ImageURL,
+		Acronym:      requestData.Acronym,
+	})
 	if err != nil {
 		log.Printf("Error updating product in database: %v\n", err)
 		http.Error(w, "Failed to update product", http.StatusInternalServerError)
@@ -156,22 +181,47 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 
 
 
-// Delete a product
-func DeleteProduct(w http.ResponseWriter, r *http.Request) {
+// Delete a product. The product is archived rather than removed, so its
+// price history and past bills stay intact.
+func (s *Server) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID := params["id"]
 
-	_, err := config.DB.Exec("DELETE FROM products WHERE product_id = $1", productID)
-	if err != nil {
+	switch err := s.Products.ArchiveProduct(productID, s.today()); err {
+	case nil:
+	case store.ErrNotFound:
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	case store.ErrInUse:
+		http.Error(w, "Product is still in default orders or upcoming modifications", http.StatusConflict)
+		return
+	default:
+		log.Printf("Error archiving product: %v\n", err)
 		http.Error(w, "Failed to delete product", http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Fprintln(w, "Product deleted successfully!")
+	fmt.Fprintln(w, "Product archived successfully!")
+}
+
+// RestoreProduct brings an archived product back into the catalogue.
+func (s *Server) RestoreProduct(w http.ResponseWriter, r *http.Request) {
+	switch err := s.Products.RestoreProduct(mux.Vars(r)["id"]); err {
+	case nil:
+	case store.ErrNotFound:
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	default:
+		log.Printf("Error restoring product: %v\n", err)
+		http.Error(w, "Failed to restore product", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "Product restored successfully!")
 }
 
 
-func Bulkupload(w http.ResponseWriter, r *http.Request) {
+func (s *Server) Bulkupload(w http.ResponseWriter, r *http.Request) {
     var products []models.Product
 
     // Log the incoming request body
@@ -202,10 +252,7 @@ func Bulkupload(w http.ResponseWriter, r *http.Request) {
         fmt.Printf("Processing product: %+v\n", product)
 
         // Insert into the database
-        _, err = config.DB.Exec(
-            "INSERT INTO products (product_id, product_name, unit, current_price, image_url) VALUES (gen_random_uuid(), $1, $2, $3, $4)",
-            product.ProductName, product.Unit, product.CurrentPrice, product.ImageURL,
-        )
+        _, err = s.Products.CreateProduct(product)
         if err != nil {
             fmt.Printf("Error inserting product into database: %+v, error: %v\n", product, err)
             http.Error(w, "Failed to add some products", http.StatusInternalServerError)
@@ -225,32 +272,84 @@ func Bulkupload(w http.ResponseWriter, r *http.Request) {
 }
 
 
-func GetProductPriceHistory(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetProductPriceHistory(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID := params["id"]
 
-	rows, err := config.DB.Query(
-		"SELECT price_id, old_price, new_price, effective_from, updated_at FROM product_price_history WHERE product_id = $1 ORDER BY effective_from ASC",
-		productID,
-	)
+	priceHistory, err := s.Prices.PriceChanges(productID)
 	if err != nil {
 		http.Error(w, "Failed to fetch price history", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var priceHistory []models.ProductPriceHistory
-	for rows.Next() {
-		var entry models.ProductPriceHistory
-		err := rows.Scan(&entry.PriceID, &entry.OldPrice, &entry.NewPrice, &entry.EffectiveFrom, &entry.UpdatedAt)
-		if err != nil {
-			http.Error(w, "Error scanning price history", http.StatusInternalServerError)
-			return
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(priceHistory)
+}
+
+
+// GetUpcomingPriceChanges lists the price changes that take effect after
+// today, for one product when product_id is given
+func (s *Server) GetUpcomingPriceChanges(w http.ResponseWriter, r *http.Request) {
+	changes, err := s.Prices.PriceChanges(r.URL.Query().Get("product_id"))
+	if err != nil {
+		log.Printf("Error fetching price changes: %v\n", err)
+		http.Error(w, "Failed to fetch price changes", http.StatusInternalServerError)
+		return
+	}
+	today := s.today()
+	upcoming := make([]models.ProductPriceHistory, 0)
+	for _, h := range changes {
+		if h.EffectiveFrom.After(today) {
+			upcoming = append(upcoming, h)
 		}
-		priceHistory = append(priceHistory, entry)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(priceHistory)
+	json.NewEncoder(w).Encode(upcoming)
 }
 
+// CancelPriceChange removes a price change that has not taken effect yet
+func (s *Server) CancelPriceChange(w http.ResponseWriter, r *http.Request) {
+	priceID := mux.Vars(r)["id"]
+
+	change, err := s.Prices.PriceChange(priceID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Price change not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching price change: %v\n", err)
+		http.Error(w, "Failed to fetch price change", http.StatusInternalServerError)
+		return
+	}
+	if !change.EffectiveFrom.After(s.today()) {
+		http.Error(w, "Price change is already in effect", http.StatusConflict)
+		return
+	}
+	if !s.checkPriceUnlocked(w, change.ProductID, change.EffectiveFrom) {
+		return
+	}
+
+	if err := s.Prices.DeletePriceChange(priceID); err != nil && err != store.ErrNotFound {
+		log.Printf("Error cancelling price change: %v\n", err)
+		http.Error(w, "Failed to cancel price change", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "Price change cancelled successfully!")
+}
+
+// checkPriceUnlocked answers 409 and returns false when a finalized invoice
+// bills the product on or after from.
+func (s *Server) checkPriceUnlocked(w http.ResponseWriter, productID string, from civil.Date) bool {
+	switch err := invoices.CheckPriceChange(s.Stores, productID, from); err {
+	case nil:
+		return true
+	case invoices.ErrLocked:
+		http.Error(w, "A finalized invoice already bills this product after effective_from", http.StatusConflict)
+	default:
+		log.Printf("Error checking invoice lock: %v\n", err)
+		http.Error(w, "Failed to check invoice lock", http.StatusInternalServerError)
+	}
+	return false
+}
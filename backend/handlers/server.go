@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"backend/civil"
+	"backend/config"
+	"backend/cutoff"
+	"backend/store"
+	"database/sql"
+	"net/http"
+	"time"
+)
+
+// Server carries what the handlers need. Every handler is a method on it,
+// so tests can build one over in-memory stores.
+type Server struct {
+	store.Stores
+	// DB serves admin account management, which has no store yet.
+	DB *sql.DB
+	// Cutoff closes changes to a delivery day the evening before.
+	Cutoff cutoff.Policy
+	// Now tells the time for the cut-off; tests may replace it.
+	Now func() time.Time
+}
+
+// NewServer returns a server whose stores are backed by db, with the order
+// cut-off from config.
+func NewServer(db *sql.DB) *Server {
+	return &Server{Stores: store.NewPostgres(db), DB: db, Cutoff: config.OrderCutoff, Now: time.Now}
+}
+
+// now returns the current time; tests may replace s.Now.
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// today returns the current date in the business time zone.
+func (s *Server) today() civil.Date {
+	return civil.Of(s.now().In(config.Location))
+}
+
+// includeArchived reports whether a list request asked for archived rows
+// with ?include_archived=true.
+func includeArchived(r *http.Request) bool {
+	return r.URL.Query().Get("include_archived") == "true"
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"backend/civil"
+	"backend/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// vacationRequest is the body of the create and update endpoints. An empty
+// product_ids pauses every product.
+type vacationRequest struct {
+	StartDate  string   `json:"start_date"`
+	EndDate    string   `json:"end_date"`
+	Reason     string   `json:"reason"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+func vacationJSON(v store.Vacation) map[string]interface{} {
+	productIDs := v.ProductIDs
+	if productIDs == nil {
+		productIDs = []string{}
+	}
+	return map[string]interface{}{
+		"vacation_id":  v.VacationID,
+		"user_id":      v.UserID,
+		"start_date":   v.StartDate.String(),
+		"end_date":     v.EndDate.String(),
+		"reason":       v.Reason,
+		"product_ids":  productIDs,
+		"all_products": len(productIDs) == 0,
+		"created_at":   v.CreatedAt,
+	}
+}
+
+// readVacation decodes and validates a vacation request for the customer. It
+// answers the request and returns false when something is wrong.
+func (s *Server) readVacation(w http.ResponseWriter, r *http.Request, userID string) (store.Vacation, bool) {
+	var req vacationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return store.Vacation{}, false
+	}
+
+	in := modificationInput{userID: userID, startDate: req.StartDate, endDate: req.EndDate}
+	errs, err := s.validateModification(&in)
+	if err != nil {
+		writeVacationError(w, err)
+		return store.Vacation{}, false
+	}
+	if in.customer == nil {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return store.Vacation{}, false
+	}
+	known, err := s.knownProducts()
+	if err != nil {
+		writeVacationError(w, err)
+		return store.Vacation{}, false
+	}
+	seen := make(map[string]bool)
+	for i, id := range req.ProductIDs {
+		field := fmt.Sprintf("product_ids[%d]", i)
+		switch {
+		case !known[id]:
+			errs.add(field, "unknown product")
+		case seen[id]:
+			errs.add(field, "product listed twice")
+		}
+		seen[id] = true
+	}
+	if errs.write(w) {
+		return store.Vacation{}, false
+	}
+
+	return store.Vacation{
+		UserID:     userID,
+		StartDate:  in.start,
+		EndDate:    in.end,
+		Reason:     req.Reason,
+		ProductIDs: req.ProductIDs,
+	}, true
+}
+
+// GetCustomerVacations lists a customer's vacations, latest first.
+func (s *Server) GetCustomerVacations(w http.ResponseWriter, r *http.Request) {
+	customerID := mux.Vars(r)["id"]
+	if _, err := s.Customers.GetCustomer(customerID); err != nil {
+		writeVacationError(w, err)
+		return
+	}
+	list, err := s.Vacations.CustomerVacations(customerID)
+	if err != nil {
+		writeVacationError(w, err)
+		return
+	}
+
+	resp := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		resp = append(resp, vacationJSON(v))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CreateVacation pauses a customer's deliveries, or only some products, over
+// a date range.
+func (s *Server) CreateVacation(w http.ResponseWriter, r *http.Request) {
+	v, ok := s.readVacation(w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	override, ok := s.checkOrderChange(w, r, v.UserID, v.StartDate, v.EndDate)
+	if !ok {
+		return
+	}
+
+	id, err := s.Vacations.CreateVacation(v)
+	if err != nil {
+		writeVacationError(w, err)
+		return
+	}
+	v.VacationID = id
+	s.recordOverrides(w, override)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(vacationJSON(v))
+}
+
+// UpdateVacation changes the dates, reason or paused products of a vacation.
+// Only the days whose deliveries change go through the cut-off and invoice
+// checks, so an ongoing vacation can still be extended or ended early.
+func (s *Server) UpdateVacation(w http.ResponseWriter, r *http.Request) {
+	old, err := s.Vacations.GetVacation(mux.Vars(r)["id"])
+	if err != nil {
+		writeVacationError(w, err)
+		return
+	}
+	v, ok := s.readVacation(w, r, old.UserID)
+	if !ok {
+		return
+	}
+	var override *store.CutoffOverride
+	if from, to, changed := changedDays(*old, v); changed {
+		if override, ok = s.checkOrderChange(w, r, v.UserID, from, to); !ok {
+			return
+		}
+	}
+
+	v.VacationID, v.CreatedAt = old.VacationID, old.CreatedAt
+	if err := s.Vacations.UpdateVacation(v); err != nil {
+		writeVacationError(w, err)
+		return
+	}
+	s.recordOverrides(w, override)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vacationJSON(v))
+}
+
+// DeleteVacation cancels a vacation; its days fall back to the normal order.
+func (s *Server) DeleteVacation(w http.ResponseWriter, r *http.Request) {
+	v, err := s.Vacations.GetVacation(mux.Vars(r)["id"])
+	if err != nil {
+		writeVacationError(w, err)
+		return
+	}
+	override, ok := s.checkOrderChange(w, r, v.UserID, v.StartDate, v.EndDate)
+	if !ok {
+		return
+	}
+	if err := s.Vacations.DeleteVacation(v.VacationID); err != nil {
+		writeVacationError(w, err)
+		return
+	}
+	s.recordOverrides(w, override)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Vacation deleted successfully"})
+}
+
+// changedDays returns the days whose deliveries change when old is updated to
+// v: none for a new reason, the days between the two end dates when only the
+// end moves, and both ranges otherwise.
+func changedDays(old, v store.Vacation) (civil.Date, civil.Date, bool) {
+	sameProducts := strings.Join(old.ProductIDs, ",") == strings.Join(v.ProductIDs, ",")
+	switch {
+	case !sameProducts || old.StartDate != v.StartDate:
+		from, to := old.StartDate, old.EndDate
+		if v.StartDate.Before(from) {
+			from = v.StartDate
+		}
+		if v.EndDate.After(to) {
+			to = v.EndDate
+		}
+		return from, to, true
+	case old.EndDate.Before(v.EndDate):
+		return old.EndDate.AddDays(1), v.EndDate, true
+	case v.EndDate.Before(old.EndDate):
+		return v.EndDate.AddDays(1), old.EndDate, true
+	}
+	return civil.Date{}, civil.Date{}, false
+}
+
+func writeVacationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Vacation or customer not found", http.StatusNotFound)
+	default:
+		log.Printf("Vacation error: %v\n", err)
+		http.Error(w, "Failed to process vacation", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"backend/civil"
+	"backend/models"
+	"backend/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// fieldErrors collects what is wrong with a request body, keyed by the JSON
+// path of the offending field, e.g. "orders[1].quantity".
+type fieldErrors map[string]string
+
+// add records the first problem found with field.
+func (e fieldErrors) add(field, format string, args ...interface{}) {
+	if _, ok := e[field]; !ok {
+		e[field] = fmt.Sprintf(format, args...)
+	}
+}
+
+// write answers 400 with every field error and reports whether there was any.
+func (e fieldErrors) write(w http.ResponseWriter) bool {
+	if len(e) == 0 {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Invalid request",
+		"fields": e,
+	})
+	return true
+}
+
+// modificationInput is the part of a modification request every endpoint
+// validates the same way. validateModification fills in start, end and
+// customer.
+type modificationInput struct {
+	userID, startDate, endDate string
+	productsField              string // JSON name of the product list, empty when there is none
+	alternating                bool
+	products                   []productInput
+
+	start, end civil.Date
+	customer   *models.User
+}
+
+type productInput struct {
+	productID string
+	quantity  float64
+	dayType   string
+}
+
+// validateModification checks that the customer and every product exist,
+// that the dates are valid and in order, and that quantities are not
+// negative. A non-nil error means the check itself failed.
+func (s *Server) validateModification(in *modificationInput) (fieldErrors, error) {
+	errs := fieldErrors{}
+
+	// 1) Customer
+	if in.userID == "" {
+		errs.add("user_id", "is required")
+	} else {
+		c, err := s.Customers.GetCustomer(in.userID)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			errs.add("user_id", "unknown customer")
+		case err != nil:
+			return nil, err
+		case c.Archived:
+			errs.add("user_id", "customer is archived")
+		}
+		in.customer = c
+	}
+
+	// 2) Date range
+	var err error
+	if in.start, err = civil.Parse(in.startDate); err != nil {
+		errs.add("start_date", "must be a YYYY-MM-DD date")
+	}
+	if in.end, err = civil.Parse(in.endDate); err != nil {
+		errs.add("end_date", "must be a YYYY-MM-DD date")
+	}
+	if errs["start_date"] == "" && errs["end_date"] == "" && in.end.Before(in.start) {
+		errs.add("end_date", "must not be before start_date")
+	}
+
+	// 3) Products
+	if in.productsField == "" {
+		return errs, nil
+	}
+	if len(in.products) == 0 {
+		errs.add(in.productsField, "at least one product is required")
+		return errs, nil
+	}
+	known, err := s.knownProducts()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i, p := range in.products {
+		field := fmt.Sprintf("%s[%d]", in.productsField, i)
+		switch {
+		case p.productID == "":
+			errs.add(field+".product_id", "is required")
+		case !known[p.productID]:
+			errs.add(field+".product_id", "unknown product")
+		case seen[p.productID+"/"+p.dayType]:
+			errs.add(field+".product_id", "product listed twice")
+		}
+		seen[p.productID+"/"+p.dayType] = true
+		if p.quantity < 0 {
+			errs.add(field+".quantity", "must not be negative")
+		}
+		if in.alternating && p.dayType != "ODD" && p.dayType != "EVEN" && p.dayType != "CUSTOM" {
+			errs.add(field+".day_type", "must be ODD, EVEN or CUSTOM")
+		}
+	}
+	return errs, nil
+}
+
+// knownProducts returns the ids of every active product in the catalogue.
+func (s *Server) knownProducts() (map[string]bool, error) {
+	catalogue, err := s.Products.ListProducts(false)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(catalogue))
+	for _, p := range catalogue {
+		known[p.ProductID] = true
+	}
+	return known, nil
+}
@@ -0,0 +1,193 @@
+// Package invoicepdf renders a monthly bill as a printable A4 invoice. It
+// only formats what billing computed; loading the bill, the customer and
+// the products is the caller's job.
+package invoicepdf
+
+import (
+	"backend/billing"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Customer is who the invoice is addressed to.
+type Customer struct {
+	Name          string
+	ApartmentName string
+	RoomNumber    string
+}
+
+// Product is how a product is labelled in the grid.
+type Product struct {
+	Name    string
+	Acronym string
+	Unit    string
+}
+
+// Business is the seller and where to pay.
+type Business struct {
+	Name     string
+	Phone    string
+	UPIID    string
+	UPIPayee string
+}
+
+const (
+	pageWidth   = 190.0 // A4 minus 10mm margins
+	dateWidth   = 24.0
+	amountWidth = 26.0
+	rowHeight   = 5.5
+)
+
+// Render writes the invoice of one customer's bill as a PDF.
+func Render(w io.Writer, biz Business, cust Customer, bill *billing.Bill, products map[string]Product) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.SetAutoPageBreak(true, 10)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	period := time.Date(bill.Year, bill.Month, 1, 0, 0, 0, 0, time.UTC).Format("January 2006")
+	pdf.SetTitle(fmt.Sprintf("%s - %s", cust.Name, period), true)
+	pdf.AddPage()
+
+	// 1) Header: seller, period and customer
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, tr(biz.Name), "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	if biz.Phone != "" {
+		pdf.CellFormat(0, 5, tr("Ph: "+biz.Phone), "", 1, "C", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 7, "Monthly Bill - "+period, "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(pageWidth/2, 6, tr("Customer: "+cust.Name), "", 0, "L", false, 0, "")
+	pdf.CellFormat(pageWidth/2, 6, tr("Apartment: "+cust.ApartmentName+"   Room: "+cust.RoomNumber), "", 1, "R", false, 0, "")
+	pdf.Ln(2)
+
+	// 2) Day-by-day grid, one column per product delivered this month
+	ids := productColumns(bill, products)
+	colWidth := 0.0
+	if len(ids) > 0 {
+		colWidth = (pageWidth - dateWidth - amountWidth) / float64(len(ids))
+	}
+	header := func() {
+		pdf.SetFont("Helvetica", "B", 8)
+		pdf.SetFillColor(0, 128, 128)
+		pdf.SetTextColor(255, 255, 255)
+		pdf.CellFormat(dateWidth, rowHeight+1, "Date", "1", 0, "C", true, 0, "")
+		for _, id := range ids {
+			pdf.CellFormat(colWidth, rowHeight+1, tr(label(id, products)), "1", 0, "C", true, 0, "")
+		}
+		pdf.CellFormat(amountWidth, rowHeight+1, "Amount", "1", 1, "C", true, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+	header()
+
+	totals := make(map[string]float64)
+	pdf.SetFont("Helvetica", "", 8)
+	for _, day := range bill.Days {
+		qty := make(map[string]float64)
+		for _, item := range day.Products {
+			qty[item.ProductID] += item.Quantity
+			totals[item.ProductID] += item.Quantity
+		}
+		empty := len(day.Products) == 0
+		pdf.SetFillColor(255, 228, 228)
+		pdf.CellFormat(dateWidth, rowHeight, day.Date.Format("02 Mon"), "1", 0, "C", empty, 0, "")
+		for _, id := range ids {
+			cell := "-"
+			if q, ok := qty[id]; ok {
+				cell = quantity(q)
+			}
+			pdf.CellFormat(colWidth, rowHeight, cell, "1", 0, "C", empty, 0, "")
+		}
+		pdf.CellFormat(amountWidth, rowHeight, money(day.DayBill), "1", 1, "R", empty, 0, "")
+	}
+
+	pdf.SetFont("Helvetica", "B", 8)
+	pdf.SetFillColor(204, 238, 238)
+	pdf.CellFormat(dateWidth, rowHeight, "Total", "1", 0, "C", true, 0, "")
+	for _, id := range ids {
+		pdf.CellFormat(colWidth, rowHeight, quantity(totals[id]), "1", 0, "C", true, 0, "")
+	}
+	pdf.CellFormat(amountWidth, rowHeight, money(bill.Total), "1", 1, "R", true, 0, "")
+
+	// 3) Legend for the acronyms
+	pdf.Ln(2)
+	pdf.SetFont("Helvetica", "", 8)
+	var legend []string
+	for _, id := range ids {
+		p := products[id]
+		legend = append(legend, fmt.Sprintf("%s = %s (%s)", label(id, products), p.Name, p.Unit))
+	}
+	if len(legend) > 0 {
+		pdf.MultiCell(0, 4, tr(strings.Join(legend, "   ")), "", "L", false)
+	}
+
+	// 4) Monthly total and how to pay
+	pdf.Ln(3)
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, "Monthly Total: Rs. "+money(bill.Total), "", 1, "R", false, 0, "")
+	if biz.UPIID != "" {
+		pdf.Ln(2)
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(0, 5, tr(fmt.Sprintf("Pay by UPI: %s (%s)", biz.UPIID, biz.UPIPayee)), "", 1, "L", false, 0, "")
+		pdf.SetFont("Courier", "", 8)
+		note := fmt.Sprintf("%s %s %s", cust.ApartmentName, cust.RoomNumber, period)
+		pdf.MultiCell(0, 4, tr(UPIString(biz.UPIID, biz.UPIPayee, bill.Total, note)), "", "L", false)
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// UPIString is the upi://pay link a UPI app or QR code opens to pay amount.
+func UPIString(upiID, payee string, amount float64, note string) string {
+	esc := func(s string) string { return strings.ReplaceAll(url.QueryEscape(s), "+", "%20") }
+	return fmt.Sprintf("upi://pay?pa=%s&pn=%s&am=%.2f&cu=INR&tn=%s", esc(upiID), esc(payee), amount, esc(note))
+}
+
+// productColumns returns the products delivered during the month, sorted
+// by their label.
+func productColumns(bill *billing.Bill, products map[string]Product) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, day := range bill.Days {
+		for _, item := range day.Products {
+			if !seen[item.ProductID] {
+				seen[item.ProductID] = true
+				ids = append(ids, item.ProductID)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return label(ids[i], products) < label(ids[j], products) })
+	return ids
+}
+
+// label is the product's acronym, falling back to its name.
+func label(id string, products map[string]Product) string {
+	p := products[id]
+	if p.Acronym != "" {
+		return p.Acronym
+	}
+	if p.Name != "" {
+		return p.Name
+	}
+	return "?"
+}
+
+func quantity(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
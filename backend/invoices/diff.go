@@ -0,0 +1,102 @@
+package invoices
+
+import (
+	"backend/billing"
+	"backend/civil"
+	"backend/store"
+	"sort"
+	"time"
+)
+
+// Difference is one (date, product) where the stored invoice and a fresh
+// computation disagree. A zero stored or current quantity means the line
+// only exists on the other side.
+type Difference struct {
+	Date            civil.Date `json:"date"`
+	ProductID       string     `json:"product_id"`
+	StoredQuantity  float64    `json:"stored_quantity"`
+	CurrentQuantity float64    `json:"current_quantity"`
+	StoredPrice     float64    `json:"stored_price_per_unit"`
+	CurrentPrice    float64    `json:"current_price_per_unit"`
+	StoredTotal     float64    `json:"stored_total"`
+	CurrentTotal    float64    `json:"current_total"`
+}
+
+// Diff compares an invoice with what billing.Compute returns today.
+type Diff struct {
+	InvoiceID    string       `json:"invoice_id"`
+	Status       string       `json:"status"`
+	StoredTotal  float64      `json:"stored_total"`
+	CurrentTotal float64      `json:"current_total"`
+	Matches      bool         `json:"matches"`
+	Differences  []Difference `json:"differences"`
+}
+
+type lineKey struct {
+	date      civil.Date
+	productID string
+}
+
+// Compare recomputes the invoice's month and lists every difference.
+func Compare(st store.Stores, invoiceID string) (*Diff, error) {
+	inv, err := Get(st, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	bill, err := billing.Compute(st, inv.UserID, inv.Year, time.Month(inv.Month))
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[lineKey]*Difference)
+	entry := func(k lineKey) *Difference {
+		d, ok := merged[k]
+		if !ok {
+			d = &Difference{Date: k.date, ProductID: k.productID}
+			merged[k] = d
+		}
+		return d
+	}
+	for _, line := range inv.Lines {
+		d := entry(lineKey{line.DeliveryDate, line.ProductID})
+		d.StoredQuantity += line.Quantity
+		d.StoredPrice = line.PricePerUnit
+		d.StoredTotal += line.TotalPrice
+	}
+	for _, day := range bill.Days {
+		for _, item := range day.Products {
+			d := entry(lineKey{day.Date, item.ProductID})
+			d.CurrentQuantity += item.Quantity
+			d.CurrentPrice = round2(item.PricePerUnit)
+			d.CurrentTotal += round2(item.TotalPrice)
+		}
+	}
+
+	diff := &Diff{
+		InvoiceID:    inv.InvoiceID,
+		Status:       inv.Status,
+		StoredTotal:  inv.TotalAmount,
+		CurrentTotal: round2(bill.Total),
+		Differences:  make([]Difference, 0),
+	}
+	for _, d := range merged {
+		if !same(d.StoredQuantity, d.CurrentQuantity) || !same(d.StoredPrice, d.CurrentPrice) || !same(d.StoredTotal, d.CurrentTotal) {
+			diff.Differences = append(diff.Differences, *d)
+		}
+	}
+	sort.Slice(diff.Differences, func(i, j int) bool {
+		a, b := diff.Differences[i], diff.Differences[j]
+		if a.Date != b.Date {
+			return a.Date.Before(b.Date)
+		}
+		return a.ProductID < b.ProductID
+	})
+	diff.Matches = len(diff.Differences) == 0 && same(diff.StoredTotal, diff.CurrentTotal)
+	return diff, nil
+}
+
+// same compares amounts stored with two decimals against fresh floats.
+func same(a, b float64) bool {
+	d := a - b
+	return d < 0.005 && d > -0.005
+}
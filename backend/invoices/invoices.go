@@ -0,0 +1,200 @@
+// Package invoices stores monthly bills so they stop moving once sent.
+//
+// An invoice is generated as a draft from billing.Compute, can be regenerated
+// while it is a draft, and is then finalized. A finalized invoice locks the
+// customer's orders for that month and refuses price changes that would
+// alter it, until it is reopened.
+package invoices
+
+import (
+	"backend/billing"
+	"backend/civil"
+	"backend/models"
+	"backend/store"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Invoice statuses.
+const (
+	StatusDraft     = "draft"
+	StatusFinalized = "finalized"
+)
+
+var (
+	ErrNotFound     = errors.New("invoices: invoice not found")
+	ErrFinalized    = errors.New("invoices: invoice is finalized")
+	ErrNotFinalized = errors.New("invoices: invoice is not finalized")
+	// ErrLocked is returned when a change would alter a finalized invoice.
+	ErrLocked = errors.New("invoices: a finalized invoice covers this period")
+)
+
+// Generate computes the customer's bill for the month and stores it as a
+// draft invoice, replacing a previous draft. Finalized invoices are left alone.
+func Generate(st store.Stores, userID string, year int, month time.Month) (*models.Invoice, error) {
+	bill, err := billing.Compute(st, userID, year, month)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := models.Invoice{UserID: userID, Year: year, Month: int(month), TotalAmount: round2(bill.Total)}
+	for _, day := range bill.Days {
+		for _, item := range day.Products {
+			inv.Lines = append(inv.Lines, models.InvoiceLine{
+				DeliveryDate: day.Date,
+				ProductID:    item.ProductID,
+				Quantity:     item.Quantity,
+				PricePerUnit: round2(item.PricePerUnit),
+				TotalPrice:   round2(item.TotalPrice),
+			})
+		}
+	}
+	invoiceID, err := st.Invoices.SaveDraft(inv)
+	if err == store.ErrWrongStatus {
+		return nil, ErrFinalized
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invoices: save draft: %w", err)
+	}
+	return Get(st, invoiceID)
+}
+
+// Get returns an invoice with its lines.
+func Get(st store.Stores, invoiceID string) (*models.Invoice, error) {
+	inv, err := st.Invoices.GetInvoice(invoiceID)
+	if err == store.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return inv, err
+}
+
+// List returns invoices without their lines. Empty filters match everything.
+func List(st store.Stores, userID string, year, month int) ([]models.Invoice, error) {
+	return st.Invoices.ListInvoices(userID, year, month)
+}
+
+// Finalize freezes a draft invoice.
+func Finalize(st store.Stores, invoiceID, adminID string) error {
+	return transitionError(st.Invoices.FinalizeInvoice(invoiceID, adminID), ErrFinalized)
+}
+
+// Reopen turns a finalized invoice back into a draft, unlocking its month.
+func Reopen(st store.Stores, invoiceID string) error {
+	return transitionError(st.Invoices.ReopenInvoice(invoiceID), ErrNotFinalized)
+}
+
+// transitionError tells a missing invoice from one in the wrong status.
+func transitionError(err, wrongStatus error) error {
+	switch err {
+	case store.ErrNotFound:
+		return ErrNotFound
+	case store.ErrWrongStatus:
+		return wrongStatus
+	}
+	return err
+}
+
+// CheckUnlocked returns ErrLocked if a finalized invoice of the customer
+// covers any day between start and end.
+func CheckUnlocked(st store.Stores, userID string, start, end civil.Date) error {
+	locked, err := st.Invoices.InvoiceLocked(userID, start, end)
+	if err != nil {
+		return fmt.Errorf("invoices: check lock: %w", err)
+	}
+	if locked {
+		return ErrLocked
+	}
+	return nil
+}
+
+// CheckPriceChange returns ErrLocked if a price change for the product taking
+// effect on effectiveFrom would alter a finalized invoice.
+func CheckPriceChange(st store.Stores, productID string, effectiveFrom civil.Date) error {
+	return checkPriceLocked(st, productID, "", effectiveFrom, civil.Date{})
+}
+
+// CheckPriceListChange returns ErrLocked if adding or removing the price list
+// entry would alter a finalized invoice: one billing the entry's product
+// between its effective dates to the entry's customer or, for an apartment
+// entry, to whoever lived in the apartment on those days.
+func CheckPriceListChange(st store.Stores, e store.PriceListEntry) error {
+	if e.UserID != "" {
+		return checkPriceLocked(st, e.ProductID, e.UserID, e.EffectiveFrom, e.EffectiveTo)
+	}
+
+	customers, err := st.Customers.ListCustomers(store.CustomerFilter{IncludeArchived: true})
+	if err != nil {
+		return fmt.Errorf("invoices: load customers: %w", err)
+	}
+	userIDs := make([]string, 0, len(customers))
+	for _, c := range customers {
+		userIDs = append(userIDs, c.UserID)
+	}
+	moves, err := st.Customers.CustomerMoves(userIDs)
+	if err != nil {
+		return fmt.Errorf("invoices: load moves: %w", err)
+	}
+	byUser := make(map[string][]store.CustomerMove)
+	for _, mv := range moves {
+		byUser[mv.UserID] = append(byUser[mv.UserID], mv)
+	}
+
+	for _, c := range customers {
+		for _, stay := range stays(c, byUser[c.UserID], e.ApartmentID) {
+			start, end := stay[0], stay[1]
+			if start.Before(e.EffectiveFrom) {
+				start = e.EffectiveFrom
+			}
+			if end.IsZero() || (!e.EffectiveTo.IsZero() && e.EffectiveTo.Before(end)) {
+				end = e.EffectiveTo
+			}
+			if !end.IsZero() && end.Before(start) {
+				continue
+			}
+			if err := checkPriceLocked(st, e.ProductID, c.UserID, start, end); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stays returns the [start, end] ranges the customer lived in the apartment,
+// given its moves oldest first. A zero start or end leaves the range open.
+func stays(c models.User, moves []store.CustomerMove, apartmentID string) [][2]civil.Date {
+	var (
+		out   [][2]civil.Date
+		start civil.Date
+		cur   = c.ApartmentID
+	)
+	if len(moves) > 0 {
+		cur = moves[0].FromApartmentID
+	}
+	for _, mv := range moves {
+		if cur == apartmentID {
+			out = append(out, [2]civil.Date{start, mv.MovedOn.AddDays(-1)})
+		}
+		cur, start = mv.ToApartmentID, mv.MovedOn
+	}
+	if cur == apartmentID {
+		out = append(out, [2]civil.Date{start, {}})
+	}
+	return out
+}
+
+func checkPriceLocked(st store.Stores, productID, userID string, start, end civil.Date) error {
+	locked, err := st.Invoices.PriceLocked(productID, userID, start, end)
+	if err != nil {
+		return fmt.Errorf("invoices: check price lock: %w", err)
+	}
+	if locked {
+		return ErrLocked
+	}
+	return nil
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,130 @@
+package invoices_test
+
+import (
+	"backend/civil"
+	"backend/invoices"
+	"backend/models"
+	"backend/store"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) civil.Date {
+	return civil.Date{Year: y, Month: m, Day: d}
+}
+
+func TestLifecycle(t *testing.T) {
+	st := store.NewMemory().Stores()
+	aptID, _ := st.Apartments.CreateApartment("Lake View")
+	userID, _ := st.Customers.CreateCustomer(models.User{Name: "Asha", ApartmentID: aptID})
+	productID, _ := st.Products.CreateProduct(models.Product{ProductName: "Milk", Unit: "L", CurrentPrice: 30})
+	err := st.Orders.ReplaceDefaults(userID, store.ModeNormal, civil.Date{}, civil.Date{}, []store.DefaultItem{{ProductID: productID, Quantity: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A draft can be regenerated and locks nothing
+	inv, err := invoices.Generate(st, userID, 2025, time.April)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Status != invoices.StatusDraft || inv.TotalAmount != 30*30 || len(inv.Lines) != 30 {
+		t.Fatalf("draft = %s, total %v, %d lines", inv.Status, inv.TotalAmount, len(inv.Lines))
+	}
+	if _, err := invoices.Generate(st, userID, 2025, time.April); err != nil {
+		t.Fatalf("regenerating a draft: %v", err)
+	}
+	if err := invoices.CheckUnlocked(st, userID, date(2025, 4, 10), date(2025, 4, 10)); err != nil {
+		t.Errorf("draft locks orders: %v", err)
+	}
+
+	// Finalizing locks the month's orders and the product's price from then on
+	if err := invoices.Finalize(st, inv.InvoiceID, "admin-1"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"order in the month", invoices.CheckUnlocked(st, userID, date(2025, 4, 30), date(2025, 5, 2)), invoices.ErrLocked},
+		{"order after the month", invoices.CheckUnlocked(st, userID, date(2025, 5, 1), date(2025, 5, 31)), nil},
+		{"default change in the month", invoices.CheckUnlocked(st, userID, date(2025, 4, 20), civil.Date{}), invoices.ErrLocked},
+		{"default change after the month", invoices.CheckUnlocked(st, userID, date(2025, 5, 1), civil.Date{}), nil},
+		{"price change in the month", invoices.CheckPriceChange(st, productID, date(2025, 4, 20)), invoices.ErrLocked},
+		{"price change after the month", invoices.CheckPriceChange(st, productID, date(2025, 5, 1)), nil},
+		{"regenerate", generateErr(st, userID), invoices.ErrFinalized},
+		{"finalize twice", invoices.Finalize(st, inv.InvoiceID, ""), invoices.ErrFinalized},
+		{"unknown invoice", invoices.Finalize(st, "nope", ""), invoices.ErrNotFound},
+	}
+	for _, tt := range tests {
+		if tt.err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+
+	// Reopening unlocks the month again
+	if err := invoices.Reopen(st, inv.InvoiceID); err != nil {
+		t.Fatal(err)
+	}
+	if err := invoices.CheckUnlocked(st, userID, date(2025, 4, 10), date(2025, 4, 10)); err != nil {
+		t.Errorf("reopened invoice still locks: %v", err)
+	}
+	if err := invoices.Reopen(st, inv.InvoiceID); err != invoices.ErrNotFinalized {
+		t.Errorf("reopen twice: got %v, want %v", err, invoices.ErrNotFinalized)
+	}
+}
+
+func generateErr(st store.Stores, userID string) error {
+	_, err := invoices.Generate(st, userID, 2025, time.April)
+	return err
+}
+
+func TestPriceListLock(t *testing.T) {
+	st := store.NewMemory().Stores()
+	lakeView, _ := st.Apartments.CreateApartment("Lake View")
+	hillTop, _ := st.Apartments.CreateApartment("Hill Top")
+	empty, _ := st.Apartments.CreateApartment("Green Park")
+	productID, _ := st.Products.CreateProduct(models.Product{ProductName: "Milk", Unit: "L", CurrentPrice: 30})
+
+	// Asha lives in Lake View, Ravi lived in Hill Top until he moved there on
+	// May 1st; both have a finalized April invoice
+	asha, _ := st.Customers.CreateCustomer(models.User{Name: "Asha", ApartmentID: lakeView})
+	ravi, _ := st.Customers.CreateCustomer(models.User{Name: "Ravi", ApartmentID: hillTop})
+	for _, userID := range []string{asha, ravi} {
+		err := st.Orders.ReplaceDefaults(userID, store.ModeNormal, civil.Date{}, civil.Date{}, []store.DefaultItem{{ProductID: productID, Quantity: 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		inv, err := invoices.Generate(st, userID, 2025, time.April)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := invoices.Finalize(st, inv.InvoiceID, "admin-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.Customers.MoveCustomer(store.CustomerMove{UserID: ravi, ToApartmentID: lakeView, MovedOn: date(2025, 5, 1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		e    store.PriceListEntry
+		want error
+	}{
+		{"apartment in the month", store.PriceListEntry{ApartmentID: lakeView, EffectiveFrom: date(2025, 4, 10), EffectiveTo: date(2025, 4, 20)}, invoices.ErrLocked},
+		{"apartment before the month", store.PriceListEntry{ApartmentID: lakeView, EffectiveFrom: date(2025, 3, 1), EffectiveTo: date(2025, 3, 31)}, nil},
+		{"apartment moved out of", store.PriceListEntry{ApartmentID: hillTop, EffectiveFrom: date(2025, 4, 10)}, invoices.ErrLocked},
+		{"apartment after the move", store.PriceListEntry{ApartmentID: hillTop, EffectiveFrom: date(2025, 5, 1)}, nil},
+		{"apartment nobody lived in", store.PriceListEntry{ApartmentID: empty, EffectiveFrom: date(2025, 4, 1)}, nil},
+		{"customer in the month", store.PriceListEntry{UserID: ravi, EffectiveFrom: date(2025, 4, 1), EffectiveTo: date(2025, 4, 5)}, invoices.ErrLocked},
+		{"customer after the month", store.PriceListEntry{UserID: ravi, EffectiveFrom: date(2025, 5, 1)}, nil},
+	}
+	for _, tt := range tests {
+		tt.e.ProductID = productID
+		if err := invoices.CheckPriceListChange(st, tt.e); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
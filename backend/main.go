@@ -2,10 +2,16 @@ package main
 
 import (
 	"backend/config"
+	"backend/handlers"
+	"backend/migrations"
+	"backend/retention"
 	"backend/routes"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 	"github.com/gorilla/mux"
 )
 
@@ -20,7 +26,7 @@ func enableCORS(next http.Handler) http.Handler {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*") // Change "*" to specific origins if needed
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Bootstrap-Token")
 
 		// Handle preflight requests
 		if r.Method == http.MethodOptions {
@@ -36,11 +42,35 @@ func main() {
 	// Connect to database
 	config.ConnectDatabase()
 
+	// `backend migrate ...` only manages the schema
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	// Bring the schema up to date unless MIGRATE_ON_START=false
+	if os.Getenv("MIGRATE_ON_START") != "false" {
+		if _, err := migrations.Up(config.DB); err != nil {
+			log.Fatalf("❌ Migration failed: %v", err)
+		}
+	}
+
+	config.LoadAuth()
+	config.LoadBusiness()
+	config.LoadOrders()
+
 
 	router := mux.NewRouter()
 
 	// Load API routes
-	routes.RegisterRoutes(router)
+	server := handlers.NewServer(config.DB)
+	routes.RegisterRoutes(router, server)
+
+	// Archive expired order modifications once a day
+	if config.ModificationRetention > 0 {
+		job := retention.Job{Orders: server.Orders, Keep: config.ModificationRetention, Location: config.Location}
+		job.Start(context.Background(), 24*time.Hour)
+	}
 
 	// Wrap the router with CORS middleware
 	handlerWithCORS := enableCORS(router)
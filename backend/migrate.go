@@ -0,0 +1,55 @@
+package main
+
+import (
+	"backend/config"
+	"backend/migrations"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// runMigrate handles `backend migrate up|down [steps]|status`.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		args = []string{"up"}
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(config.DB)
+		if err != nil {
+			log.Fatalf("❌ Migration failed: %v", err)
+		}
+		fmt.Printf("✅ %d migration(s) applied\n", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("❌ Invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrations.Down(config.DB, steps)
+		if err != nil {
+			log.Fatalf("❌ Rollback failed: %v", err)
+		}
+		fmt.Printf("✅ %d migration(s) reverted\n", len(reverted))
+	case "status":
+		states, err := migrations.Status(config.DB)
+		if err != nil {
+			log.Fatalf("❌ Could not read migration status: %v", err)
+		}
+		for _, s := range states {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-20s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "usage: backend migrate up | down [steps] | status")
+		os.Exit(2)
+	}
+}
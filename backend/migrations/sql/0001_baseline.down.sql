@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS alternating_order_modifications;
+DROP TABLE IF EXISTS order_modifications;
+DROP TABLE IF EXISTS alternating_default_order_items;
+DROP TABLE IF EXISTS default_order_items;
+DROP TABLE IF EXISTS product_price_history;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS apartments;
+DROP TABLE IF EXISTS admin;
@@ -0,0 +1,94 @@
+-- Baseline schema: every table the application used before migrations
+-- existed. IF NOT EXISTS lets it run as a no-op against databases that were
+-- created by hand.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE IF NOT EXISTS admin (
+    admin_id      UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    username      TEXT NOT NULL,
+    password_hash TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS apartments (
+    apartment_id   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    apartment_name TEXT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    user_id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name                 TEXT NOT NULL,
+    apartment_id         UUID NOT NULL REFERENCES apartments (apartment_id) ON DELETE CASCADE,
+    room_number          TEXT NOT NULL DEFAULT '',
+    phone_number         TEXT NOT NULL DEFAULT '',
+    email                TEXT NOT NULL DEFAULT '',
+    priority_order       INT NOT NULL DEFAULT 0,
+    is_alternating_order BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS users_apartment_priority_idx ON users (apartment_id, priority_order);
+
+CREATE TABLE IF NOT EXISTS products (
+    product_id    UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_name  TEXT NOT NULL,
+    unit          TEXT NOT NULL DEFAULT '',
+    current_price NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    image_url     TEXT NOT NULL DEFAULT '',
+    acronym       TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS product_price_history (
+    price_id       UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id     UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    old_price      NUMERIC(10, 2) NOT NULL,
+    new_price      NUMERIC(10, 2) NOT NULL,
+    effective_from DATE NOT NULL,
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_price_history_product_idx ON product_price_history (product_id, effective_from);
+
+CREATE TABLE IF NOT EXISTS default_order_items (
+    user_id    UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    quantity   NUMERIC(10, 3) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS default_order_items_user_idx ON default_order_items (user_id);
+
+CREATE TABLE IF NOT EXISTS alternating_default_order_items (
+    user_id    UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    quantity   NUMERIC(10, 3) NOT NULL,
+    day_type   TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS alternating_default_order_items_user_idx ON alternating_default_order_items (user_id);
+
+CREATE TABLE IF NOT EXISTS order_modifications (
+    modification_id   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id          UUID NOT NULL,
+    user_id           UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    product_id        UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    modified_quantity NUMERIC(10, 3) NOT NULL,
+    start_date        DATE NOT NULL,
+    end_date          DATE NOT NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS order_modifications_user_dates_idx ON order_modifications (user_id, start_date, end_date);
+
+CREATE TABLE IF NOT EXISTS alternating_order_modifications (
+    modification_id   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id          UUID NOT NULL,
+    user_id           UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    product_id        UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    modified_quantity NUMERIC(10, 3) NOT NULL,
+    start_date        DATE NOT NULL,
+    end_date          DATE NOT NULL,
+    day_type          TEXT NOT NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS alternating_order_modifications_user_dates_idx ON alternating_order_modifications (user_id, start_date, end_date);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS admin_apartments;
+DROP INDEX IF EXISTS admin_username_key;
+
+ALTER TABLE admin
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS is_active,
+    DROP COLUMN IF EXISTS role;
@@ -0,0 +1,15 @@
+-- Roles and account status for admins, plus apartment assignments for
+-- delivery staff. Existing admins become owners.
+ALTER TABLE admin
+    ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'owner'
+        CHECK (role IN ('owner', 'manager', 'delivery')),
+    ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE UNIQUE INDEX IF NOT EXISTS admin_username_key ON admin (username);
+
+CREATE TABLE IF NOT EXISTS admin_apartments (
+    admin_id     UUID NOT NULL REFERENCES admin (admin_id) ON DELETE CASCADE,
+    apartment_id UUID NOT NULL REFERENCES apartments (apartment_id) ON DELETE CASCADE,
+    PRIMARY KEY (admin_id, apartment_id)
+);
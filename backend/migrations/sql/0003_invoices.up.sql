@@ -0,0 +1,28 @@
+-- Stored monthly invoices. A finalized invoice is frozen: its lines no longer
+-- follow later edits to modifications or prices, and the customer's orders in
+-- that month are locked until it is reopened.
+CREATE TABLE IF NOT EXISTS invoices (
+    invoice_id   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id      UUID NOT NULL REFERENCES users (user_id),
+    year         INT NOT NULL,
+    month        INT NOT NULL CHECK (month BETWEEN 1 AND 12),
+    status       TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'finalized')),
+    total_amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finalized_at TIMESTAMPTZ,
+    finalized_by UUID REFERENCES admin (admin_id) ON DELETE SET NULL,
+    UNIQUE (user_id, year, month)
+);
+
+CREATE TABLE IF NOT EXISTS invoice_lines (
+    line_id        UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id     UUID NOT NULL REFERENCES invoices (invoice_id) ON DELETE CASCADE,
+    delivery_date  DATE NOT NULL,
+    product_id     UUID NOT NULL REFERENCES products (product_id),
+    quantity       NUMERIC(12, 3) NOT NULL,
+    price_per_unit NUMERIC(12, 2) NOT NULL,
+    total_price    NUMERIC(12, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS invoice_lines_invoice_idx ON invoice_lines (invoice_id);
+CREATE INDEX IF NOT EXISTS invoice_lines_product_date_idx ON invoice_lines (product_id, delivery_date);
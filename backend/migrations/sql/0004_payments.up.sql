@@ -0,0 +1,14 @@
+-- Payments received from customers, optionally against a specific invoice.
+CREATE TABLE IF NOT EXISTS payments (
+    payment_id   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id      UUID NOT NULL REFERENCES users (user_id),
+    invoice_id   UUID REFERENCES invoices (invoice_id) ON DELETE SET NULL,
+    amount       NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    payment_date DATE NOT NULL,
+    mode         TEXT NOT NULL CHECK (mode IN ('cash', 'upi', 'bank')),
+    reference    TEXT NOT NULL DEFAULT '',
+    recorded_by  UUID REFERENCES admin (admin_id) ON DELETE SET NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS payments_user_date_idx ON payments (user_id, payment_date);
@@ -0,0 +1,6 @@
+-- Each alternating customer counts ODD/EVEN days from their own anchor date.
+-- Customers already on alternating delivery keep the old global anchor.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS alternating_anchor DATE;
+
+UPDATE users SET alternating_anchor = DATE '2024-01-01'
+ WHERE is_alternating_order AND alternating_anchor IS NULL;
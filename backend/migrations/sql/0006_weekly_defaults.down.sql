@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS weekly_default_order_items;
+ALTER TABLE users DROP COLUMN IF EXISTS order_mode;
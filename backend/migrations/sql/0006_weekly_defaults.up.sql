@@ -0,0 +1,17 @@
+-- A customer's default order is normal, alternating (ODD/EVEN days) or
+-- weekly (one set of items per weekday). is_alternating_order stays in sync
+-- with order_mode for older clients.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS order_mode TEXT NOT NULL DEFAULT 'normal'
+        CHECK (order_mode IN ('normal', 'alternating', 'weekly'));
+
+UPDATE users SET order_mode = 'alternating' WHERE is_alternating_order;
+
+CREATE TABLE IF NOT EXISTS weekly_default_order_items (
+    user_id    UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    quantity   NUMERIC(10, 3) NOT NULL,
+    weekday    TEXT NOT NULL CHECK (weekday IN ('MON', 'TUE', 'WED', 'THU', 'FRI', 'SAT', 'SUN'))
+);
+
+CREATE INDEX IF NOT EXISTS weekly_default_order_items_user_idx ON weekly_default_order_items (user_id);
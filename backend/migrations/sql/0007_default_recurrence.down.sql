@@ -0,0 +1 @@
+ALTER TABLE default_order_items DROP COLUMN IF EXISTS recurrence;
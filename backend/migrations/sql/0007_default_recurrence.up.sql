@@ -0,0 +1,3 @@
+-- Items of a normal default order may carry a recurrence rule (an RRULE
+-- subset, see package recurrence); NULL keeps the item due every day.
+ALTER TABLE default_order_items ADD COLUMN IF NOT EXISTS recurrence TEXT;
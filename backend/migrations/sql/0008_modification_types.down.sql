@@ -0,0 +1,2 @@
+DELETE FROM order_modifications WHERE modification_type <> 'replace';
+ALTER TABLE order_modifications DROP COLUMN IF EXISTS modification_type;
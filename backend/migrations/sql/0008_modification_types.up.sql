@@ -0,0 +1,6 @@
+-- A modification batch either replaces the whole day (the original
+-- behaviour), adds extra quantities on top of it, or overrides single
+-- products while the others stay as resolved.
+ALTER TABLE order_modifications
+    ADD COLUMN IF NOT EXISTS modification_type TEXT NOT NULL DEFAULT 'replace'
+        CHECK (modification_type IN ('replace', 'extra', 'override'));
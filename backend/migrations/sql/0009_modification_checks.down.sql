@@ -0,0 +1,7 @@
+ALTER TABLE alternating_order_modifications
+    DROP CONSTRAINT IF EXISTS alternating_order_modifications_dates_check,
+    DROP CONSTRAINT IF EXISTS alternating_order_modifications_quantity_check;
+
+ALTER TABLE order_modifications
+    DROP CONSTRAINT IF EXISTS order_modifications_dates_check,
+    DROP CONSTRAINT IF EXISTS order_modifications_quantity_check;
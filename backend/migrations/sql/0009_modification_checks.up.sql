@@ -0,0 +1,10 @@
+-- The handlers validate modifications before writing them; these checks keep
+-- bad rows out even if something else writes to the tables. NOT VALID leaves
+-- any old rows alone.
+ALTER TABLE order_modifications
+    ADD CONSTRAINT order_modifications_quantity_check CHECK (modified_quantity >= 0) NOT VALID,
+    ADD CONSTRAINT order_modifications_dates_check CHECK (start_date <= end_date) NOT VALID;
+
+ALTER TABLE alternating_order_modifications
+    ADD CONSTRAINT alternating_order_modifications_quantity_check CHECK (modified_quantity >= 0) NOT VALID,
+    ADD CONSTRAINT alternating_order_modifications_dates_check CHECK (start_date <= end_date) NOT VALID;
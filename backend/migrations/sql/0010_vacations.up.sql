@@ -0,0 +1,15 @@
+-- A vacation pauses a customer's deliveries over a date range: every product
+-- when product_ids is empty, otherwise only the listed ones. Delivery resumes
+-- by itself after end_date.
+CREATE TABLE IF NOT EXISTS vacations (
+    vacation_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id     UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    start_date  DATE NOT NULL,
+    end_date    DATE NOT NULL,
+    reason      TEXT NOT NULL DEFAULT '',
+    product_ids UUID[] NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (start_date <= end_date)
+);
+
+CREATE INDEX IF NOT EXISTS vacations_user_dates_idx ON vacations (user_id, start_date, end_date);
@@ -0,0 +1,15 @@
+-- Changes made after the order cut-off, either forced by an admin with a
+-- reason or let through and flagged for review.
+CREATE TABLE IF NOT EXISTS cutoff_overrides (
+    override_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    admin_id    UUID REFERENCES admin (admin_id) ON DELETE SET NULL,
+    user_id     UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    start_date  DATE NOT NULL,
+    end_date    DATE NOT NULL,
+    action      TEXT NOT NULL,
+    reason      TEXT NOT NULL DEFAULT '',
+    forced      BOOLEAN NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS cutoff_overrides_created_idx ON cutoff_overrides (created_at);
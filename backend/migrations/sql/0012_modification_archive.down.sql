@@ -0,0 +1 @@
+DROP TABLE IF EXISTS modification_archive;
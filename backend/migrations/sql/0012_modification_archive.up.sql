@@ -0,0 +1,18 @@
+-- Expired modifications of both kinds move here instead of being deleted, so
+-- a reopened invoice still bills the orders it was built from.
+CREATE TABLE IF NOT EXISTS modification_archive (
+    modification_id   UUID PRIMARY KEY,
+    order_id          UUID NOT NULL,
+    user_id           UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    product_id        UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    modified_quantity NUMERIC(10, 3) NOT NULL,
+    start_date        DATE NOT NULL,
+    end_date          DATE NOT NULL,
+    alternating       BOOLEAN NOT NULL,
+    day_type          TEXT NOT NULL DEFAULT '',
+    modification_type TEXT NOT NULL DEFAULT 'replace',
+    created_at        TIMESTAMPTZ NOT NULL,
+    archived_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS modification_archive_user_dates_idx ON modification_archive (user_id, start_date, end_date);
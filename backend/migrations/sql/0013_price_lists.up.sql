@@ -0,0 +1,17 @@
+-- Negotiated prices that override a product's base price for one apartment
+-- or one customer. A customer entry wins over an apartment entry, which wins
+-- over the price history.
+CREATE TABLE IF NOT EXISTS price_list_entries (
+    entry_id       UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id     UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    apartment_id   UUID REFERENCES apartments (apartment_id) ON DELETE CASCADE,
+    user_id        UUID REFERENCES users (user_id) ON DELETE CASCADE,
+    price          NUMERIC(10, 2) NOT NULL CHECK (price >= 0),
+    effective_from DATE NOT NULL,
+    effective_to   DATE,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((apartment_id IS NULL) <> (user_id IS NULL)),
+    CHECK (effective_to IS NULL OR effective_to >= effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS price_list_entries_product_idx ON price_list_entries (product_id, effective_from);
@@ -0,0 +1,3 @@
+ALTER TABLE users      DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE products   DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE apartments DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,5 @@
+-- Customers, products and apartments are archived instead of deleted, so the
+-- bills and price history that refer to them stay intact. NULL means active.
+ALTER TABLE users      ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+ALTER TABLE products   ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+ALTER TABLE apartments ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
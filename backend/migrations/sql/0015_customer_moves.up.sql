@@ -0,0 +1,16 @@
+-- Customers moving from one apartment to another. Deliveries before moved_on
+-- belong to the old apartment, so its prices still apply to them.
+CREATE TABLE IF NOT EXISTS customer_moves (
+    move_id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id           UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    from_apartment_id UUID NOT NULL REFERENCES apartments (apartment_id) ON DELETE CASCADE,
+    to_apartment_id   UUID NOT NULL REFERENCES apartments (apartment_id) ON DELETE CASCADE,
+    from_priority     INT NOT NULL,
+    to_priority       INT NOT NULL,
+    moved_on          DATE NOT NULL,
+    admin_id          UUID REFERENCES admin (admin_id) ON DELETE SET NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (from_apartment_id <> to_apartment_id)
+);
+
+CREATE INDEX IF NOT EXISTS customer_moves_user_idx ON customer_moves (user_id, moved_on);
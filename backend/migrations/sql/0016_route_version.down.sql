@@ -0,0 +1 @@
+ALTER TABLE apartments DROP COLUMN IF EXISTS route_version;
@@ -0,0 +1,3 @@
+-- Counts changes to an apartment's delivery order, so a reorder made from an
+-- outdated copy of the list can be refused.
+ALTER TABLE apartments ADD COLUMN IF NOT EXISTS route_version INT NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- The original types of hand-made columns are not recorded, and DATE reads
+-- back the same everywhere, so the columns stay DATE.
+SELECT 1;
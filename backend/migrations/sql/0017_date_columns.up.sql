@@ -0,0 +1,41 @@
+-- Databases created by hand before migrations existed may keep delivery and
+-- price dates as TEXT or timestamps, which 0001 leaves untouched. Convert
+-- every date column to DATE. Timestamps hold UTC midnights, so their UTC date
+-- is taken; text keeps its first ten characters, the YYYY-MM-DD part.
+DO $$
+DECLARE
+    col RECORD;
+BEGIN
+    FOR col IN
+        SELECT table_name, column_name, data_type
+        FROM information_schema.columns
+        WHERE table_schema = current_schema()
+          AND data_type <> 'date'
+          AND (table_name, column_name) IN (
+              ('order_modifications', 'start_date'),
+              ('order_modifications', 'end_date'),
+              ('alternating_order_modifications', 'start_date'),
+              ('alternating_order_modifications', 'end_date'),
+              ('modification_archive', 'start_date'),
+              ('modification_archive', 'end_date'),
+              ('vacations', 'start_date'),
+              ('vacations', 'end_date'),
+              ('cutoff_overrides', 'start_date'),
+              ('cutoff_overrides', 'end_date'),
+              ('product_price_history', 'effective_from'),
+              ('price_list_entries', 'effective_from'),
+              ('price_list_entries', 'effective_to'),
+              ('users', 'alternating_anchor'),
+              ('customer_moves', 'moved_on'))
+    LOOP
+        EXECUTE format(
+            'ALTER TABLE %I ALTER COLUMN %I TYPE DATE USING %s',
+            col.table_name, col.column_name,
+            CASE col.data_type
+                WHEN 'timestamp with time zone' THEN format('(%I AT TIME ZONE ''UTC'')::date', col.column_name)
+                WHEN 'timestamp without time zone' THEN format('%I::date', col.column_name)
+                ELSE format('left(%I::text, 10)::date', col.column_name)
+            END);
+    END LOOP;
+END
+$$;
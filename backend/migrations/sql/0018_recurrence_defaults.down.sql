@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS alternating_default_order_items (
+    user_id    UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    quantity   NUMERIC(10, 3) NOT NULL,
+    day_type   TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS alternating_default_order_items_user_idx ON alternating_default_order_items (user_id);
+
+CREATE TABLE IF NOT EXISTS weekly_default_order_items (
+    user_id    UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    quantity   NUMERIC(10, 3) NOT NULL,
+    weekday    TEXT NOT NULL CHECK (weekday IN ('MON', 'TUE', 'WED', 'THU', 'FRI', 'SAT', 'SUN'))
+);
+
+CREATE INDEX IF NOT EXISTS weekly_default_order_items_user_idx ON weekly_default_order_items (user_id);
+
+INSERT INTO alternating_default_order_items (user_id, product_id, quantity, day_type)
+SELECT d.user_id, d.product_id, d.quantity,
+       CASE WHEN (to_date(substring(d.recurrence FROM 'DTSTART=([0-9]{8})'), 'YYYYMMDD') -
+                  COALESCE(u.alternating_anchor, DATE '2024-01-01')) % 2 = 0
+            THEN 'EVEN' ELSE 'ODD' END
+  FROM default_order_items d
+  JOIN users u ON u.user_id = d.user_id
+ WHERE u.order_mode = 'alternating' AND d.recurrence LIKE 'FREQ=DAILY;INTERVAL=2;DTSTART=%';
+
+INSERT INTO weekly_default_order_items (user_id, product_id, quantity, weekday)
+SELECT d.user_id, d.product_id, d.quantity, w.weekday
+  FROM default_order_items d
+  JOIN users u ON u.user_id = d.user_id
+  JOIN (VALUES ('MO', 'MON'), ('TU', 'TUE'), ('WE', 'WED'), ('TH', 'THU'),
+               ('FR', 'FRI'), ('SA', 'SAT'), ('SU', 'SUN')) AS w (byday, weekday)
+    ON d.recurrence = 'FREQ=WEEKLY;BYDAY=' || w.byday
+ WHERE u.order_mode = 'weekly';
+
+DELETE FROM default_order_items d
+ USING users u
+ WHERE d.user_id = u.user_id AND u.order_mode <> 'normal';
@@ -0,0 +1,25 @@
+-- Alternating and weekly default items become recurrence rules on
+-- default_order_items: EVEN items are due every second day from the
+-- customer's alternating_anchor and ODD items every second day from the day
+-- after it; weekly items are due on their weekday.
+DELETE FROM default_order_items d
+ USING users u
+ WHERE d.user_id = u.user_id AND u.order_mode <> 'normal';
+
+INSERT INTO default_order_items (user_id, product_id, quantity, recurrence)
+SELECT a.user_id, a.product_id, a.quantity,
+       'FREQ=DAILY;INTERVAL=2;DTSTART=' ||
+       to_char(COALESCE(u.alternating_anchor, DATE '2024-01-01') +
+               CASE WHEN a.day_type = 'ODD' THEN 1 ELSE 0 END, 'YYYYMMDD')
+  FROM alternating_default_order_items a
+  JOIN users u ON u.user_id = a.user_id
+ WHERE u.order_mode = 'alternating' AND a.day_type IN ('ODD', 'EVEN');
+
+INSERT INTO default_order_items (user_id, product_id, quantity, recurrence)
+SELECT wd.user_id, wd.product_id, wd.quantity, 'FREQ=WEEKLY;BYDAY=' || left(wd.weekday, 2)
+  FROM weekly_default_order_items wd
+  JOIN users u ON u.user_id = wd.user_id
+ WHERE u.order_mode = 'weekly';
+
+DROP TABLE IF EXISTS alternating_default_order_items;
+DROP TABLE IF EXISTS weekly_default_order_items;
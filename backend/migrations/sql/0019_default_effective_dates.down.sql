@@ -0,0 +1,6 @@
+DELETE FROM default_order_items
+ WHERE effective_from > CURRENT_DATE OR effective_to < CURRENT_DATE;
+
+ALTER TABLE default_order_items
+    DROP COLUMN IF EXISTS effective_to,
+    DROP COLUMN IF EXISTS effective_from;
@@ -0,0 +1,6 @@
+-- Default items are in force from effective_from through effective_to, so a
+-- changed default order leaves the days before the change, and the invoices
+-- billing them, as they were. NULL leaves that end open.
+ALTER TABLE default_order_items
+    ADD COLUMN IF NOT EXISTS effective_from DATE,
+    ADD COLUMN IF NOT EXISTS effective_to DATE;
@@ -1,10 +1,16 @@
 package models
 
+import "backend/civil"
+
 // Apartment model
  type Apartment struct {
 	ApartmentID   string `json:"apartment_id"`
 	ApartmentName string `json:"apartment_name"`
 	CreatedAt     string `json:"created_at"`
+	Archived      bool   `json:"archived"`
+	ArchivedAt    string `json:"archived_at,omitempty"`
+	// RouteVersion goes up on every change to the delivery order.
+	RouteVersion int `json:"route_version"`
 }
 
 // User model
@@ -17,7 +23,11 @@ package models
 	Email       string `json:"email,omitempty"` 
 	PriorityOrder int    `json:"priority_order"`
 	IsAlternatingOrder bool `json:"is_alternating_order"`
+	OrderMode   string `json:"order_mode"` // "normal", "alternating" or "weekly"
+	AlternatingAnchor civil.Date `json:"alternating_anchor"` // alternating customers only, null otherwise
 	CreatedAt   string `json:"created_at"`
+	Archived    bool   `json:"archived"`
+	ArchivedAt  string `json:"archived_at,omitempty"`
 }
 
 // Product model
@@ -29,6 +39,8 @@ package models
 	CreatedAt   string  `json:"created_at"`
 	ImageURL    string  `json:"image_url"`
 	Acronym     string 	`json:"acronym"`
+	Archived    bool    `json:"archived"`
+	ArchivedAt  string  `json:"archived_at,omitempty"`
 }
 
 // ProductPriceHistory model
@@ -37,7 +49,7 @@ package models
 	ProductID    string  `json:"product_id"`
 	OldPrice     float64 `json:"old_price"`
 	NewPrice     float64 `json:"new_price"`
-	EffectiveFrom string `json:"effective_from"`
+	EffectiveFrom civil.Date `json:"effective_from"`
 	UpdatedAt    string  `json:"updated_at"`
 }
 
@@ -62,8 +74,8 @@ type OrderModification struct {
 	UserID           string  `json:"user_id"`  
 	ProductID        string  `json:"product_id"`
 	ModifiedQuantity float64 `json:"modified_quantity"`
-	StartDate        string  `json:"start_date"`
-	EndDate          string  `json:"end_date"`
+	StartDate        civil.Date `json:"start_date"`
+	EndDate          civil.Date `json:"end_date"`
 	OrderID       string  `json:"order_id"`
 	CreatedAt        string  `json:"created_at"`
 }
@@ -74,18 +86,58 @@ type AlternatingOrderModification struct {
 	ProductID        string  `json:"product_id"`
 	ModifiedQuantity float64 `json:"modified_quantity"`
 	DayType          string  `json:"day_type"` // "ODD", "EVEN", or "CUSTOM"
-	StartDate        string  `json:"start_date"`
-	EndDate          string  `json:"end_date"`
+	StartDate        civil.Date `json:"start_date"`
+	EndDate          civil.Date `json:"end_date"`
 	OrderID       	 string  `json:"order_id"`
 	CreatedAt        string  `json:"created_at"`
 }
 
 type Admin struct {
-	AdminID      string `json:"admin_id"`
-	Username     string `json:"username"`
-	PasswordHash string `json:"password_hash"`
+	AdminID      string   `json:"admin_id"`
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"-"`
+	Role         string   `json:"role"` // "owner", "manager" or "delivery"
+	IsActive     bool     `json:"is_active"`
+	ApartmentIDs []string `json:"apartment_ids"` // assigned apartments, delivery staff only
+	CreatedAt    string   `json:"created_at"`
+}
+
+
+// Invoice model: a stored monthly bill
+type Invoice struct {
+	InvoiceID   string        `json:"invoice_id"`
+	UserID      string        `json:"user_id"`
+	Year        int           `json:"year"`
+	Month       int           `json:"month"`
+	Status      string        `json:"status"` // "draft" or "finalized"
+	TotalAmount float64       `json:"total_amount"`
+	GeneratedAt string        `json:"generated_at"`
+	FinalizedAt string        `json:"finalized_at,omitempty"`
+	FinalizedBy string        `json:"finalized_by,omitempty"`
+	Lines       []InvoiceLine `json:"lines,omitempty"`
+}
+
+type InvoiceLine struct {
+	LineID       string  `json:"line_id"`
+	DeliveryDate civil.Date `json:"delivery_date"`
+	ProductID    string  `json:"product_id"`
+	Quantity     float64 `json:"quantity"`
+	PricePerUnit float64 `json:"price_per_unit"`
+	TotalPrice   float64 `json:"total_price"`
 }
 
+// Payment model
+type Payment struct {
+	PaymentID   string  `json:"payment_id"`
+	UserID      string  `json:"user_id"`
+	InvoiceID   string  `json:"invoice_id,omitempty"`
+	Amount      float64 `json:"amount"`
+	PaymentDate civil.Date `json:"payment_date"`
+	Mode        string  `json:"mode"` // "cash", "upi" or "bank"
+	Reference   string  `json:"reference"`
+	RecordedBy  string  `json:"recorded_by,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+}
 
 // DailyOrderSummary model
 //  type DailyOrderSummary struct {
@@ -0,0 +1,123 @@
+package orders
+
+import (
+	"backend/civil"
+	"backend/store"
+	"fmt"
+	"sort"
+)
+
+// Load builds a plan for the given customers over [start, end].
+func Load(st store.Stores, userIDs []string, start, end civil.Date) (*Plan, error) {
+	if userIDs == nil {
+		userIDs = []string{}
+	}
+	return load(st, store.CustomerFilter{UserIDs: userIDs}, start, end)
+}
+
+// LoadApartment builds a plan for every customer of an apartment.
+func LoadApartment(st store.Stores, apartmentID string, start, end civil.Date) (*Plan, error) {
+	return load(st, store.CustomerFilter{ApartmentID: apartmentID}, start, end)
+}
+
+// LoadAll builds a plan for every customer.
+func LoadAll(st store.Stores, start, end civil.Date) (*Plan, error) {
+	return load(st, store.CustomerFilter{}, start, end)
+}
+
+// load makes five store calls, whatever the number of customers: the
+// customers matched by filter, their defaults, every modification row and
+// every vacation overlapping the range, and their moves between apartments.
+// Archived customers are included; they have nothing left to deliver but
+// still have their past deliveries.
+func load(st store.Stores, filter store.CustomerFilter, start, end civil.Date) (*Plan, error) {
+	p := &Plan{start: start, end: end, schedules: make(map[string]*schedule)}
+	filter.IncludeArchived = true
+
+	// 1) Customers and their order type, in delivery order
+	customers, err := st.Customers.ListCustomers(filter)
+	if err != nil {
+		return nil, fmt.Errorf("orders: load users: %w", err)
+	}
+	for _, c := range customers {
+		p.userIDs = append(p.userIDs, c.UserID)
+		p.schedules[c.UserID] = &schedule{mode: Mode(c), apartmentID: c.ApartmentID}
+	}
+	if len(p.userIDs) == 0 {
+		return p, nil
+	}
+
+	// 2) Default items and the days they are due on
+	defaults, err := st.Orders.Defaults(p.userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("orders: load defaults: %w", err)
+	}
+	for _, d := range defaults {
+		s, ok := p.schedules[d.UserID]
+		if !ok {
+			continue
+		}
+		due, err := dueFunc(d)
+		if err != nil {
+			return nil, fmt.Errorf("orders: default item %s of %s: %w", d.ProductID, d.UserID, err)
+		}
+		s.defaults = append(s.defaults, item{productID: d.ProductID, quantity: d.Quantity, due: due})
+	}
+
+	// 3) Every modification row, normal or alternating, overlapping the range
+	mods, err := st.Orders.Modifications(p.userIDs, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("orders: load modifications: %w", err)
+	}
+	byOrder := make(map[string]*batch)
+	for _, m := range mods {
+		s, ok := p.schedules[m.UserID]
+		if !ok {
+			continue
+		}
+		b, ok := byOrder[m.OrderID]
+		if !ok {
+			b = &batch{orderID: m.OrderID, kind: m.Kind, alternating: m.Alternating, start: m.StartDate, end: m.EndDate}
+			if b.kind == "" {
+				b.kind = store.KindReplace
+			}
+			byOrder[m.OrderID] = b
+			s.batches = append(s.batches, b)
+		}
+		// Rows of one batch used to be inserted one by one, so the batch
+		// counts as created when its last row was written.
+		if m.CreatedAt.After(b.createdAt) {
+			b.createdAt = m.CreatedAt
+		}
+		b.items = append(b.items, item{productID: m.ProductID, quantity: m.Quantity, dayType: m.DayType})
+	}
+
+	// 4) Vacations overlapping the range
+	vacations, err := st.Vacations.Vacations(p.userIDs, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("orders: load vacations: %w", err)
+	}
+	for _, v := range vacations {
+		if s, ok := p.schedules[v.UserID]; ok {
+			s.vacations = append(s.vacations, v)
+		}
+	}
+
+	// 5) Moves between apartments, to tell where each day was delivered
+	moves, err := st.Customers.CustomerMoves(p.userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("orders: load moves: %w", err)
+	}
+	for _, mv := range moves {
+		if s, ok := p.schedules[mv.UserID]; ok {
+			s.moves = append(s.moves, mv)
+		}
+	}
+
+	for _, s := range p.schedules {
+		sort.SliceStable(s.batches, func(i, j int) bool {
+			return s.batches[i].createdAt.After(s.batches[j].createdAt)
+		})
+	}
+	return p, nil
+}
@@ -0,0 +1,455 @@
+// Package orders decides what a customer actually receives on a given day.
+//
+// Every screen that shows deliveries (the monthly calendar, the monthly bill,
+// the apartment delivery sheet and the sales report) must agree on this, so the
+// rules live here and nowhere else:
+//
+//  1. The most recently created replace batch (from order_modifications or
+//     alternating_order_modifications) whose date range covers the day wins
+//     and replaces the whole day.
+//  2. Otherwise the customer's default order (default_order_items) applies.
+//     Every default item is due on the days its recurrence rule allows, or
+//     every day without one. Alternating items are rules due every second
+//     day from the customer's alternating_anchor (ODD items one day later),
+//     weekly items rules due on their weekday; see DayTypeRule. An item
+//     only applies between its effective dates, so a changed default order
+//     leaves the days before the change alone.
+//  3. Extra and override batches covering the day that were created after the
+//     batch of rule 1 (or at any time, when the day comes from the defaults)
+//     are then applied oldest first: an extra batch adds its quantities to the
+//     day, an override batch sets the quantity of its own products and leaves
+//     the others alone.
+//  4. Finally a vacation covering the day takes away the products it pauses,
+//     or the whole day when it pauses every product. Delivery resumes by
+//     itself once the vacation has ended.
+package orders
+
+import (
+	"backend/civil"
+	"backend/models"
+	"backend/recurrence"
+	"backend/store"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Source tells where the lines of a resolved day came from.
+type Source string
+
+const (
+	SourceDefault                 Source = "default"
+	SourceAlternatingDefault      Source = "alternating_default"
+	SourceWeeklyDefault           Source = "weekly_default"
+	SourceModification            Source = "modification"
+	SourceAlternatingModification Source = "alternating_modification"
+	SourceVacation                Source = "vacation"
+)
+
+// ErrUnknownUser is returned when the customer does not exist.
+var ErrUnknownUser = errors.New("orders: unknown user")
+
+// legacyAnchor is the reference day of alternating customers that have no
+// anchor of their own.
+var legacyAnchor = civil.Date{Year: 2024, Month: time.January, Day: 1}
+
+// Anchor returns the day a customer's alternating ODD/EVEN cycle counts from.
+// The anchor itself is an EVEN day.
+func Anchor(u models.User) civil.Date {
+	if u.AlternatingAnchor.IsZero() {
+		return legacyAnchor
+	}
+	return u.AlternatingAnchor
+}
+
+// NewAnchor returns the anchor of an alternating default order taking effect
+// on from: the stored one when the customer already orders alternately, so
+// editing the order does not shift its days, else from itself, so the new
+// schedule starts on an EVEN day.
+func NewAnchor(u models.User, from civil.Date) civil.Date {
+	if Mode(u) == store.ModeAlternating {
+		return Anchor(u)
+	}
+	return from
+}
+
+// Mode returns the customer's default order mode. Rows written before modes
+// existed only carry is_alternating_order.
+func Mode(u models.User) string {
+	if u.OrderMode != "" {
+		return u.OrderMode
+	}
+	if u.IsAlternatingOrder {
+		return store.ModeAlternating
+	}
+	return store.ModeNormal
+}
+
+// Weekday returns the MON..SUN day type of weekly default items on date.
+func Weekday(date civil.Date) string {
+	return store.Weekdays[(int(date.Weekday())+6)%7]
+}
+
+// Line is one product delivered on a day.
+type Line struct {
+	ProductID string  `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+}
+
+// Day is the resolved delivery of one customer on one date.
+type Day struct {
+	Date   civil.Date
+	Lines  []Line
+	Source Source
+}
+
+// DayType returns "EVEN" or "ODD" based on the number of calendar days
+// between ref and curr.
+func DayType(ref, curr civil.Date) string {
+	if curr.DaysSince(ref)%2 == 0 {
+		return "EVEN"
+	}
+	return "ODD"
+}
+
+// item is a single product row of a default order or modification batch.
+type item struct {
+	productID string
+	quantity  float64
+	dayType   string
+	due       func(civil.Date) bool // default items only; nil means every day
+}
+
+// batch groups the rows written by one ModifyOrder/PauseOrder/ResumeOrder/
+// ModifyAlternatingOrder call, identified by their shared order_id.
+type batch struct {
+	orderID     string
+	kind        string // store.KindReplace, KindExtra or KindOverride
+	alternating bool
+	start, end  civil.Date
+	createdAt   time.Time
+	items       []item
+}
+
+func (b *batch) covers(date civil.Date) bool {
+	return !date.Before(b.start) && !date.After(b.end)
+}
+
+// schedule holds everything needed to resolve a customer's days in memory.
+type schedule struct {
+	mode        string
+	apartmentID string               // where the customer lives now
+	moves       []store.CustomerMove // oldest first
+	defaults    []item
+	batches     []*batch // newest first
+	vacations   []store.Vacation
+}
+
+// resolve applies the resolution rules to a single date.
+func (s *schedule) resolve(date civil.Date) ([]Line, Source) {
+	lines, src := s.planned(date)
+	for _, v := range s.vacations {
+		if date.Before(v.StartDate) || date.After(v.EndDate) {
+			continue
+		}
+		if len(v.ProductIDs) == 0 {
+			return []Line{}, SourceVacation
+		}
+		lines = without(lines, v.ProductIDs)
+	}
+	return lines, src
+}
+
+// vacation returns the vacation covering date, if any.
+func (s *schedule) vacation(date civil.Date) (store.Vacation, bool) {
+	for _, v := range s.vacations {
+		if !date.Before(v.StartDate) && !date.After(v.EndDate) {
+			return v, true
+		}
+	}
+	return store.Vacation{}, false
+}
+
+// planned applies the rules before vacations to a single date.
+func (s *schedule) planned(date civil.Date) ([]Line, Source) {
+	// 1) The newest covering replace batch, or the defaults
+	var adjust []*batch // newest first
+	for _, b := range s.batches {
+		if !b.covers(date) {
+			continue
+		}
+		switch {
+		case b.kind != store.KindReplace:
+			adjust = append(adjust, b)
+		case !b.alternating:
+			return adjusted(positive(b.items, ""), adjust), SourceModification
+		default:
+			return adjusted(positive(b.items, DayType(b.start, date)), adjust), SourceAlternatingModification
+		}
+	}
+
+	lines := adjusted(positive(dueOn(s.defaults, date), ""), adjust)
+	switch s.mode {
+	case store.ModeAlternating:
+		return lines, SourceAlternatingDefault
+	case store.ModeWeekly:
+		return lines, SourceWeeklyDefault
+	}
+	return lines, SourceDefault
+}
+
+// adjusted applies extra and override batches, given newest first, to the
+// lines of a day. Products keep their position; new ones are appended.
+func adjusted(lines []Line, batches []*batch) []Line {
+	if len(batches) == 0 {
+		return lines
+	}
+	for i := len(batches) - 1; i >= 0; i-- {
+		b := batches[i]
+		for _, it := range b.items {
+			j := 0
+			for j < len(lines) && lines[j].ProductID != it.productID {
+				j++
+			}
+			if j == len(lines) {
+				lines = append(lines, Line{ProductID: it.productID})
+			}
+			if b.kind == store.KindExtra {
+				lines[j].Quantity += it.quantity
+			} else {
+				lines[j].Quantity = it.quantity
+			}
+		}
+	}
+
+	kept := lines[:0]
+	for _, l := range lines {
+		if l.Quantity > 0 {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+// dueOn keeps the default items due on date.
+func dueOn(items []item, date civil.Date) []item {
+	due := make([]item, 0, len(items))
+	for _, it := range items {
+		if it.due == nil || it.due(date) {
+			due = append(due, it)
+		}
+	}
+	return due
+}
+
+// dueFunc tells on which days a default item is delivered.
+func dueFunc(it store.DefaultItem) (func(civil.Date) bool, error) {
+	var rule *recurrence.Rule
+	if it.Recurrence != "" {
+		var err error
+		if rule, err = recurrence.Parse(it.Recurrence); err != nil {
+			return nil, err
+		}
+	}
+	if rule == nil && it.EffectiveFrom.IsZero() && it.EffectiveTo.IsZero() {
+		return nil, nil
+	}
+	return func(date civil.Date) bool {
+		return inForce(it, date) && (rule == nil || rule.Due(date))
+	}, nil
+}
+
+// inForce reports whether date falls in the item's effective dates.
+func inForce(it store.DefaultItem, date civil.Date) bool {
+	return !date.Before(it.EffectiveFrom) && (it.EffectiveTo.IsZero() || !date.After(it.EffectiveTo))
+}
+
+// Latest returns the default items of the customer's latest change, the ones
+// in force from its effective date on.
+func Latest(items []store.DefaultItem) []store.DefaultItem {
+	var kept []store.DefaultItem
+	for _, it := range items {
+		if it.EffectiveTo.IsZero() {
+			kept = append(kept, it)
+		}
+	}
+	return kept
+}
+
+// DefaultsOn returns the default items that are in force and due on date.
+func DefaultsOn(items []store.DefaultItem, date civil.Date) ([]store.DefaultItem, error) {
+	var due []store.DefaultItem
+	for _, it := range items {
+		f, err := dueFunc(it)
+		if err != nil {
+			return nil, fmt.Errorf("orders: default item %s: %w", it.ProductID, err)
+		}
+		if f == nil || f(date) {
+			due = append(due, it)
+		}
+	}
+	return due, nil
+}
+
+// DayTypeRule returns the recurrence rule of an alternating item due on
+// dayType, EVEN or ODD, counted from anchor, or of a weekly item due on
+// dayType, MON..SUN. It returns "" when dayType does not fit the mode.
+func DayTypeRule(mode string, anchor civil.Date, dayType string) string {
+	switch {
+	case mode == store.ModeAlternating && dayType == "EVEN":
+		return recurrence.EveryDays(2, anchor).String()
+	case mode == store.ModeAlternating && dayType == "ODD":
+		return recurrence.EveryDays(2, anchor.AddDays(1)).String()
+	case mode == store.ModeWeekly:
+		for i, d := range store.Weekdays {
+			if d == dayType {
+				return recurrence.OnWeekdays(time.Weekday((i + 1) % 7)).String()
+			}
+		}
+	}
+	return ""
+}
+
+// DayTypeOf returns the EVEN/ODD or MON..SUN day type of a default item of
+// an alternating or weekly customer, or "" when its rule is not one
+// DayTypeRule writes.
+func DayTypeOf(u models.User, it store.DefaultItem) string {
+	mode := Mode(u)
+	for _, dayType := range append([]string{"EVEN", "ODD"}, store.Weekdays...) {
+		if rule := DayTypeRule(mode, Anchor(u), dayType); rule != "" && rule == it.Recurrence {
+			return dayType
+		}
+	}
+	return ""
+}
+
+// without drops the lines of the given products.
+func without(lines []Line, productIDs []string) []Line {
+	kept := make([]Line, 0, len(lines))
+	for _, l := range lines {
+		paused := false
+		for _, id := range productIDs {
+			paused = paused || l.ProductID == id
+		}
+		if !paused {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+// positive keeps items with a quantity above zero, optionally restricted to a day type.
+func positive(items []item, dayType string) []Line {
+	lines := make([]Line, 0, len(items))
+	for _, it := range items {
+		if dayType != "" && it.dayType != dayType {
+			continue
+		}
+		if it.quantity > 0 {
+			lines = append(lines, Line{ProductID: it.productID, Quantity: it.quantity})
+		}
+	}
+	return lines
+}
+
+// Plan holds the resolved schedules of a set of customers over a date range.
+// It is built with a fixed number of queries, whatever the number of customers.
+type Plan struct {
+	start, end civil.Date
+	userIDs    []string
+	schedules  map[string]*schedule
+}
+
+// UserIDs returns the customers in the plan, in the order they were loaded.
+func (p *Plan) UserIDs() []string {
+	return p.userIDs
+}
+
+// UserIDsIn returns the customers of the plan who lived in the apartment on
+// date, in the order they were loaded; never nil, so it can filter customers.
+// Load every customer, not just the apartment's current ones, to include
+// those who have moved since.
+func (p *Plan) UserIDsIn(apartmentID string, date civil.Date) []string {
+	ids := []string{}
+	for _, id := range p.userIDs {
+		if p.ApartmentOn(id, date) == apartmentID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ApartmentOn returns the apartment the customer lived in on date, or "".
+// Moves recorded after date are undone, newest first.
+func (p *Plan) ApartmentOn(userID string, date civil.Date) string {
+	s, ok := p.schedules[userID]
+	if !ok {
+		return ""
+	}
+	apartmentID := s.apartmentID
+	for i := len(s.moves) - 1; i >= 0 && date.Before(s.moves[i].MovedOn); i-- {
+		apartmentID = s.moves[i].FromApartmentID
+	}
+	return apartmentID
+}
+
+// Has reports whether the customer is part of the plan.
+func (p *Plan) Has(userID string) bool {
+	_, ok := p.schedules[userID]
+	return ok
+}
+
+// Resolve returns what the customer receives on date. The date must lie
+// within the range the plan was loaded for.
+func (p *Plan) Resolve(userID string, date civil.Date) ([]Line, Source) {
+	s, ok := p.schedules[userID]
+	if !ok {
+		return []Line{}, SourceDefault
+	}
+	return s.resolve(date)
+}
+
+// Vacation returns the customer's vacation covering date, if any. The date
+// must lie within the range the plan was loaded for.
+func (p *Plan) Vacation(userID string, date civil.Date) (store.Vacation, bool) {
+	s, ok := p.schedules[userID]
+	if !ok {
+		return store.Vacation{}, false
+	}
+	return s.vacation(date)
+}
+
+// Days resolves every day of the plan's range for one customer.
+func (p *Plan) Days(userID string) []Day {
+	var days []Day
+	for date := p.start; !date.After(p.end); date = date.AddDays(1) {
+		lines, src := p.Resolve(userID, date)
+		days = append(days, Day{Date: date, Lines: lines, Source: src})
+	}
+	return days
+}
+
+// Resolve returns what the customer receives on date and where it came from.
+func Resolve(st store.Stores, userID string, date civil.Date) ([]Line, Source, error) {
+	p, err := Load(st, []string{userID}, date, date)
+	if err != nil {
+		return nil, "", err
+	}
+	if !p.Has(userID) {
+		return nil, "", ErrUnknownUser
+	}
+	lines, src := p.Resolve(userID, date)
+	return lines, src, nil
+}
+
+// ResolveRange resolves every day from start to end inclusive.
+func ResolveRange(st store.Stores, userID string, start, end civil.Date) ([]Day, error) {
+	p, err := Load(st, []string{userID}, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Has(userID) {
+		return nil, ErrUnknownUser
+	}
+	return p.Days(userID), nil
+}
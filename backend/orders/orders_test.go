@@ -0,0 +1,553 @@
+package orders_test
+
+import (
+	"backend/civil"
+	"backend/models"
+	"backend/orders"
+	"backend/store"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func date(s string) civil.Date {
+	d, err := civil.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// fixture is a customer with a default order of 1 milk and 2 curd, an
+// alternating one of 1 milk on EVEN days and 2 curd on ODD days, or a weekly
+// one of 1 milk on weekdays and 2 curd on Sundays.
+func fixture(t *testing.T, mode string) (store.Stores, string) {
+	t.Helper()
+	st := store.NewMemory().Stores()
+	aptID, _ := st.Apartments.CreateApartment("Lake View")
+	userID, err := st.Customers.CreateCustomer(models.User{Name: "Asha", ApartmentID: aptID, RoomNumber: "101"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchor := date("2024-01-01")
+	items := []store.DefaultItem{
+		{ProductID: "milk", Quantity: 1, Recurrence: orders.DayTypeRule(mode, anchor, "EVEN")},
+		{ProductID: "curd", Quantity: 2, Recurrence: orders.DayTypeRule(mode, anchor, "ODD")},
+	}
+	if mode == store.ModeWeekly {
+		items = items[:0]
+		for _, d := range []string{"MON", "TUE", "WED", "THU", "FRI"} {
+			items = append(items, store.DefaultItem{ProductID: "milk", Quantity: 1, Recurrence: orders.DayTypeRule(mode, anchor, d)})
+		}
+		items = append(items, store.DefaultItem{ProductID: "curd", Quantity: 2, Recurrence: orders.DayTypeRule(mode, anchor, "SUN")})
+	}
+	if err := st.Orders.ReplaceDefaults(userID, mode, anchor, civil.Date{}, items); err != nil {
+		t.Fatal(err)
+	}
+	return st, userID
+}
+
+func modify(t *testing.T, st store.Stores, mods ...store.Modification) {
+	t.Helper()
+	if _, err := st.Orders.AddModifications(mods); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		mods       [][]store.Modification // batches, oldest first
+		date       string
+		want       []orders.Line
+		wantSource orders.Source
+	}{
+		{
+			name:       "default order",
+			date:       "2025-03-10",
+			want:       []orders.Line{{ProductID: "curd", Quantity: 2}, {ProductID: "milk", Quantity: 1}},
+			wantSource: orders.SourceDefault,
+		},
+		{
+			name:       "alternating default on an EVEN day",
+			mode:       store.ModeAlternating,
+			date:       "2024-01-03",
+			want:       []orders.Line{{ProductID: "milk", Quantity: 1}},
+			wantSource: orders.SourceAlternatingDefault,
+		},
+		{
+			name:       "alternating default on an ODD day",
+			mode:       store.ModeAlternating,
+			date:       "2024-01-04",
+			want:       []orders.Line{{ProductID: "curd", Quantity: 2}},
+			wantSource: orders.SourceAlternatingDefault,
+		},
+		{
+			name:       "weekly default on a weekday",
+			mode:       store.ModeWeekly,
+			date:       "2025-03-12", // Wednesday
+			want:       []orders.Line{{ProductID: "milk", Quantity: 1}},
+			wantSource: orders.SourceWeeklyDefault,
+		},
+		{
+			name:       "weekly default on Sunday",
+			mode:       store.ModeWeekly,
+			date:       "2025-03-16",
+			want:       []orders.Line{{ProductID: "curd", Quantity: 2}},
+			wantSource: orders.SourceWeeklyDefault,
+		},
+		{
+			name:       "weekly default on a day without items",
+			mode:       store.ModeWeekly,
+			date:       "2025-03-15", // Saturday
+			want:       []orders.Line{},
+			wantSource: orders.SourceWeeklyDefault,
+		},
+		{
+			name: "modification replaces the whole day",
+			mods: [][]store.Modification{
+				{{ProductID: "milk", Quantity: 3, StartDate: date("2025-03-01"), EndDate: date("2025-03-31")}},
+			},
+			date:       "2025-03-10",
+			want:       []orders.Line{{ProductID: "milk", Quantity: 3}},
+			wantSource: orders.SourceModification,
+		},
+		{
+			name: "modification outside its range is ignored",
+			mods: [][]store.Modification{
+				{{ProductID: "milk", Quantity: 3, StartDate: date("2025-03-01"), EndDate: date("2025-03-05")}},
+			},
+			date:       "2025-03-10",
+			want:       []orders.Line{{ProductID: "curd", Quantity: 2}, {ProductID: "milk", Quantity: 1}},
+			wantSource: orders.SourceDefault,
+		},
+		{
+			name: "newest batch wins",
+			mods: [][]store.Modification{
+				{{ProductID: "milk", Quantity: 3, StartDate: date("2025-03-01"), EndDate: date("2025-03-31")}},
+				{{ProductID: "curd", Quantity: 5, StartDate: date("2025-03-10"), EndDate: date("2025-03-10")}},
+			},
+			date:       "2025-03-10",
+			want:       []orders.Line{{ProductID: "curd", Quantity: 5}},
+			wantSource: orders.SourceModification,
+		},
+		{
+			name: "pause delivers nothing",
+			mods: [][]store.Modification{
+				{{ProductID: "milk", Quantity: 0, StartDate: date("2025-03-08"), EndDate: date("2025-03-12")}},
+			},
+			date:       "2025-03-10",
+			want:       []orders.Line{},
+			wantSource: orders.SourceModification,
+		},
+		{
+			name: "extra adds on top of the default",
+			mods: [][]store.Modification{
+				{{ProductID: "curd", Quantity: 1, Kind: store.KindExtra, StartDate: date("2025-03-10"), EndDate: date("2025-03-10")}},
+			},
+			date:       "2025-03-10",
+			want:       []orders.Line{{ProductID: "curd", Quantity: 3}, {ProductID: "milk", Quantity: 1}},
+			wantSource: orders.SourceDefault,
+		},
+		{
+			name: "extra adds a new product on top of a modification",
+			mods: [][]store.Modification{
+				{{ProductID: "milk", Quantity: 3, StartDate: date("2025-03-01"), EndDate: date("2025-03-31")}},
+				{{ProductID: "ghee", Quantity: 1, Kind: store.KindExtra, StartDate: date("2025-03-10"), EndDate: date("2025-03-11")}},
+			},
+			date:       "2025-03-10",
+			want:       []orders.Line{{ProductID: "milk", Quantity: 3}, {ProductID: "ghee", Quantity: 1}},
+			wantSource: orders.SourceModification,
+		},
+		{
+			name: "override changes one product only",
+			mods: [][]store.Modification{
+				{{ProductID: "milk", Quantity: 0, Kind: store.KindOverride, StartDate: date("2025-03-01"), EndDate: date("2025-03-31")}},
+				{{ProductID: "curd", Quantity: 1, Kind: store.KindExtra, StartDate: date("2025-03-10"), EndDate: date("2025-03-10")}},
+				{{ProductID: "curd", Quantity: 4, Kind: store.KindOverride, StartDate: date("2025-03-10"), EndDate: date("2025-03-10")}},
+			},
+			date:       "2025-03-10",
+			want:       []orders.Line{{ProductID: "curd", Quantity: 4}},
+			wantSource: orders.SourceDefault,
+		},
+		{
+			name: "a newer replace batch drops older extras",
+			mods: [][]store.Modification{
+				{{ProductID: "curd", Quantity: 1, Kind: store.KindExtra, StartDate: date("2025-03-10"), EndDate: date("2025-03-10")}},
+				{{ProductID: "milk", Quantity: 0, StartDate: date("2025-03-08"), EndDate: date("2025-03-12")}},
+			},
+			date:       "2025-03-10",
+			want:       []orders.Line{},
+			wantSource: orders.SourceModification,
+		},
+		{
+			name: "alternating modification counts from its start date",
+			mods: [][]store.Modification{{
+				{ProductID: "milk", Quantity: 4, DayType: "EVEN", Alternating: true, StartDate: date("2025-03-01"), EndDate: date("2025-03-31")},
+				{ProductID: "curd", Quantity: 6, DayType: "ODD", Alternating: true, StartDate: date("2025-03-01"), EndDate: date("2025-03-31")},
+			}},
+			date:       "2025-03-02",
+			want:       []orders.Line{{ProductID: "curd", Quantity: 6}},
+			wantSource: orders.SourceAlternatingModification,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := tt.mode
+			if mode == "" {
+				mode = store.ModeNormal
+			}
+			st, userID := fixture(t, mode)
+			for _, b := range tt.mods {
+				for i := range b {
+					b[i].UserID = userID
+				}
+				modify(t, st, b...)
+			}
+
+			lines, src, err := orders.Resolve(st, userID, date(tt.date))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("lines = %v, want %v", lines, tt.want)
+			}
+			if src != tt.wantSource {
+				t.Errorf("source = %q, want %q", src, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestResolveAlternatingAnchor(t *testing.T) {
+	st, userID := fixture(t, store.ModeAlternating)
+	anchor := date("2025-03-05")
+	items := []store.DefaultItem{
+		{ProductID: "milk", Quantity: 1, Recurrence: orders.DayTypeRule(store.ModeAlternating, anchor, "EVEN")},
+		{ProductID: "curd", Quantity: 2, Recurrence: orders.DayTypeRule(store.ModeAlternating, anchor, "ODD")},
+	}
+	if err := st.Orders.ReplaceDefaults(userID, store.ModeAlternating, anchor, civil.Date{}, items); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2025-03-05", "milk"}, // the anchor is an EVEN day
+		{"2025-03-06", "curd"},
+		{"2025-03-07", "milk"},
+	}
+	for _, tt := range tests {
+		lines, _, err := orders.Resolve(st, userID, date(tt.date))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != 1 || lines[0].ProductID != tt.want {
+			t.Errorf("%s: lines = %v, want only %s", tt.date, lines, tt.want)
+		}
+	}
+
+	// The stored rules read back as their day types
+	u, err := st.Customers.GetCustomer(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := st.Orders.Defaults([]string{userID})
+	for _, it := range stored {
+		want := map[string]string{"milk": "EVEN", "curd": "ODD"}[it.ProductID]
+		if got := orders.DayTypeOf(*u, it); got != want {
+			t.Errorf("DayTypeOf(%s) = %q, want %q", it.ProductID, got, want)
+		}
+	}
+}
+
+func TestResolveRecurringDefaults(t *testing.T) {
+	st, userID := fixture(t, store.ModeNormal)
+	err := st.Orders.ReplaceDefaults(userID, store.ModeNormal, civil.Date{}, civil.Date{}, []store.DefaultItem{
+		{ProductID: "milk", Quantity: 1},
+		{ProductID: "paneer", Quantity: 1, Recurrence: "FREQ=DAILY;INTERVAL=3;DTSTART=20250301"},
+		{ProductID: "ghee", Quantity: 1, Recurrence: "FREQ=MONTHLY;BYMONTHDAY=1,15"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		date string
+		want []string
+	}{
+		{"2025-03-01", []string{"ghee", "milk", "paneer"}},
+		{"2025-03-02", []string{"milk"}},
+		{"2025-03-04", []string{"milk", "paneer"}},
+		{"2025-03-15", []string{"ghee", "milk"}},
+	}
+	for _, tt := range tests {
+		lines, src, err := orders.Resolve(st, userID, date(tt.date))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range lines {
+			got = append(got, l.ProductID)
+		}
+		if !reflect.DeepEqual(got, tt.want) || src != orders.SourceDefault {
+			t.Errorf("%s: got %v from %s, want %v from default", tt.date, got, src, tt.want)
+		}
+	}
+}
+
+func TestResolveChangedDefaults(t *testing.T) {
+	st, userID := fixture(t, store.ModeNormal)
+	change := func(from string, items ...store.DefaultItem) {
+		t.Helper()
+		if err := st.Orders.ReplaceDefaults(userID, store.ModeNormal, civil.Date{}, date(from), items); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Milk and curd until the 9th, paneer from the 10th, nothing from the
+	// 20th; a later change made first is dropped by the earlier one
+	change("2025-03-15", store.DefaultItem{ProductID: "ghee", Quantity: 1})
+	change("2025-03-10", store.DefaultItem{ProductID: "paneer", Quantity: 1})
+	change("2025-03-20")
+
+	tests := []struct {
+		date string
+		want []string
+	}{
+		{"2025-03-09", []string{"curd", "milk"}},
+		{"2025-03-10", []string{"paneer"}},
+		{"2025-03-19", []string{"paneer"}},
+		{"2025-03-20", nil},
+	}
+	for _, tt := range tests {
+		lines, _, err := orders.Resolve(st, userID, date(tt.date))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range lines {
+			got = append(got, l.ProductID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestNewAnchor(t *testing.T) {
+	// Switched to alternating on the 10th with the change taking effect on
+	// the 11th: the 11th is the first EVEN day, whatever today is
+	today, from := date("2025-03-10"), date("2025-03-11")
+	st, userID := fixture(t, store.ModeNormal)
+	u, _ := st.Customers.GetCustomer(userID)
+	anchor := orders.NewAnchor(*u, from)
+	if anchor != from {
+		t.Fatalf("NewAnchor = %s, want %s", anchor, from)
+	}
+	items := []store.DefaultItem{
+		{ProductID: "milk", Quantity: 1, Recurrence: orders.DayTypeRule(store.ModeAlternating, anchor, "EVEN")},
+		{ProductID: "curd", Quantity: 2, Recurrence: orders.DayTypeRule(store.ModeAlternating, anchor, "ODD")},
+	}
+	if err := st.Orders.ReplaceDefaults(userID, store.ModeAlternating, anchor, from, items); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		today.String():           {"curd", "milk"}, // the normal order, before the change
+		from.String():            {"milk"},
+		from.AddDays(1).String(): {"curd"},
+	}
+	for d, want := range tests {
+		lines, _, err := orders.Resolve(st, userID, date(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range lines {
+			got = append(got, l.ProductID)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", d, got, want)
+		}
+	}
+
+	// Editing the alternating order later keeps its anchor
+	u, _ = st.Customers.GetCustomer(userID)
+	if got := orders.NewAnchor(*u, date("2025-03-20")); got != anchor {
+		t.Errorf("NewAnchor after switching = %s, want %s", got, anchor)
+	}
+}
+
+func TestWeekday(t *testing.T) {
+	tests := map[string]string{
+		"2025-03-10": "MON",
+		"2025-03-14": "FRI",
+		"2025-03-16": "SUN",
+	}
+	for d, want := range tests {
+		if got := orders.Weekday(date(d)); got != want {
+			t.Errorf("Weekday(%s) = %s, want %s", d, got, want)
+		}
+	}
+}
+
+func TestResolveUnknownUser(t *testing.T) {
+	st := store.NewMemory().Stores()
+	if _, _, err := orders.Resolve(st, "nobody", date("2025-03-10")); err != orders.ErrUnknownUser {
+		t.Fatalf("err = %v, want ErrUnknownUser", err)
+	}
+}
+
+func TestResolveRange(t *testing.T) {
+	st, userID := fixture(t, store.ModeNormal)
+	modify(t, st, store.Modification{UserID: userID, ProductID: "milk", StartDate: date("2025-03-02"), EndDate: date("2025-03-02")})
+
+	days, err := orders.ResolveRange(st, userID, date("2025-03-01"), date("2025-03-03"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{2, 0, 2}
+	if len(days) != len(want) {
+		t.Fatalf("got %d days, want %d", len(days), len(want))
+	}
+	for i, d := range days {
+		if len(d.Lines) != want[i] {
+			t.Errorf("%s: %d lines, want %d", d.Date, len(d.Lines), want[i])
+		}
+	}
+}
+
+func TestResolveVacation(t *testing.T) {
+	st, userID := fixture(t, store.ModeNormal)
+	// A modification during the vacation is still paused by it
+	modify(t, st, store.Modification{UserID: userID, ProductID: "milk", Quantity: 3, StartDate: date("2025-03-01"), EndDate: date("2025-03-31")})
+	for _, v := range []store.Vacation{
+		{UserID: userID, StartDate: date("2025-03-10"), EndDate: date("2025-03-12"), Reason: "Travel"},
+		{UserID: userID, StartDate: date("2025-03-20"), EndDate: date("2025-03-21"), ProductIDs: []string{"milk"}},
+	} {
+		if _, err := st.Vacations.CreateVacation(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		date       string
+		want       []orders.Line
+		wantSource orders.Source
+	}{
+		{"2025-03-09", []orders.Line{{ProductID: "milk", Quantity: 3}}, orders.SourceModification},
+		{"2025-03-10", []orders.Line{}, orders.SourceVacation},
+		{"2025-03-12", []orders.Line{}, orders.SourceVacation},
+		{"2025-03-13", []orders.Line{{ProductID: "milk", Quantity: 3}}, orders.SourceModification}, // resumes by itself
+		{"2025-03-20", []orders.Line{}, orders.SourceModification},
+		{"2025-04-20", []orders.Line{{ProductID: "curd", Quantity: 2}, {ProductID: "milk", Quantity: 1}}, orders.SourceDefault},
+	}
+	for _, tt := range tests {
+		lines, src, err := orders.Resolve(st, userID, date(tt.date))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(lines, tt.want) || src != tt.wantSource {
+			t.Errorf("%s: got %v from %s, want %v from %s", tt.date, lines, src, tt.want, tt.wantSource)
+		}
+	}
+
+	// Pausing only milk keeps curd
+	if _, err := st.Vacations.CreateVacation(store.Vacation{
+		UserID: userID, StartDate: date("2025-04-05"), EndDate: date("2025-04-06"), ProductIDs: []string{"milk"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := orders.Load(st, []string{userID}, date("2025-04-01"), date("2025-04-30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, src := p.Resolve(userID, date("2025-04-05"))
+	if want := []orders.Line{{ProductID: "curd", Quantity: 2}}; !reflect.DeepEqual(lines, want) || src != orders.SourceDefault {
+		t.Errorf("partial vacation: got %v from %s, want %v from default", lines, src, want)
+	}
+	if v, ok := p.Vacation(userID, date("2025-04-06")); !ok || v.EndDate != date("2025-04-06") {
+		t.Errorf("Vacation = %v, %v, want the one ending 2025-04-06", v, ok)
+	}
+	if _, ok := p.Vacation(userID, date("2025-04-07")); ok {
+		t.Error("no vacation expected after the end date")
+	}
+}
+
+func TestApartmentOn(t *testing.T) {
+	st, userID := fixture(t, store.ModeNormal)
+	c, _ := st.Customers.GetCustomer(userID)
+	from := c.ApartmentID
+	to, _ := st.Apartments.CreateApartment("Hill Top")
+	neighbour, _ := st.Customers.CreateCustomer(models.User{Name: "Ravi", ApartmentID: from})
+	resident, _ := st.Customers.CreateCustomer(models.User{Name: "Meena", ApartmentID: to})
+
+	mv, err := st.Customers.MoveCustomer(store.CustomerMove{UserID: userID, ToApartmentID: to, ToPriority: 1, MovedOn: date("2024-03-15")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mv.FromApartmentID != from || mv.FromPriority != 1 || mv.ToPriority != 1 {
+		t.Errorf("move = %+v", mv)
+	}
+	for id, want := range map[string]int{userID: 1, resident: 2, neighbour: 1} {
+		if c, _ := st.Customers.GetCustomer(id); c.PriorityOrder != want {
+			t.Errorf("priority of %s = %d, want %d", c.Name, c.PriorityOrder, want)
+		}
+	}
+
+	plan, err := orders.Load(st, []string{userID}, date("2024-03-01"), date("2024-03-31"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2024-03-01", from},
+		{"2024-03-14", from},
+		{"2024-03-15", to},
+		{"2024-03-31", to},
+	}
+	for _, tt := range tests {
+		if got := plan.ApartmentOn(userID, date(tt.date)); got != tt.want {
+			t.Errorf("ApartmentOn(%s) = %s, want %s", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestUserIDsIn(t *testing.T) {
+	st, userID := fixture(t, store.ModeNormal)
+	c, _ := st.Customers.GetCustomer(userID)
+	from := c.ApartmentID
+	to, _ := st.Apartments.CreateApartment("Hill Top")
+	neighbour, _ := st.Customers.CreateCustomer(models.User{Name: "Ravi", ApartmentID: from})
+	resident, _ := st.Customers.CreateCustomer(models.User{Name: "Meena", ApartmentID: to})
+	if _, err := st.Customers.MoveCustomer(store.CustomerMove{UserID: userID, ToApartmentID: to, MovedOn: date("2024-03-15")}); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := orders.LoadAll(st, date("2024-03-14"), date("2024-03-15"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		apartmentID string
+		date        string
+		want        []string
+	}{
+		{from, "2024-03-14", []string{userID, neighbour}},
+		{to, "2024-03-14", []string{resident}},
+		{from, "2024-03-15", []string{neighbour}},
+		{to, "2024-03-15", []string{userID, resident}},
+	}
+	for _, tt := range tests {
+		got := plan.UserIDsIn(tt.apartmentID, date(tt.date))
+		sort.Strings(got)
+		sort.Strings(tt.want)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("UserIDsIn(%s, %s) = %v, want %v", tt.apartmentID, tt.date, got, tt.want)
+		}
+	}
+}
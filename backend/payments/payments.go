@@ -0,0 +1,91 @@
+// Package payments records money received from customers and turns it,
+// together with their monthly bills, into balances and statements.
+//
+// A month is billed at its finalized invoice total when there is one, and
+// otherwise at the live amount billing.Compute (and so GetMonthlyBill)
+// produces for it.
+package payments
+
+import (
+	"backend/civil"
+	"backend/models"
+	"backend/store"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Payment modes.
+const (
+	ModeCash = "cash"
+	ModeUPI  = "upi"
+	ModeBank = "bank"
+)
+
+var (
+	ErrNotFound = errors.New("payments: payment not found")
+	// ErrInvalid is wrapped by every validation error of Record.
+	ErrInvalid = errors.New("payments: invalid payment")
+)
+
+// ValidMode reports whether mode is one of the accepted payment modes.
+func ValidMode(mode string) bool {
+	return mode == ModeCash || mode == ModeUPI || mode == ModeBank
+}
+
+// Record validates and stores a payment. InvoiceID is optional but, when
+// set, must be an invoice of the same customer.
+func Record(st store.Stores, p models.Payment) (*models.Payment, error) {
+	p.Mode = strings.ToLower(strings.TrimSpace(p.Mode))
+	p.Reference = strings.TrimSpace(p.Reference)
+	p.Amount = round2(p.Amount)
+	if p.UserID == "" {
+		return nil, fmt.Errorf("%w: customer_id is required", ErrInvalid)
+	}
+	if p.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalid)
+	}
+	if !ValidMode(p.Mode) {
+		return nil, fmt.Errorf("%w: mode must be cash, upi or bank", ErrInvalid)
+	}
+	if p.PaymentDate.IsZero() {
+		return nil, fmt.Errorf("%w: payment_date must be YYYY-MM-DD", ErrInvalid)
+	}
+
+	if _, err := st.Customers.GetCustomer(p.UserID); err == store.ErrNotFound {
+		return nil, fmt.Errorf("%w: unknown customer", ErrInvalid)
+	} else if err != nil {
+		return nil, fmt.Errorf("payments: check customer: %w", err)
+	}
+	if p.InvoiceID != "" {
+		inv, err := st.Invoices.GetInvoice(p.InvoiceID)
+		if err == store.ErrNotFound || (err == nil && inv.UserID != p.UserID) {
+			return nil, fmt.Errorf("%w: invoice does not belong to this customer", ErrInvalid)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("payments: check invoice: %w", err)
+		}
+	}
+
+	id, err := st.Payments.CreatePayment(p)
+	if err != nil {
+		return nil, fmt.Errorf("payments: insert: %w", err)
+	}
+	return st.Payments.GetPayment(id)
+}
+
+// List returns a customer's payments between from and to, oldest first.
+// An empty customer matches everyone and zero dates leave that side open.
+func List(st store.Stores, userID string, from, to civil.Date) ([]models.Payment, error) {
+	return st.Payments.ListPayments(userID, from, to)
+}
+
+// Delete removes a payment recorded by mistake.
+func Delete(st store.Stores, paymentID string) error {
+	if err := st.Payments.DeletePayment(paymentID); err == store.ErrNotFound {
+		return ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("payments: delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package payments_test
+
+import (
+	"backend/civil"
+	"backend/invoices"
+	"backend/models"
+	"backend/payments"
+	"backend/store"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fixture is a customer created on 10 January 2025 who takes 1 L of milk at
+// 30 a day.
+func fixture(t *testing.T) (store.Stores, string) {
+	t.Helper()
+	m := store.NewMemory()
+	m.Now = func() time.Time { return time.Date(2025, time.January, 10, 8, 0, 0, 0, time.UTC) }
+	st := m.Stores()
+	aptID, _ := st.Apartments.CreateApartment("Lake View")
+	userID, _ := st.Customers.CreateCustomer(models.User{Name: "Asha", ApartmentID: aptID})
+	productID, _ := st.Products.CreateProduct(models.Product{ProductName: "Milk", Unit: "L", CurrentPrice: 30})
+	err := st.Orders.ReplaceDefaults(userID, store.ModeNormal, civil.Date{}, civil.Date{}, []store.DefaultItem{{ProductID: productID, Quantity: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st, userID
+}
+
+func TestRecord(t *testing.T) {
+	st, userID := fixture(t)
+	otherID, _ := st.Customers.CreateCustomer(models.User{Name: "Ravi"})
+	inv, err := invoices.Generate(st, otherID, 2025, time.January)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid := civil.Date{Year: 2025, Month: 2, Day: 3}
+
+	tests := []struct {
+		name    string
+		payment models.Payment
+		wantErr bool
+	}{
+		{"valid", models.Payment{UserID: userID, Amount: 500, PaymentDate: paid, Mode: " UPI "}, false},
+		{"no customer", models.Payment{Amount: 500, PaymentDate: paid, Mode: "cash"}, true},
+		{"unknown customer", models.Payment{UserID: "nobody", Amount: 500, PaymentDate: paid, Mode: "cash"}, true},
+		{"zero amount", models.Payment{UserID: userID, PaymentDate: paid, Mode: "cash"}, true},
+		{"unknown mode", models.Payment{UserID: userID, Amount: 500, PaymentDate: paid, Mode: "cheque"}, true},
+		{"no date", models.Payment{UserID: userID, Amount: 500, Mode: "cash"}, true},
+		{"someone else's invoice", models.Payment{UserID: userID, InvoiceID: inv.InvoiceID, Amount: 500, PaymentDate: paid, Mode: "cash"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := payments.Record(st, tt.payment)
+			if tt.wantErr {
+				if !errors.Is(err, payments.ErrInvalid) {
+					t.Fatalf("got %v, want ErrInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.PaymentID == "" || p.Mode != payments.ModeUPI {
+				t.Errorf("stored %+v", p)
+			}
+		})
+	}
+}
+
+func TestGetBalance(t *testing.T) {
+	st, userID := fixture(t)
+	if _, err := payments.Record(st, models.Payment{
+		UserID: userID, Amount: 500, PaymentDate: civil.Date{Year: 2025, Month: 2, Day: 10}, Mode: "cash",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		asOf       civil.Date
+		wantBilled float64
+		wantPaid   float64
+	}{
+		{civil.Date{Year: 2025, Month: time.January, Day: 20}, 0, 0},
+		{civil.Date{Year: 2025, Month: time.February, Day: 9}, 31 * 30, 0},
+		{civil.Date{Year: 2025, Month: time.March, Day: 5}, 31*30 + 28*30, 500},
+	}
+	for _, tt := range tests {
+		bal, err := payments.GetBalance(st, userID, tt.asOf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bal.TotalBilled != tt.wantBilled || bal.TotalPaid != tt.wantPaid || bal.Balance != tt.wantBilled-tt.wantPaid {
+			t.Errorf("as of %s: billed %v, paid %v, balance %v; want billed %v, paid %v",
+				bal.AsOf, bal.TotalBilled, bal.TotalPaid, bal.Balance, tt.wantBilled, tt.wantPaid)
+		}
+	}
+}
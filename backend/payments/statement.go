@@ -0,0 +1,231 @@
+package payments
+
+import (
+	"backend/billing"
+	"backend/civil"
+	"backend/invoices"
+	"backend/models"
+	"backend/orders"
+	"backend/pricing"
+	"backend/store"
+	"fmt"
+	"math"
+)
+
+// Month is one line of a statement.
+type Month struct {
+	Month         string  `json:"month"` // YYYY-MM
+	Opening       float64 `json:"opening_balance"`
+	Billed        float64 `json:"billed"`
+	Paid          float64 `json:"paid"`
+	Closing       float64 `json:"closing_balance"`
+	InvoiceID     string  `json:"invoice_id,omitempty"`
+	InvoiceStatus string  `json:"invoice_status,omitempty"`
+}
+
+// Statement lists a customer's bills and payments month by month. The
+// opening balance carries forward everything before From.
+type Statement struct {
+	UserID         string           `json:"customer_id"`
+	From           string           `json:"from"`
+	To             string           `json:"to"`
+	OpeningBalance float64          `json:"opening_balance"`
+	TotalBilled    float64          `json:"total_billed"`
+	TotalPaid      float64          `json:"total_paid"`
+	ClosingBalance float64          `json:"closing_balance"`
+	Months         []Month          `json:"months"`
+	Payments       []models.Payment `json:"payments"`
+}
+
+// Balance is what a customer owes on a date. Only complete months are
+// billed; payments count up to and including AsOf.
+type Balance struct {
+	UserID        string  `json:"customer_id"`
+	AsOf          string  `json:"as_of"`
+	BilledThrough string  `json:"billed_through,omitempty"` // YYYY-MM, empty before the first complete month
+	TotalBilled   float64 `json:"total_billed"`
+	TotalPaid     float64 `json:"total_paid"`
+	Balance       float64 `json:"balance"`
+}
+
+// monthBill is the amount billed for one month and where it came from.
+type monthBill struct {
+	start         civil.Date
+	amount        float64
+	invoiceID     string
+	invoiceStatus string
+}
+
+// GetStatement builds the statement of the months from..to (any day of each
+// month will do). from is moved up to the month the customer was created.
+func GetStatement(stores store.Stores, userID string, from, to civil.Date) (*Statement, error) {
+	first, err := firstMonth(stores, userID)
+	if err != nil {
+		return nil, err
+	}
+	from, to = from.FirstOfMonth(), to.FirstOfMonth()
+	if from.Before(first) {
+		from = first
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: statement ends before it starts", ErrInvalid)
+	}
+
+	bills, err := billMonths(stores, userID, first, to)
+	if err != nil {
+		return nil, err
+	}
+	_, end := billing.MonthRange(to.Year, to.Month)
+	paid, err := List(stores, userID, civil.Date{}, end)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Statement{
+		UserID:   userID,
+		From:     from.Format("2006-01"),
+		To:       to.Format("2006-01"),
+		Months:   make([]Month, 0),
+		Payments: make([]models.Payment, 0),
+	}
+	for _, p := range paid {
+		if p.PaymentDate.Before(from) {
+			st.OpeningBalance -= p.Amount
+		} else {
+			st.Payments = append(st.Payments, p)
+		}
+	}
+	for _, b := range bills {
+		if b.start.Before(from) {
+			st.OpeningBalance += b.amount
+		}
+	}
+
+	balance := st.OpeningBalance
+	for _, b := range bills {
+		if b.start.Before(from) {
+			continue
+		}
+		_, monthEnd := billing.MonthRange(b.start.Year, b.start.Month)
+		m := Month{
+			Month:         b.start.Format("2006-01"),
+			Opening:       round2(balance),
+			Billed:        b.amount,
+			InvoiceID:     b.invoiceID,
+			InvoiceStatus: b.invoiceStatus,
+		}
+		for _, p := range st.Payments {
+			if date := p.PaymentDate; !date.Before(b.start) && !date.After(monthEnd) {
+				m.Paid += p.Amount
+			}
+		}
+		balance += m.Billed - m.Paid
+		m.Paid = round2(m.Paid)
+		m.Closing = round2(balance)
+		st.TotalBilled += m.Billed
+		st.TotalPaid += m.Paid
+		st.Months = append(st.Months, m)
+	}
+
+	st.OpeningBalance = round2(st.OpeningBalance)
+	st.TotalBilled = round2(st.TotalBilled)
+	st.TotalPaid = round2(st.TotalPaid)
+	st.ClosingBalance = round2(st.OpeningBalance + st.TotalBilled - st.TotalPaid)
+	return st, nil
+}
+
+// GetBalance returns what the customer owes on asOf: every complete month
+// before asOf's month minus every payment up to asOf.
+func GetBalance(stores store.Stores, userID string, asOf civil.Date) (*Balance, error) {
+	first, err := firstMonth(stores, userID)
+	if err != nil {
+		return nil, err
+	}
+	bal := &Balance{UserID: userID, AsOf: asOf.String()}
+
+	last := asOf.FirstOfMonth().AddMonths(-1)
+	if !last.Before(first) {
+		bills, err := billMonths(stores, userID, first, last)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bills {
+			bal.TotalBilled += b.amount
+		}
+		bal.BilledThrough = last.Format("2006-01")
+	}
+
+	paid, err := List(stores, userID, civil.Date{}, asOf)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paid {
+		bal.TotalPaid += p.Amount
+	}
+
+	bal.TotalBilled = round2(bal.TotalBilled)
+	bal.TotalPaid = round2(bal.TotalPaid)
+	bal.Balance = round2(bal.TotalBilled - bal.TotalPaid)
+	return bal, nil
+}
+
+// billMonths bills every month from first to last with a single order plan.
+// Finalized invoices win over the live computation.
+func billMonths(stores store.Stores, userID string, first, last civil.Date) ([]monthBill, error) {
+	_, end := billing.MonthRange(last.Year, last.Month)
+	plan, err := orders.Load(stores, []string{userID}, first, end)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Has(userID) {
+		return nil, orders.ErrUnknownUser
+	}
+	prices, err := pricing.Load(stores.Prices)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := invoices.List(stores, userID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	byMonth := make(map[string]models.Invoice)
+	for _, inv := range stored {
+		byMonth[fmt.Sprintf("%04d-%02d", inv.Year, inv.Month)] = inv
+	}
+
+	var bills []monthBill
+	for m := first; !m.After(last); m = m.AddMonths(1) {
+		b := monthBill{start: m}
+		inv, ok := byMonth[m.Format("2006-01")]
+		if ok {
+			b.invoiceID, b.invoiceStatus = inv.InvoiceID, inv.Status
+		}
+		if ok && inv.Status == invoices.StatusFinalized {
+			b.amount = inv.TotalAmount
+		} else {
+			b.amount = round2(billing.FromPlan(plan, prices, userID, m.Year, m.Month).Total)
+		}
+		bills = append(bills, b)
+	}
+	return bills, nil
+}
+
+// firstMonth is the month the customer was created in.
+func firstMonth(stores store.Stores, userID string) (civil.Date, error) {
+	c, err := stores.Customers.GetCustomer(userID)
+	if err == store.ErrNotFound {
+		return civil.Date{}, orders.ErrUnknownUser
+	}
+	if err != nil {
+		return civil.Date{}, fmt.Errorf("payments: load customer: %w", err)
+	}
+	created, err := civil.Parse(c.CreatedAt)
+	if err != nil {
+		return civil.Date{}, fmt.Errorf("payments: customer %s: %w", userID, err)
+	}
+	return created.FirstOfMonth(), nil
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,140 @@
+// Package pricing answers "what did a unit of this product cost on that day"
+// from the price history, loaded once per request instead of once per
+// product and day.
+//
+// A price change is scheduled: it takes effect on its effective date, which
+// may lie in the future, and can be cancelled until then. A product's stored
+// current_price is only its price before the first change; the price shown as
+// current is PriceAsOf today.
+//
+// Price lists override that base price for one apartment or one customer.
+// Quote picks the customer's entry, then the apartment's, then the base price.
+package pricing
+
+import (
+	"backend/civil"
+	"backend/store"
+	"fmt"
+	"sort"
+)
+
+// change is one price change of a product.
+type change struct {
+	oldPrice, newPrice float64
+	effectiveFrom      civil.Date
+}
+
+// Where a quoted price comes from, most specific first.
+const (
+	SourceCustomer  = "customer"
+	SourceApartment = "apartment"
+	SourceBase      = "base"
+)
+
+// owner is a product's price list for one apartment or one customer.
+type owner struct {
+	productID, id string
+}
+
+// Book holds the price history, current price and price lists of every
+// product.
+type Book struct {
+	current    map[string]float64
+	history    map[string][]change // sorted by effectiveFrom ascending
+	customers  map[owner][]store.PriceListEntry
+	apartments map[owner][]store.PriceListEntry // both sorted by EffectiveFrom ascending
+}
+
+// Load reads every product's current price, price history and price list
+// entries in three calls.
+func Load(prices store.PriceStore) (*Book, error) {
+	b := &Book{
+		history:    make(map[string][]change),
+		customers:  make(map[owner][]store.PriceListEntry),
+		apartments: make(map[owner][]store.PriceListEntry),
+	}
+
+	current, err := prices.CurrentPrices()
+	if err != nil {
+		return nil, fmt.Errorf("pricing: load products: %w", err)
+	}
+	b.current = current
+
+	changes, err := prices.PriceChanges("")
+	if err != nil {
+		return nil, fmt.Errorf("pricing: load price history: %w", err)
+	}
+	for _, h := range changes {
+		if h.EffectiveFrom.IsZero() {
+			return nil, fmt.Errorf("pricing: price change %s has no effective date", h.PriceID)
+		}
+		b.history[h.ProductID] = append(b.history[h.ProductID], change{oldPrice: h.OldPrice, newPrice: h.NewPrice, effectiveFrom: h.EffectiveFrom})
+	}
+	for _, h := range b.history {
+		sort.SliceStable(h, func(i, j int) bool { return h[i].effectiveFrom.Before(h[j].effectiveFrom) })
+	}
+
+	entries, err := prices.PriceListEntries("", "")
+	if err != nil {
+		return nil, fmt.Errorf("pricing: load price lists: %w", err)
+	}
+	for _, e := range entries {
+		if e.UserID != "" {
+			k := owner{e.ProductID, e.UserID}
+			b.customers[k] = append(b.customers[k], e)
+		} else {
+			k := owner{e.ProductID, e.ApartmentID}
+			b.apartments[k] = append(b.apartments[k], e)
+		}
+	}
+	return b, nil
+}
+
+// Quote returns what a customer living in the apartment pays for a unit of
+// the product on date, and where that price comes from: the customer's price
+// list entry covering date, else the apartment's, else PriceAsOf. Among
+// entries of one list covering date, the latest effective one wins.
+func (b *Book) Quote(productID, userID, apartmentID string, date civil.Date) (float64, string) {
+	if price, ok := covering(b.customers[owner{productID, userID}], date); ok && userID != "" {
+		return price, SourceCustomer
+	}
+	if price, ok := covering(b.apartments[owner{productID, apartmentID}], date); ok && apartmentID != "" {
+		return price, SourceApartment
+	}
+	return b.PriceAsOf(productID, date), SourceBase
+}
+
+// PriceFor is Quote without the source.
+func (b *Book) PriceFor(productID, userID, apartmentID string, date civil.Date) float64 {
+	price, _ := b.Quote(productID, userID, apartmentID, date)
+	return price
+}
+
+// covering returns the price of the last entry in effect on date.
+func covering(entries []store.PriceListEntry, date civil.Date) (float64, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if !e.EffectiveFrom.After(date) && (e.EffectiveTo.IsZero() || !e.EffectiveTo.Before(date)) {
+			return e.Price, true
+		}
+	}
+	return 0, false
+}
+
+// PriceAsOf returns the unit price of a product on date: the new_price of the
+// latest change effective on or before date (the last recorded one when two
+// share a date), else the old_price of the first change, else the product's
+// stored current_price, else 0. It is the only definition of a price; bills
+// and the product list both use it.
+func (b *Book) PriceAsOf(productID string, date civil.Date) float64 {
+	h := b.history[productID]
+	for i := len(h) - 1; i >= 0; i-- {
+		if !h[i].effectiveFrom.After(date) {
+			return h[i].newPrice
+		}
+	}
+	if len(h) > 0 {
+		return h[0].oldPrice
+	}
+	return b.current[productID]
+}
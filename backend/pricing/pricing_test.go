@@ -0,0 +1,239 @@
+package pricing_test
+
+import (
+	"backend/civil"
+	"backend/models"
+	"backend/pricing"
+	"backend/store"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPriceAsOf(t *testing.T) {
+	apr := func(d int) civil.Date { return civil.Date{Year: 2025, Month: time.April, Day: d} }
+	tests := []struct {
+		name    string
+		changes []models.ProductPriceHistory // recorded in this order
+		cancel  int                          // 1-based index of a change to cancel, 0 for none
+		date    civil.Date
+		want    float64
+	}{
+		{
+			name: "no changes uses the stored price",
+			date: apr(10),
+			want: 30,
+		},
+		{
+			name:    "before the first change uses its old price",
+			changes: []models.ProductPriceHistory{{OldPrice: 28, NewPrice: 32, EffectiveFrom: apr(16)}},
+			date:    apr(15),
+			want:    28,
+		},
+		{
+			name:    "a change applies from its effective date",
+			changes: []models.ProductPriceHistory{{OldPrice: 28, NewPrice: 32, EffectiveFrom: apr(16)}},
+			date:    apr(16),
+			want:    32,
+		},
+		{
+			name: "latest effective change wins over a scheduled one",
+			changes: []models.ProductPriceHistory{
+				{OldPrice: 28, NewPrice: 30, EffectiveFrom: apr(1)},
+				{OldPrice: 30, NewPrice: 34, EffectiveFrom: apr(20)},
+			},
+			date: apr(19),
+			want: 30,
+		},
+		{
+			name: "last recorded change wins on the same date",
+			changes: []models.ProductPriceHistory{
+				{OldPrice: 28, NewPrice: 31, EffectiveFrom: apr(10)},
+				{OldPrice: 28, NewPrice: 33, EffectiveFrom: apr(10)},
+			},
+			date: apr(12),
+			want: 33,
+		},
+		{
+			name: "cancelled change no longer applies",
+			changes: []models.ProductPriceHistory{
+				{OldPrice: 28, NewPrice: 30, EffectiveFrom: apr(1)},
+				{OldPrice: 30, NewPrice: 34, EffectiveFrom: apr(20)},
+			},
+			cancel: 2,
+			date:   apr(25),
+			want:   30,
+		},
+		{
+			name: "cancelling the first change hands its old price on",
+			changes: []models.ProductPriceHistory{
+				{OldPrice: 28, NewPrice: 30, EffectiveFrom: apr(1)},
+				{OldPrice: 30, NewPrice: 34, EffectiveFrom: apr(20)},
+			},
+			cancel: 1,
+			date:   apr(10),
+			want:   28,
+		},
+		{
+			name:    "cancelling the only change restores the stored price",
+			changes: []models.ProductPriceHistory{{OldPrice: 28, NewPrice: 34, EffectiveFrom: apr(20)}},
+			cancel:  1,
+			date:    apr(25),
+			want:    30,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory().Stores()
+			productID, _ := st.Products.CreateProduct(models.Product{ProductName: "Milk", Unit: "L", CurrentPrice: 30})
+			for _, h := range tt.changes {
+				h.ProductID = productID
+				if err := st.Prices.AddPriceChange(h); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.cancel > 0 {
+				list, _ := st.Prices.PriceChanges(productID)
+				var priceID string
+				for _, h := range list {
+					if h.EffectiveFrom == tt.changes[tt.cancel-1].EffectiveFrom && h.NewPrice == tt.changes[tt.cancel-1].NewPrice {
+						priceID = h.PriceID
+					}
+				}
+				if err := st.Prices.DeletePriceChange(priceID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			book, err := pricing.Load(st.Prices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := book.PriceAsOf(productID, tt.date); got != tt.want {
+				t.Errorf("PriceAsOf(%s) = %v, want %v", tt.date, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPriceChangeChain checks that each change starts from the price of the
+// change before it, however the changes were recorded or cancelled.
+func TestPriceChangeChain(t *testing.T) {
+	apr := func(d int) civil.Date { return civil.Date{Year: 2025, Month: time.April, Day: d} }
+	st := store.NewMemory().Stores()
+	productID, _ := st.Products.CreateProduct(models.Product{ProductName: "Milk", Unit: "L", CurrentPrice: 28})
+	for _, h := range []models.ProductPriceHistory{
+		{OldPrice: 28, NewPrice: 34, EffectiveFrom: apr(20)},
+		{OldPrice: 28, NewPrice: 31, EffectiveFrom: apr(10)},
+	} {
+		h.ProductID = productID
+		if err := st.Prices.AddPriceChange(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldPrices := func() []float64 {
+		list, _ := st.Prices.PriceChanges(productID)
+		var got []float64
+		for _, h := range list {
+			got = append(got, h.OldPrice)
+		}
+		return got
+	}
+	if got, want := oldPrices(), []float64{28, 31}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after recording: old prices %v, want %v", got, want)
+	}
+
+	list, _ := st.Prices.PriceChanges(productID)
+	if err := st.Prices.DeletePriceChange(list[0].PriceID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := oldPrices(), []float64{28}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after cancelling: old prices %v, want %v", got, want)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	day := func(d int) civil.Date { return civil.Date{Year: 2025, Month: time.May, Day: d} }
+	tests := []struct {
+		name       string
+		entries    []store.PriceListEntry // recorded in this order
+		user, apt  string
+		date       civil.Date
+		want       float64
+		wantSource string
+	}{
+		{
+			name: "no price list uses the base price",
+			user: "u1", apt: "a1", date: day(10),
+			want: 30, wantSource: pricing.SourceBase,
+		},
+		{
+			name:    "apartment entry",
+			entries: []store.PriceListEntry{{ApartmentID: "a1", Price: 27, EffectiveFrom: day(1)}},
+			user:    "u1", apt: "a1", date: day(10),
+			want: 27, wantSource: pricing.SourceApartment,
+		},
+		{
+			name:    "another apartment's entry does not apply",
+			entries: []store.PriceListEntry{{ApartmentID: "a2", Price: 27, EffectiveFrom: day(1)}},
+			user:    "u1", apt: "a1", date: day(10),
+			want: 30, wantSource: pricing.SourceBase,
+		},
+		{
+			name: "customer entry wins over the apartment",
+			entries: []store.PriceListEntry{
+				{UserID: "u1", Price: 25, EffectiveFrom: day(1)},
+				{ApartmentID: "a1", Price: 27, EffectiveFrom: day(5)},
+			},
+			user: "u1", apt: "a1", date: day(10),
+			want: 25, wantSource: pricing.SourceCustomer,
+		},
+		{
+			name: "expired customer entry falls back to the apartment",
+			entries: []store.PriceListEntry{
+				{UserID: "u1", Price: 25, EffectiveFrom: day(1), EffectiveTo: day(9)},
+				{ApartmentID: "a1", Price: 27, EffectiveFrom: day(1)},
+			},
+			user: "u1", apt: "a1", date: day(10),
+			want: 27, wantSource: pricing.SourceApartment,
+		},
+		{
+			name:    "entry not yet effective",
+			entries: []store.PriceListEntry{{UserID: "u1", Price: 25, EffectiveFrom: day(11)}},
+			user:    "u1", apt: "a1", date: day(10),
+			want: 30, wantSource: pricing.SourceBase,
+		},
+		{
+			name: "latest effective entry of a list wins",
+			entries: []store.PriceListEntry{
+				{ApartmentID: "a1", Price: 26, EffectiveFrom: day(5)},
+				{ApartmentID: "a1", Price: 28, EffectiveFrom: day(1)},
+			},
+			user: "u1", apt: "a1", date: day(10),
+			want: 26, wantSource: pricing.SourceApartment,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory().Stores()
+			productID, _ := st.Products.CreateProduct(models.Product{ProductName: "Milk", Unit: "L", CurrentPrice: 30})
+			for _, e := range tt.entries {
+				e.ProductID = productID
+				if _, err := st.Prices.CreatePriceListEntry(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			book, err := pricing.Load(st.Prices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, source := book.Quote(productID, tt.user, tt.apt, tt.date)
+			if got != tt.want || source != tt.wantSource {
+				t.Errorf("Quote = %v (%s), want %v (%s)", got, source, tt.want, tt.wantSource)
+			}
+		})
+	}
+}
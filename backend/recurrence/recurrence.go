@@ -0,0 +1,271 @@
+// Package recurrence decides whether a recurring default order item is due on
+// a date. Rules are stored as a subset of RFC 5545 RRULE text, with the
+// start date kept in the same string:
+//
+//	FREQ=DAILY;INTERVAL=3;DTSTART=20250301   every 3 days from 1 March 2025
+//	FREQ=MONTHLY;BYMONTHDAY=1,15             the 1st and 15th of every month
+//	FREQ=WEEKLY;INTERVAL=2;BYDAY=SA;DTSTART=20250301
+//	                                          every other Saturday
+//
+// Supported parts are FREQ (DAILY, WEEKLY, MONTHLY), INTERVAL, DTSTART,
+// UNTIL, BYDAY (plain weekdays, no ordinals) and BYMONTHDAY (negative values
+// count from the end of the month). Weeks start on Monday.
+package recurrence
+
+import (
+	"backend/civil"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies.
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+)
+
+// ErrInvalid is wrapped by every parse error.
+var ErrInvalid = errors.New("recurrence: invalid rule")
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq       string
+	Interval   int        // at least 1
+	Start      civil.Date // zero when the rule has no DTSTART
+	Until      civil.Date // zero when the rule never ends
+	ByDay      []time.Weekday
+	ByMonthDay []int
+}
+
+// EveryDays returns a rule due every n days, starting on anchor.
+func EveryDays(n int, anchor civil.Date) *Rule {
+	return &Rule{Freq: Daily, Interval: n, Start: anchor}
+}
+
+// OnWeekdays returns a rule due on the given days of every week.
+func OnWeekdays(days ...time.Weekday) *Rule {
+	return &Rule{Freq: Weekly, Interval: 1, ByDay: days}
+}
+
+// MonthDays returns a rule due on the given days of every month.
+func MonthDays(days ...int) *Rule {
+	return &Rule{Freq: Monthly, Interval: 1, ByMonthDay: days}
+}
+
+// Parse reads a rule. An "RRULE:" prefix is accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalid)
+	}
+
+	r := &Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalid, part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s given twice", ErrInvalid, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			if value != Daily && value != Weekly && value != Monthly {
+				return nil, fmt.Errorf("%w: FREQ must be DAILY, WEEKLY or MONTHLY", ErrInvalid)
+			}
+			r.Freq = value
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive number", ErrInvalid)
+			}
+		case "DTSTART":
+			if r.Start, err = civil.Parse(dashed(value)); err != nil {
+				return nil, fmt.Errorf("%w: DTSTART: %v", ErrInvalid, err)
+			}
+		case "UNTIL":
+			if r.Until, err = civil.Parse(dashed(value)); err != nil {
+				return nil, fmt.Errorf("%w: UNTIL: %v", ErrInvalid, err)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := weekdays[d]
+				if !ok {
+					return nil, fmt.Errorf("%w: unsupported BYDAY value %q", ErrInvalid, d)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("%w: BYMONTHDAY values must be 1..31 or -31..-1", ErrInvalid)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s is not supported", ErrInvalid, key)
+		}
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Rule) validate() error {
+	switch {
+	case r.Freq == "":
+		return fmt.Errorf("%w: FREQ is required", ErrInvalid)
+	case r.Interval > 1 && r.Start.IsZero():
+		return fmt.Errorf("%w: INTERVAL above 1 needs a DTSTART to count from", ErrInvalid)
+	case r.Freq == Weekly && len(r.ByDay) == 0 && r.Start.IsZero():
+		return fmt.Errorf("%w: WEEKLY needs BYDAY or DTSTART", ErrInvalid)
+	case r.Freq == Monthly && len(r.ByMonthDay) == 0 && r.Start.IsZero():
+		return fmt.Errorf("%w: MONTHLY needs BYMONTHDAY or DTSTART", ErrInvalid)
+	case !r.Until.IsZero() && !r.Start.IsZero() && r.Until.Before(r.Start):
+		return fmt.Errorf("%w: UNTIL is before DTSTART", ErrInvalid)
+	}
+	return nil
+}
+
+// String returns the rule in the form Parse reads, with its parts in a fixed
+// order so equal rules compare equal.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := append([]time.Weekday(nil), r.ByDay...)
+		sort.Slice(days, func(i, j int) bool { return mondayFirst(days[i]) < mondayFirst(days[j]) })
+		codes := make([]string, len(days))
+		for i, d := range days {
+			codes[i] = strings.ToUpper(d.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if !r.Start.IsZero() {
+		parts = append(parts, "DTSTART="+compact(r.Start))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+compact(r.Until))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Due reports whether the rule has an occurrence on date.
+func (r *Rule) Due(date civil.Date) bool {
+	if !r.Start.IsZero() && date.Before(r.Start) {
+		return false
+	}
+	if !r.Until.IsZero() && date.After(r.Until) {
+		return false
+	}
+
+	switch r.Freq {
+	case Daily:
+		if r.Interval > 1 && daysBetween(r.Start, date)%r.Interval != 0 {
+			return false
+		}
+		return r.matchesByDay(date) && r.matchesByMonthDay(date)
+	case Weekly:
+		if r.Interval > 1 && weeksBetween(r.Start, date)%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			return date.Weekday() == r.Start.Weekday() && r.matchesByMonthDay(date)
+		}
+		return r.matchesByDay(date) && r.matchesByMonthDay(date)
+	case Monthly:
+		if r.Interval > 1 && monthsBetween(r.Start, date)%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByMonthDay) == 0 {
+			return date.Day == r.Start.Day && r.matchesByDay(date)
+		}
+		return r.matchesByMonthDay(date) && r.matchesByDay(date)
+	}
+	return false
+}
+
+func (r *Rule) matchesByDay(date civil.Date) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d == date.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesByMonthDay(date civil.Date) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := date.FirstOfMonth().AddMonths(1).AddDays(-1).Day
+	for _, d := range r.ByMonthDay {
+		if d == date.Day || (d < 0 && last+d+1 == date.Day) {
+			return true
+		}
+	}
+	return false
+}
+
+// dashed turns an RRULE date (20250301, optionally with a time) into the
+// YYYY-MM-DD form civil.Parse reads. Plain YYYY-MM-DD dates pass unchanged.
+func dashed(s string) string {
+	if len(s) < 8 || strings.Contains(s[:8], "-") {
+		return s
+	}
+	return s[:4] + "-" + s[4:6] + "-" + s[6:8]
+}
+
+// compact writes d in the RRULE form, 20250301.
+func compact(d civil.Date) string {
+	return fmt.Sprintf("%04d%02d%02d", d.Year, d.Month, d.Day)
+}
+
+func daysBetween(a, b civil.Date) int {
+	return b.DaysSince(a)
+}
+
+// weeksBetween counts Monday-to-Sunday weeks from a's week to b's.
+func weeksBetween(a, b civil.Date) int {
+	monday := func(d civil.Date) civil.Date { return d.AddDays(-mondayFirst(d.Weekday())) }
+	return daysBetween(monday(a), monday(b)) / 7
+}
+
+func monthsBetween(a, b civil.Date) int {
+	return (b.Year-a.Year)*12 + int(b.Month) - int(a.Month)
+}
+
+// mondayFirst numbers weekdays from Monday = 0 to Sunday = 6.
+func mondayFirst(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
@@ -0,0 +1,118 @@
+package recurrence_test
+
+import (
+	"backend/civil"
+	"backend/recurrence"
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(s string) civil.Date {
+	d, err := civil.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestDue(t *testing.T) {
+	tests := []struct {
+		rule string
+		due  []string
+		not  []string
+	}{
+		{
+			rule: "FREQ=DAILY;INTERVAL=3;DTSTART=20250301",
+			due:  []string{"2025-03-01", "2025-03-04", "2025-03-31", "2025-04-03"},
+			not:  []string{"2025-02-26", "2025-03-02", "2025-03-03"},
+		},
+		{
+			rule: "FREQ=MONTHLY;BYMONTHDAY=1,15",
+			due:  []string{"2025-01-01", "2025-02-15", "2025-12-15"},
+			not:  []string{"2025-01-02", "2025-02-14", "2025-02-28"},
+		},
+		{
+			rule: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			due:  []string{"2025-02-28", "2024-02-29", "2025-04-30"},
+			not:  []string{"2024-02-28", "2025-03-30"},
+		},
+		{
+			rule: "FREQ=WEEKLY;BYDAY=SU",
+			due:  []string{"2025-03-02", "2025-03-09"},
+			not:  []string{"2025-03-01", "2025-03-03"},
+		},
+		{
+			rule: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=SA;DTSTART=20250301",
+			due:  []string{"2025-03-01", "2025-03-15", "2025-03-29"},
+			not:  []string{"2025-03-08", "2025-03-22", "2025-02-15"},
+		},
+		{
+			rule: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20250314",
+			due:  []string{"2025-03-10", "2025-03-14"},
+			not:  []string{"2025-03-15", "2025-03-17"},
+		},
+		{
+			rule: "FREQ=MONTHLY;INTERVAL=2;DTSTART=2025-01-31",
+			due:  []string{"2025-01-31", "2025-03-31"},
+			not:  []string{"2025-02-28", "2025-05-30", "2025-03-01"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := recurrence.Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range tt.due {
+				if !r.Due(date(d)) {
+					t.Errorf("%s should be due", d)
+				}
+			}
+			for _, d := range tt.not {
+				if r.Due(date(d)) {
+					t.Errorf("%s should not be due", d)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;COUNT=5",
+		"FREQ=DAILY;INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0;DTSTART=20250101",
+		"FREQ=WEEKLY",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"INTERVAL=2",
+	} {
+		if _, err := recurrence.Parse(rule); !errors.Is(err, recurrence.ErrInvalid) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalid", rule, err)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := map[string]string{
+		"freq=weekly;byday=SU,MO":                            "FREQ=WEEKLY;BYDAY=MO,SU",
+		"RRULE:DTSTART=20250301;INTERVAL=3;FREQ=DAILY":       "FREQ=DAILY;INTERVAL=3;DTSTART=20250301",
+		recurrence.MonthDays(1, 15).String():                 "FREQ=MONTHLY;BYMONTHDAY=1,15",
+		recurrence.EveryDays(3, date("2025-03-01")).String(): "FREQ=DAILY;INTERVAL=3;DTSTART=20250301",
+		recurrence.OnWeekdays(time.Sunday).String():          "FREQ=WEEKLY;BYDAY=SU",
+	}
+	for in, want := range tests {
+		r, err := recurrence.Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", in, err)
+		}
+		if got := r.String(); got != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", in, got, want)
+		}
+	}
+}
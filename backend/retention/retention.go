@@ -0,0 +1,52 @@
+// Package retention keeps the modification tables small. Once a modification
+// has ended and every month it touches is billed by a finalized invoice, it is
+// moved to the archive, where order resolution still finds it.
+package retention
+
+import (
+	"backend/civil"
+	"backend/store"
+	"context"
+	"log"
+	"time"
+)
+
+// Job archives modifications that ended more than Keep days ago.
+type Job struct {
+	Orders   store.OrderStore
+	Keep     int
+	Location *time.Location
+}
+
+// Before returns the date a modification must have ended before to be
+// archived at now.
+func (j Job) Before(now time.Time) civil.Date {
+	return civil.Of(now.In(j.Location)).AddDays(-j.Keep)
+}
+
+// Run archives what has expired at now and returns the number of rows moved.
+func (j Job) Run(now time.Time) (int64, error) {
+	return j.Orders.ArchiveExpiredModifications(j.Before(now))
+}
+
+// Start runs the job now and then every interval until ctx is done. Failures
+// are logged and retried on the next tick.
+func (j Job) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			n, err := j.Run(time.Now())
+			if err != nil {
+				log.Printf("Error archiving expired modifications: %v\n", err)
+			} else if n > 0 {
+				log.Printf("Archived %d expired modifications\n", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,99 @@
+package retention_test
+
+import (
+	"backend/civil"
+	"backend/models"
+	"backend/retention"
+	"backend/store"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func date(y int, m time.Month, d int) civil.Date {
+	return civil.Date{Year: y, Month: m, Day: d}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		mod          store.Modification
+		wantArchived bool
+	}{
+		{
+			name:         "ended in a finalized month",
+			mod:          store.Modification{StartDate: date(2025, 1, 5), EndDate: date(2025, 1, 10)},
+			wantArchived: true,
+		},
+		{
+			name:         "alternating row in a finalized month",
+			mod:          store.Modification{StartDate: date(2025, 1, 5), EndDate: date(2025, 1, 10), Alternating: true, DayType: "ODD"},
+			wantArchived: true,
+		},
+		{
+			name: "spans into a month without a finalized invoice",
+			mod:  store.Modification{StartDate: date(2025, 1, 25), EndDate: date(2025, 2, 3)},
+		},
+		{
+			name: "ended too recently",
+			mod:  store.Modification{StartDate: date(2025, 3, 1), EndDate: date(2025, 3, 20)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory().Stores()
+			aptID, _ := st.Apartments.CreateApartment("Lake View")
+			userID, err := st.Customers.CreateCustomer(models.User{Name: "Asha", ApartmentID: aptID, RoomNumber: "101"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// January and March are billed, February is not
+			for _, month := range []int{1, 3} {
+				id, err := st.Invoices.SaveDraft(models.Invoice{UserID: userID, Year: 2025, Month: month})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := st.Invoices.FinalizeInvoice(id, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tt.mod.UserID, tt.mod.ProductID, tt.mod.Quantity = userID, "milk", 2
+			if _, err := st.Orders.AddModifications([]store.Modification{tt.mod}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Keeping 10 days on 2025-03-25 archives rows that ended before 03-15
+			job := retention.Job{Orders: st.Orders, Keep: 10, Location: time.UTC}
+			n, err := job.Run(time.Date(2025, 3, 25, 9, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := n == 1; got != tt.wantArchived {
+				t.Fatalf("archived %d rows, want archived = %v", n, tt.wantArchived)
+			}
+
+			live, _ := st.Orders.CustomerModifications(userID)
+			if got := len(live) == 0; got != tt.wantArchived {
+				t.Errorf("%d live rows left, want archived = %v", len(live), tt.wantArchived)
+			}
+			// Archived rows still count when resolving the period
+			all, _ := st.Orders.Modifications([]string{userID}, tt.mod.StartDate, tt.mod.EndDate)
+			if len(all) != 1 {
+				t.Errorf("Modifications returned %d rows, want 1", len(all))
+			}
+		})
+	}
+}
+
+func TestBefore(t *testing.T) {
+	ist, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := retention.Job{Keep: 30, Location: ist}
+	// 20:00 UTC on 31 March is already 1 April in India
+	got := job.Before(time.Date(2025, 3, 31, 20, 0, 0, 0, time.UTC))
+	if want := date(2025, 3, 2); got != want {
+		t.Errorf("Before = %s, want %s", got, want)
+	}
+}
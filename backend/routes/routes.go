@@ -1,54 +1,118 @@
 package routes
 
 import (
+	"backend/auth"
 	"backend/handlers"
 
 	"github.com/gorilla/mux"
 )
 
-func RegisterRoutes(router *mux.Router) {
+// Roles allowed on each group of routes
+var (
+	owner    = []string{auth.RoleOwner}
+	staff    = []string{auth.RoleOwner, auth.RoleManager}
+	everyone = []string{auth.RoleOwner, auth.RoleManager, auth.RoleDelivery}
+)
+
+func RegisterRoutes(root *mux.Router, s *handlers.Server) {
 	// Admin authentication routes
-	router.HandleFunc("/admin/login", handlers.AdminLogin).Methods("POST")
-	router.HandleFunc("/admin/register", handlers.AdminRegister).Methods("POST")
+	root.HandleFunc("/admin/login", s.AdminLogin).Methods("POST")
+	// Register checks the admin token or the bootstrap token itself
+	root.HandleFunc("/admin/register", s.AdminRegister).Methods("POST")
+
+	// Everything else requires a valid admin token
+	router := root.NewRoute().Subrouter()
+	router.Use(auth.Middleware(s.Admins))
+
+	router.Handle("/admin/me", auth.Allow(s.GetCurrentAdmin, everyone...)).Methods("GET")
+	router.Handle("/admins", auth.Allow(s.GetAdmins, owner...)).Methods("GET")
+	router.Handle("/admins", auth.Allow(s.CreateAdmin, owner...)).Methods("POST")
+	router.Handle("/admins/{id}", auth.Allow(s.UpdateAdmin, owner...)).Methods("PUT")
+	router.Handle("/admins/{id}", auth.Allow(s.DeleteAdmin, owner...)).Methods("DELETE")
+	// Anyone may change their own password; the handler restricts others to owners
+	router.Handle("/admins/{id}/password", auth.Allow(s.ChangeAdminPassword, everyone...)).Methods("PUT")
+	router.Handle("/admins/{id}/disable", auth.Allow(s.DisableAdmin, owner...)).Methods("POST")
+	router.Handle("/admins/{id}/enable", auth.Allow(s.EnableAdmin, owner...)).Methods("POST")
+
+	router.Handle("/products", auth.Allow(s.GetProducts, everyone...)).Methods("GET")
+	router.Handle("/products", auth.Allow(s.CreateProduct, owner...)).Methods("POST")
+	router.Handle("/products/{id}", auth.Allow(s.UpdateProduct, owner...)).Methods("PUT")
+	router.Handle("/products/{id}", auth.Allow(s.DeleteProduct, owner...)).Methods("DELETE")
+	router.Handle("/products/{id}/restore", auth.Allow(s.RestoreProduct, owner...)).Methods("POST")
+
+	router.Handle("/products/bulk", auth.Allow(s.Bulkupload, owner...)).Methods("POST")
+	router.Handle("/products/{id}/price-history", auth.Allow(s.GetProductPriceHistory, staff...)).Methods("GET")
+	router.Handle("/price-changes/upcoming", auth.Allow(s.GetUpcomingPriceChanges, staff...)).Methods("GET")
+	router.Handle("/price-changes/{id}", auth.Allow(s.CancelPriceChange, owner...)).Methods("DELETE")
+	router.Handle("/price-lists", auth.Allow(s.GetPriceList, staff...)).Methods("GET")
+	router.Handle("/price-lists", auth.Allow(s.CreatePriceListEntry, owner...)).Methods("POST")
+	router.Handle("/price-lists/{id}", auth.Allow(s.DeletePriceListEntry, owner...)).Methods("DELETE")
+	router.Handle("/price-matrix", auth.Allow(s.GetPriceMatrix, staff...)).Methods("GET")
+
+	// Delivery staff only get their assigned apartments
+	router.Handle("/apartments", auth.Allow(s.GetApartments, everyone...)).Methods("GET")
+	router.Handle("/apartments", auth.Allow(s.CreateApartment, staff...)).Methods("POST")
+	router.Handle("/apartments/{id}", auth.Allow(s.DeleteApartment, staff...)).Methods("DELETE")
+	router.Handle("/apartments/{id}/restore", auth.Allow(s.RestoreApartment, staff...)).Methods("POST")
+
+	router.Handle("/customers", auth.Allow(s.GetCustomers, staff...)).Methods("GET")
+	router.Handle("/apartcustomers", auth.Allow(s.GetApartCustomers, staff...)).Methods("GET")
+	router.Handle("/customers", auth.Allow(s.CreateCustomer, staff...)).Methods("POST")
+	router.Handle("/customers/{id}", auth.Allow(s.UpdateCustomer, staff...)).Methods("PUT")
+	router.Handle("/route-order", auth.Allow(s.GetRouteOrder, staff...)).Methods("GET")
+	router.Handle("/update-priorities", auth.Allow(s.UpdateCustomerPriorities, staff...)).Methods("PUT")
+
+	router.Handle("/customers/{id}", auth.Allow(s.DeleteCustomer, staff...)).Methods("DELETE")
+	router.Handle("/customers/{id}/restore", auth.Allow(s.RestoreCustomer, staff...)).Methods("POST")
+	router.Handle("/customers/{id}/move", auth.Allow(s.MoveCustomer, staff...)).Methods("POST")
+	router.Handle("/customers/{id}/moves", auth.Allow(s.GetCustomerMoves, staff...)).Methods("GET")
 
-	router.HandleFunc("/products", handlers.GetProducts).Methods("GET")
-	router.HandleFunc("/products", handlers.CreateProduct).Methods("POST")
-	router.HandleFunc("/products/{id}", handlers.UpdateProduct).Methods("PUT")
-	router.HandleFunc("/products/{id}", handlers.DeleteProduct).Methods("DELETE")
+	router.Handle("/bulkcustomers", auth.Allow(s.CreatebulkCustomers, staff...)).Methods("POST")
 
-	router.HandleFunc("/products/bulk", handlers.Bulkupload).Methods("POST")
-	router.HandleFunc("/products/{id}/price-history", handlers.GetProductPriceHistory).Methods("GET")
+	router.Handle("/customers/{id}/default-order", auth.Allow(s.CreateDefaultOrderUnified, staff...)).Methods("POST")
+	router.Handle("/customers/{id}/default-order", auth.Allow(s.UpdateDefaultOrderUnified, staff...)).Methods("PUT")
+	router.Handle("/customers/{id}/default-order", auth.Allow(s.GetDefaultOrderUnified, staff...)).Methods("GET")
 
-	router.HandleFunc("/apartments", handlers.GetApartments).Methods("GET")
-	router.HandleFunc("/apartments", handlers.CreateApartment).Methods("POST")
-	router.HandleFunc("/apartments/{id}", handlers.DeleteApartment).Methods("DELETE")
+	router.Handle("/orders", auth.Allow(s.GetOrders, staff...)).Methods("GET")              // Fetch orders for a month
+	router.Handle("/orders/modify", auth.Allow(s.ModifyOrder, staff...)).Methods("POST") // Modify an order
+	router.Handle("/orders/pause", auth.Allow(s.PauseOrder, staff...)).Methods("POST")   // Pause an order
+	router.Handle("/orders/resume", auth.Allow(s.ResumeOrder, staff...)).Methods("POST")
+	router.Handle("/orders/modify-alternating", auth.Allow(s.ModifyAlternatingOrder, staff...)).Methods("POST")
+	router.Handle("/customers/{id}/modifications", auth.Allow(s.GetCustomerModifications, staff...)).Methods("GET")
+	router.Handle("/customers/{id}/modifications/undo", auth.Allow(s.UndoLastModification, staff...)).Methods("POST")
+	router.Handle("/modifications/{order_id}", auth.Allow(s.DeleteModification, staff...)).Methods("DELETE")
 
-	router.HandleFunc("/customers", handlers.GetCustomers).Methods("GET")
-	router.HandleFunc("/apartcustomers", handlers.GetApartCustomers).Methods("GET")
-	router.HandleFunc("/customers", handlers.CreateCustomer).Methods("POST")
-	router.HandleFunc("/customers/{id}", handlers.UpdateCustomer).Methods("PUT")
-	//router.HandleFunc("/update-priorities", handlers.UpdateCustomerPriorities).Methods("PUT")
+	router.Handle("/customers/{id}/vacations", auth.Allow(s.GetCustomerVacations, staff...)).Methods("GET")
+	router.Handle("/customers/{id}/vacations", auth.Allow(s.CreateVacation, staff...)).Methods("POST")
+	router.Handle("/vacations/{id}", auth.Allow(s.UpdateVacation, staff...)).Methods("PUT")
+	router.Handle("/vacations/{id}", auth.Allow(s.DeleteVacation, staff...)).Methods("DELETE")
 
-	router.HandleFunc("/customers/{id}", handlers.DeleteCustomer).Methods("DELETE")
+	// Delivery staff are limited to their assigned apartments by the handlers
+	router.Handle("/daily-summary", auth.Allow(s.GetDailyOrderSummary, everyone...)).Methods("GET")
+	router.Handle("/daily-totalsummary", auth.Allow(s.GetDailyTotalSummary, everyone...)).Methods("GET")
+	router.Handle("/daily-SalesSummary", auth.Allow(s.GetDailySalesSummary, staff...)).Methods("GET")
 
-	router.HandleFunc("/bulkcustomers", handlers.CreatebulkCustomers).Methods("POST")
+	router.Handle("/monthly-bill", auth.Allow(s.GetMonthlyBill, staff...)).Methods("GET")
+	router.Handle("/monthly-bill.pdf", auth.Allow(s.GetMonthlyBillPDF, staff...)).Methods("GET")
+	router.Handle("/apartments/{id}/monthly-bills.zip", auth.Allow(s.GetApartmentMonthlyBillsZip, staff...)).Methods("GET")
+	router.Handle("/monthly-bills", auth.Allow(s.GetAllMonthlyBills, staff...)).Methods("GET")
+	router.Handle("/apartments/{id}/monthly-bills", auth.Allow(s.GetApartmentMonthlyBills, staff...)).Methods("GET")
 
-	router.HandleFunc("/customers/{id}/default-order", handlers.CreateDefaultOrderUnified).Methods("POST")
-	router.HandleFunc("/customers/{id}/default-order", handlers.UpdateDefaultOrderUnified).Methods("PUT")
-	router.HandleFunc("/customers/{id}/default-order", handlers.GetDefaultOrderUnified).Methods("GET")
+	router.Handle("/invoices", auth.Allow(s.GetInvoices, staff...)).Methods("GET")
+	router.Handle("/invoices", auth.Allow(s.GenerateInvoice, staff...)).Methods("POST")
+	router.Handle("/invoices/{id}", auth.Allow(s.GetInvoice, staff...)).Methods("GET")
+	router.Handle("/invoices/{id}/diff", auth.Allow(s.GetInvoiceDiff, staff...)).Methods("GET")
+	router.Handle("/invoices/{id}/finalize", auth.Allow(s.FinalizeInvoice, staff...)).Methods("POST")
+	router.Handle("/invoices/{id}/reopen", auth.Allow(s.ReopenInvoice, owner...)).Methods("POST")
 
-	router.HandleFunc("/orders", handlers.GetOrders).Methods("GET")       // Fetch orders for a month
-	router.HandleFunc("/orders/modify", handlers.ModifyOrder).Methods("POST")  // Modify an order
-	router.HandleFunc("/orders/pause", handlers.PauseOrder).Methods("POST")    // Pause an order
-	router.HandleFunc("/orders/resume", handlers.ResumeOrder).Methods("POST")
-	router.HandleFunc("/orders/modify-alternating",handlers.ModifyAlternatingOrder).Methods("POST")
+	router.Handle("/payments", auth.Allow(s.GetPayments, staff...)).Methods("GET")
+	router.Handle("/payments", auth.Allow(s.RecordPayment, staff...)).Methods("POST")
+	router.Handle("/payments/{id}", auth.Allow(s.DeletePayment, owner...)).Methods("DELETE")
+	router.Handle("/customers/{id}/balance", auth.Allow(s.GetCustomerBalance, staff...)).Methods("GET")
+	router.Handle("/customers/{id}/statement", auth.Allow(s.GetCustomerStatement, staff...)).Methods("GET")
 
-	router.HandleFunc("/daily-summary", handlers.GetDailyOrderSummary).Methods("GET")
-	router.HandleFunc("/daily-totalsummary", handlers.GetDailyTotalSummary).Methods("GET")
-	router.HandleFunc("/daily-SalesSummary", handlers.GetDailySalesSummary).Methods("GET")
+	router.Handle("/cutoff-overrides", auth.Allow(s.GetCutoffOverrides, staff...)).Methods("GET")
 
-	router.HandleFunc("/monthly-bill", handlers.GetMonthlyBill).Methods("GET")
-	
-	router.HandleFunc("/ordermodificationsclear", handlers.ClearExpiredOrderModifications).Methods("DELETE")
+	router.Handle("/ordermodificationsclear", auth.Allow(s.ClearExpiredOrderModifications, owner...)).Methods("DELETE")
 
 }
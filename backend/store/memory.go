@@ -0,0 +1,1132 @@
+package store
+
+import (
+	"backend/civil"
+	"backend/models"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory implements every store in memory. It is meant for tests and keeps
+// the same ordering and priority rules as Postgres.
+type Memory struct {
+	mu          sync.Mutex
+	seq         int
+	last        time.Time
+	apartments  map[string]*models.Apartment
+	customers   map[string]*models.User
+	products    map[string]*models.Product
+	history     []models.ProductPriceHistory
+	defaults    []DefaultItem
+	mods        []Modification
+	archived    []Modification
+	vacations   []Vacation
+	priceList   []PriceListEntry
+	overrides   []CutoffOverride
+	moves       []CustomerMove
+	invoices    map[string]*models.Invoice
+	payments    []models.Payment
+	admins      map[string]adminAccount
+	assignments map[string]map[string]bool // admin -> apartments
+
+	// Now stamps created_at values. Stamps are forced to increase so rows
+	// written one after the other always sort in that order.
+	Now func() time.Time
+}
+
+type adminAccount struct {
+	role   string
+	active bool
+}
+
+// NewMemory returns an empty in-memory database.
+func NewMemory() *Memory {
+	return &Memory{
+		apartments:  make(map[string]*models.Apartment),
+		customers:   make(map[string]*models.User),
+		products:    make(map[string]*models.Product),
+		invoices:    make(map[string]*models.Invoice),
+		admins:      make(map[string]adminAccount),
+		assignments: make(map[string]map[string]bool),
+		Now:         time.Now,
+	}
+}
+
+// Stores returns m behind every store interface.
+func (m *Memory) Stores() Stores {
+	return Stores{Customers: m, Products: m, Orders: m, Prices: m, Apartments: m, Vacations: m, Invoices: m, Payments: m, Admins: m}
+}
+
+// AddAdmin creates an admin account with the given role.
+func (m *Memory) AddAdmin(adminID, role string, active bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.admins[adminID] = adminAccount{role: role, active: active}
+}
+
+// AssignApartment lets a delivery admin see an apartment.
+func (m *Memory) AssignApartment(adminID, apartmentID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.assignments[adminID] == nil {
+		m.assignments[adminID] = make(map[string]bool)
+	}
+	m.assignments[adminID][apartmentID] = true
+}
+
+func (m *Memory) newID(prefix string) string {
+	m.seq++
+	return fmt.Sprintf("%s-%d", prefix, m.seq)
+}
+
+// stampLayout has a fixed width so stamps sort as strings.
+const stampLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+func (m *Memory) stamp() time.Time {
+	t := m.Now().UTC()
+	if !t.After(m.last) {
+		t = m.last.Add(time.Microsecond)
+	}
+	m.last = t
+	return t
+}
+
+// ---- customers ----
+
+func (m *Memory) ListCustomers(f CustomerFilter) ([]models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var wanted map[string]bool
+	if f.UserIDs != nil {
+		wanted = make(map[string]bool, len(f.UserIDs))
+		for _, id := range f.UserIDs {
+			wanted[id] = true
+		}
+	}
+	list := make([]models.User, 0)
+	for _, u := range m.customers {
+		if (wanted == nil || wanted[u.UserID]) && (f.ApartmentID == "" || u.ApartmentID == f.ApartmentID) && (f.IncludeArchived || !u.Archived) {
+			list = append(list, *u)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if f.NewestFirst {
+			return a.CreatedAt > b.CreatedAt
+		}
+		if a.PriorityOrder != b.PriorityOrder {
+			return a.PriorityOrder < b.PriorityOrder
+		}
+		return a.UserID < b.UserID
+	})
+	return list, nil
+}
+
+func (m *Memory) GetCustomer(userID string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.customers[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *u
+	return &c, nil
+}
+
+func (m *Memory) CreateCustomer(c models.User) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	last := 1
+	for _, u := range m.customers {
+		if u.ApartmentID == c.ApartmentID && u.PriorityOrder >= last {
+			last = u.PriorityOrder + 1
+		}
+	}
+	if c.PriorityOrder <= 0 || c.PriorityOrder >= last {
+		c.PriorityOrder = last
+	} else {
+		for _, u := range m.customers {
+			if u.ApartmentID == c.ApartmentID && u.PriorityOrder >= c.PriorityOrder {
+				u.PriorityOrder++
+			}
+		}
+	}
+	m.insertCustomer(&c)
+	m.bumpRoute(c.ApartmentID)
+	return c.UserID, nil
+}
+
+func (m *Memory) CreateCustomers(cs []models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range cs {
+		c := c
+		c.PriorityOrder = 0
+		m.insertCustomer(&c)
+	}
+	return nil
+}
+
+func (m *Memory) insertCustomer(c *models.User) {
+	c.UserID = m.newID("user")
+	c.IsAlternatingOrder = false
+	c.OrderMode = ModeNormal
+	c.CreatedAt = m.stamp().Format(stampLayout)
+	m.customers[c.UserID] = c
+}
+
+func (m *Memory) UpdateCustomer(userID string, c models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.customers[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	current := u.PriorityOrder
+	for _, o := range m.customers {
+		if o.UserID == userID || o.ApartmentID != u.ApartmentID {
+			continue
+		}
+		switch {
+		case c.PriorityOrder < current && o.PriorityOrder >= c.PriorityOrder && o.PriorityOrder < current:
+			o.PriorityOrder++
+		case c.PriorityOrder > current && o.PriorityOrder <= c.PriorityOrder && o.PriorityOrder > current:
+			o.PriorityOrder--
+		}
+	}
+	u.Name, u.RoomNumber = c.Name, c.RoomNumber
+	u.PhoneNumber, u.Email, u.PriorityOrder = c.PhoneNumber, c.Email, c.PriorityOrder
+	if c.PriorityOrder != current {
+		m.bumpRoute(u.ApartmentID)
+	}
+	return nil
+}
+
+func (m *Memory) MoveCustomer(mv CustomerMove) (CustomerMove, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.customers[mv.UserID]
+	if !ok {
+		return CustomerMove{}, ErrNotFound
+	}
+	mv.FromApartmentID, mv.FromPriority = u.ApartmentID, u.PriorityOrder
+	last := 1
+	for _, o := range m.customers {
+		if o.UserID == u.UserID || o.Archived {
+			continue
+		}
+		if o.ApartmentID == mv.FromApartmentID && o.PriorityOrder > mv.FromPriority {
+			o.PriorityOrder--
+		}
+		if o.ApartmentID == mv.ToApartmentID && o.PriorityOrder >= last {
+			last = o.PriorityOrder + 1
+		}
+	}
+	if mv.ToPriority <= 0 || mv.ToPriority >= last {
+		mv.ToPriority = last
+	} else {
+		for _, o := range m.customers {
+			if o.UserID != u.UserID && !o.Archived && o.ApartmentID == mv.ToApartmentID && o.PriorityOrder >= mv.ToPriority {
+				o.PriorityOrder++
+			}
+		}
+	}
+	u.ApartmentID, u.PriorityOrder = mv.ToApartmentID, mv.ToPriority
+	m.bumpRoute(mv.FromApartmentID)
+	m.bumpRoute(mv.ToApartmentID)
+
+	mv.MoveID = m.newID("move")
+	mv.CreatedAt = m.stamp()
+	m.moves = append(m.moves, mv)
+	return mv, nil
+}
+
+func (m *Memory) CustomerMoves(userIDs []string) ([]CustomerMove, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wanted := set(userIDs)
+	var list []CustomerMove
+	for _, mv := range m.moves {
+		if wanted[mv.UserID] {
+			list = append(list, mv)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].MovedOn.Before(list[j].MovedOn) })
+	return list, nil
+}
+
+func (m *Memory) ArchiveCustomer(userID string, today civil.Date) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.customers[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if u.Archived {
+		return nil
+	}
+	if m.inUse(func(uid, _ string) bool { return uid == userID }, today) {
+		return ErrInUse
+	}
+	for _, o := range m.customers {
+		if o.ApartmentID == u.ApartmentID && !o.Archived && o.PriorityOrder > u.PriorityOrder {
+			o.PriorityOrder--
+		}
+	}
+	u.Archived, u.ArchivedAt, u.PriorityOrder = true, m.stamp().Format(stampLayout), 0
+	m.bumpRoute(u.ApartmentID)
+	return nil
+}
+
+func (m *Memory) RestoreCustomer(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.customers[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if !u.Archived {
+		return nil
+	}
+	last := 0
+	for _, o := range m.customers {
+		if o.ApartmentID == u.ApartmentID && !o.Archived && o.PriorityOrder > last {
+			last = o.PriorityOrder
+		}
+	}
+	u.Archived, u.ArchivedAt, u.PriorityOrder = false, "", last+1
+	m.bumpRoute(u.ApartmentID)
+	return nil
+}
+
+// inUse reports whether refers matches the customer and product of a default
+// item or a modification, either of them ending on or after today.
+func (m *Memory) inUse(refers func(userID, productID string) bool, today civil.Date) bool {
+	for _, it := range m.defaults {
+		if refers(it.UserID, it.ProductID) && (it.EffectiveTo.IsZero() || !it.EffectiveTo.Before(today)) {
+			return true
+		}
+	}
+	for _, md := range m.mods {
+		if refers(md.UserID, md.ProductID) && !md.EndDate.Before(today) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) ReorderCustomers(apartmentID string, userIDs []string, version int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.apartments[apartmentID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if a.RouteVersion != version {
+		return 0, ErrStale
+	}
+	var active []string
+	for _, u := range m.customers {
+		if u.ApartmentID == apartmentID && !u.Archived {
+			active = append(active, u.UserID)
+		}
+	}
+	if !samePeople(active, userIDs) {
+		return 0, ErrStale
+	}
+	for i, id := range userIDs {
+		m.customers[id].PriorityOrder = i + 1
+	}
+	a.RouteVersion++
+	return a.RouteVersion, nil
+}
+
+// bumpRoute marks an apartment's delivery order as changed.
+func (m *Memory) bumpRoute(apartmentID string) {
+	if a, ok := m.apartments[apartmentID]; ok {
+		a.RouteVersion++
+	}
+}
+
+// ---- products ----
+
+func (m *Memory) ListProducts(includeArchived bool) ([]models.Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]models.Product, 0, len(m.products))
+	for _, p := range m.products {
+		if includeArchived || !p.Archived {
+			list = append(list, *p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ProductID < list[j].ProductID })
+	return list, nil
+}
+
+func (m *Memory) GetProduct(productID string) (*models.Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.products[productID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *p
+	return &c, nil
+}
+
+func (m *Memory) CreateProduct(p models.Product) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p.ProductID = m.newID("product")
+	p.CreatedAt = m.stamp().Format(stampLayout)
+	m.products[p.ProductID] = &p
+	return p.ProductID, nil
+}
+
+func (m *Memory) UpdateProduct(p models.Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.products[p.ProductID]
+	if !ok {
+		return ErrNotFound
+	}
+	p.CreatedAt = old.CreatedAt
+	m.products[p.ProductID] = &p
+	return nil
+}
+
+func (m *Memory) ArchiveProduct(productID string, today civil.Date) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.products[productID]
+	if !ok {
+		return ErrNotFound
+	}
+	if p.Archived {
+		return nil
+	}
+	if m.inUse(func(_, pid string) bool { return pid == productID }, today) {
+		return ErrInUse
+	}
+	p.Archived, p.ArchivedAt = true, m.stamp().Format(stampLayout)
+	return nil
+}
+
+func (m *Memory) RestoreProduct(productID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.products[productID]
+	if !ok {
+		return ErrNotFound
+	}
+	p.Archived, p.ArchivedAt = false, ""
+	return nil
+}
+
+// ---- prices ----
+
+func (m *Memory) CurrentPrices() (map[string]float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prices := make(map[string]float64, len(m.products))
+	for id, p := range m.products {
+		prices[id] = p.CurrentPrice
+	}
+	return prices, nil
+}
+
+func (m *Memory) PriceChanges(productID string) ([]models.ProductPriceHistory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := filterHistory(m.history, func(h models.ProductPriceHistory) bool {
+		return productID == "" || h.ProductID == productID
+	})
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.ProductID != b.ProductID {
+			return a.ProductID < b.ProductID
+		}
+		return a.EffectiveFrom.Before(b.EffectiveFrom)
+	})
+	return list, nil
+}
+
+func (m *Memory) PriceChange(priceID string) (*models.ProductPriceHistory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.history {
+		if h.PriceID == priceID {
+			return &h, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) AddPriceChange(h models.ProductPriceHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h.PriceID = m.newID("price")
+	h.UpdatedAt = m.stamp().Format(stampLayout)
+	if next := m.nextPriceChange(h); next != nil {
+		next.OldPrice = h.NewPrice
+	}
+	m.history = append(m.history, h)
+	return nil
+}
+
+// nextPriceChange returns the earliest change of h's product that takes
+// effect after h, or nil.
+func (m *Memory) nextPriceChange(h models.ProductPriceHistory) *models.ProductPriceHistory {
+	var next *models.ProductPriceHistory
+	for i := range m.history {
+		c := &m.history[i]
+		if c.ProductID == h.ProductID && c.EffectiveFrom.After(h.EffectiveFrom) && (next == nil || c.EffectiveFrom.Before(next.EffectiveFrom)) {
+			next = c
+		}
+	}
+	return next
+}
+
+func (m *Memory) PriceListEntries(apartmentID, userID string) ([]PriceListEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := filterPriceList(m.priceList, func(e PriceListEntry) bool {
+		return (apartmentID == "" || e.ApartmentID == apartmentID) && (userID == "" || e.UserID == userID)
+	})
+	sort.SliceStable(list, func(i, j int) bool { return list[i].EffectiveFrom.Before(list[j].EffectiveFrom) })
+	return list, nil
+}
+
+func (m *Memory) GetPriceListEntry(entryID string) (*PriceListEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.priceList {
+		if e.EntryID == entryID {
+			return &e, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) CreatePriceListEntry(e PriceListEntry) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.EntryID = m.newID("pricelist")
+	e.CreatedAt = m.stamp()
+	m.priceList = append(m.priceList, e)
+	return e.EntryID, nil
+}
+
+func (m *Memory) DeletePriceListEntry(entryID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.priceList)
+	m.priceList = filterPriceList(m.priceList, func(e PriceListEntry) bool { return e.EntryID != entryID })
+	if len(m.priceList) == n {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *Memory) DeletePriceChange(priceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.history {
+		if h.PriceID != priceID {
+			continue
+		}
+		m.history = filterHistory(m.history, func(c models.ProductPriceHistory) bool { return c.PriceID != priceID })
+		if next := m.nextPriceChange(h); next != nil {
+			next.OldPrice = h.OldPrice
+		}
+		return nil
+	}
+	return ErrNotFound
+}
+
+// ---- apartments ----
+
+func (m *Memory) ListApartments(adminID string, includeArchived bool) ([]models.Apartment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]models.Apartment, 0)
+	for _, a := range m.apartments {
+		if (adminID == "" || m.assignments[adminID][a.ApartmentID]) && (includeArchived || !a.Archived) {
+			list = append(list, *a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return list, nil
+}
+
+func (m *Memory) GetApartment(apartmentID string) (*models.Apartment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.apartments[apartmentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *a
+	return &c, nil
+}
+
+func (m *Memory) CreateApartment(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := &models.Apartment{ApartmentID: m.newID("apartment"), ApartmentName: name, CreatedAt: m.stamp().Format(stampLayout)}
+	m.apartments[a.ApartmentID] = a
+	return a.ApartmentID, nil
+}
+
+func (m *Memory) ArchiveApartment(apartmentID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.apartments[apartmentID]
+	if !ok {
+		return ErrNotFound
+	}
+	if a.Archived {
+		return nil
+	}
+	for _, u := range m.customers {
+		if u.ApartmentID == apartmentID && !u.Archived {
+			return ErrInUse
+		}
+	}
+	a.Archived, a.ArchivedAt = true, m.stamp().Format(stampLayout)
+	return nil
+}
+
+func (m *Memory) RestoreApartment(apartmentID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.apartments[apartmentID]
+	if !ok {
+		return ErrNotFound
+	}
+	a.Archived, a.ArchivedAt = false, ""
+	return nil
+}
+
+// ---- orders ----
+
+func (m *Memory) Defaults(userIDs []string) ([]DefaultItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wanted := set(userIDs)
+	items := filterDefaults(m.defaults, func(it DefaultItem) bool { return wanted[it.UserID] })
+	sort.SliceStable(items, func(i, j int) bool { return items[i].ProductID < items[j].ProductID })
+	return items, nil
+}
+
+func (m *Memory) ReplaceDefaults(userID string, mode string, anchor, from civil.Date, items []DefaultItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.customers[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	m.defaults = filterDefaults(m.defaults, func(it DefaultItem) bool {
+		return it.UserID != userID || (!from.IsZero() && it.EffectiveFrom.Before(from))
+	})
+	for i, it := range m.defaults {
+		if it.UserID == userID && (it.EffectiveTo.IsZero() || !it.EffectiveTo.Before(from)) {
+			m.defaults[i].EffectiveTo = from.AddDays(-1)
+		}
+	}
+	for _, it := range items {
+		it.UserID, it.EffectiveFrom, it.EffectiveTo = userID, from, civil.Date{}
+		m.defaults = append(m.defaults, it)
+	}
+	u.OrderMode, u.IsAlternatingOrder = mode, mode == ModeAlternating
+	switch {
+	case mode != ModeAlternating:
+		u.AlternatingAnchor = civil.Date{}
+	case !anchor.IsZero():
+		u.AlternatingAnchor = anchor
+	}
+	return nil
+}
+
+func (m *Memory) Modifications(userIDs []string, start, end civil.Date) ([]Modification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wanted := set(userIDs)
+	overlaps := func(md Modification) bool {
+		return wanted[md.UserID] && !md.StartDate.After(end) && !md.EndDate.Before(start)
+	}
+	mods := append(filterMods(m.mods, overlaps), filterMods(m.archived, overlaps)...)
+	sort.SliceStable(mods, func(i, j int) bool { return mods[i].ProductID < mods[j].ProductID })
+	return mods, nil
+}
+
+func (m *Memory) CustomerModifications(userID string) ([]Modification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return newestFirst(filterMods(m.mods, func(md Modification) bool { return md.UserID == userID })), nil
+}
+
+func (m *Memory) ModificationBatch(orderID string) ([]Modification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mods := filterMods(m.mods, func(md Modification) bool { return md.OrderID == orderID })
+	if len(mods) == 0 {
+		return nil, ErrNotFound
+	}
+	return newestFirst(mods), nil
+}
+
+func (m *Memory) AddModifications(mods []Modification) (string, error) {
+	ids, err := m.AddModificationBatches([][]Modification{mods})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+func (m *Memory) AddModificationBatches(batches [][]Modification) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Like NOW() in one transaction, every batch gets the same time
+	createdAt := m.stamp()
+	ids := make([]string, 0, len(batches))
+	for _, mods := range batches {
+		ids = append(ids, m.insertBatch(mods, createdAt))
+	}
+	return ids, nil
+}
+
+func (m *Memory) insertBatch(mods []Modification, createdAt time.Time) string {
+	orderID := m.newID("order")
+	for _, md := range mods {
+		md.ModificationID = m.newID("modification")
+		md.OrderID = orderID
+		md.CreatedAt = createdAt
+		if !md.Alternating {
+			md.DayType = ""
+		}
+		if md.Alternating || md.Kind == "" {
+			md.Kind = KindReplace
+		}
+		m.mods = append(m.mods, md)
+	}
+	return orderID
+}
+
+func (m *Memory) DeleteModificationBatches(orderIDs ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.mods)
+	doomed := set(orderIDs)
+	m.mods = filterMods(m.mods, func(md Modification) bool { return !doomed[md.OrderID] })
+	if len(m.mods) == n {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *Memory) ArchiveExpiredModifications(before civil.Date) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.mods)
+	m.mods = filterMods(m.mods, func(md Modification) bool {
+		if !md.EndDate.Before(before) || !m.billed(md) {
+			return true
+		}
+		m.archived = append(m.archived, md)
+		return false
+	})
+	return int64(n - len(m.mods)), nil
+}
+
+// billed reports whether every month md touches has a finalized invoice.
+func (m *Memory) billed(md Modification) bool {
+	for d := md.StartDate.FirstOfMonth(); !d.After(md.EndDate); d = d.AddMonths(1) {
+		if m.finalized(md.UserID, d.Year, int(d.Month)) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// newestFirst orders rows like the Postgres store: newest batch first, then
+// by order id and product.
+func newestFirst(mods []Modification) []Modification {
+	sort.SliceStable(mods, func(i, j int) bool {
+		a, b := mods[i], mods[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		if a.OrderID != b.OrderID {
+			return a.OrderID < b.OrderID
+		}
+		return a.ProductID < b.ProductID
+	})
+	return mods
+}
+
+func (m *Memory) RecordCutoffOverride(o CutoffOverride) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o.OverrideID = m.newID("override")
+	o.CreatedAt = m.stamp()
+	m.overrides = append(m.overrides, o)
+	return nil
+}
+
+func (m *Memory) CutoffOverrides(from, to time.Time) ([]CutoffOverride, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []CutoffOverride
+	for i := len(m.overrides) - 1; i >= 0; i-- {
+		if o := m.overrides[i]; !o.CreatedAt.Before(from) && o.CreatedAt.Before(to) {
+			list = append(list, o)
+		}
+	}
+	return list, nil
+}
+
+// ---- vacations ----
+
+func (m *Memory) Vacations(userIDs []string, start, end civil.Date) ([]Vacation, error) {
+	wanted := set(userIDs)
+	return m.findVacations(func(v Vacation) bool {
+		return wanted[v.UserID] && !v.StartDate.After(end) && !v.EndDate.Before(start)
+	}), nil
+}
+
+func (m *Memory) CustomerVacations(userID string) ([]Vacation, error) {
+	return m.findVacations(func(v Vacation) bool { return v.UserID == userID }), nil
+}
+
+func (m *Memory) GetVacation(vacationID string) (*Vacation, error) {
+	list := m.findVacations(func(v Vacation) bool { return v.VacationID == vacationID })
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+// findVacations returns copies of the matching vacations, latest start first.
+func (m *Memory) findVacations(keep func(Vacation) bool) []Vacation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []Vacation
+	for _, v := range m.vacations {
+		if keep(v) {
+			v.ProductIDs = append([]string(nil), v.ProductIDs...)
+			list = append(list, v)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].StartDate != list[j].StartDate {
+			return list[i].StartDate.After(list[j].StartDate)
+		}
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+func (m *Memory) CreateVacation(v Vacation) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.customers[v.UserID]; !ok {
+		return "", fmt.Errorf("store: insert vacation: unknown user %s", v.UserID)
+	}
+	v.VacationID = m.newID("vacation")
+	v.ProductIDs = append([]string(nil), v.ProductIDs...)
+	v.CreatedAt = m.stamp()
+	m.vacations = append(m.vacations, v)
+	return v.VacationID, nil
+}
+
+func (m *Memory) UpdateVacation(v Vacation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.vacations {
+		if m.vacations[i].VacationID == v.VacationID {
+			stored := &m.vacations[i]
+			stored.StartDate, stored.EndDate = v.StartDate, v.EndDate
+			stored.Reason = v.Reason
+			stored.ProductIDs = append([]string(nil), v.ProductIDs...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) DeleteVacation(vacationID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, v := range m.vacations {
+		if v.VacationID == vacationID {
+			m.vacations = append(m.vacations[:i], m.vacations[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ---- invoices ----
+
+func (m *Memory) SaveDraft(inv models.Invoice) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.invoiceOf(inv.UserID, inv.Year, inv.Month)
+	switch {
+	case stored == nil:
+		stored = &models.Invoice{InvoiceID: m.newID("invoice"), UserID: inv.UserID, Year: inv.Year, Month: inv.Month, Status: "draft"}
+		m.invoices[stored.InvoiceID] = stored
+	case stored.Status != "draft":
+		return "", ErrWrongStatus
+	}
+	stored.TotalAmount = inv.TotalAmount
+	stored.GeneratedAt = m.stamp().Format(stampLayout)
+	stored.Lines = make([]models.InvoiceLine, 0, len(inv.Lines))
+	for _, line := range inv.Lines {
+		line.LineID = m.newID("line")
+		stored.Lines = append(stored.Lines, line)
+	}
+	return stored.InvoiceID, nil
+}
+
+// invoiceOf returns the customer's invoice for the month, or nil.
+func (m *Memory) invoiceOf(userID string, year, month int) *models.Invoice {
+	for _, inv := range m.invoices {
+		if inv.UserID == userID && inv.Year == year && inv.Month == month {
+			return inv
+		}
+	}
+	return nil
+}
+
+// finalized returns the customer's invoice for the month if it is finalized.
+func (m *Memory) finalized(userID string, year, month int) *models.Invoice {
+	if inv := m.invoiceOf(userID, year, month); inv != nil && inv.Status == "finalized" {
+		return inv
+	}
+	return nil
+}
+
+func (m *Memory) GetInvoice(invoiceID string) (*models.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inv, ok := m.invoices[invoiceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *inv
+	c.Lines = append([]models.InvoiceLine{}, inv.Lines...)
+	return &c, nil
+}
+
+func (m *Memory) ListInvoices(userID string, year, month int) ([]models.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]models.Invoice, 0)
+	for _, inv := range m.invoices {
+		if (userID == "" || inv.UserID == userID) && (year == 0 || inv.Year == year) && (month == 0 || inv.Month == month) {
+			c := *inv
+			c.Lines = nil
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Year != b.Year {
+			return a.Year > b.Year
+		}
+		if a.Month != b.Month {
+			return a.Month > b.Month
+		}
+		return a.UserID < b.UserID
+	})
+	return list, nil
+}
+
+func (m *Memory) FinalizeInvoice(invoiceID, adminID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inv, ok := m.invoices[invoiceID]
+	if !ok {
+		return ErrNotFound
+	}
+	if inv.Status != "draft" {
+		return ErrWrongStatus
+	}
+	inv.Status, inv.FinalizedAt, inv.FinalizedBy = "finalized", m.stamp().Format(stampLayout), adminID
+	return nil
+}
+
+func (m *Memory) ReopenInvoice(invoiceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inv, ok := m.invoices[invoiceID]
+	if !ok {
+		return ErrNotFound
+	}
+	if inv.Status != "finalized" {
+		return ErrWrongStatus
+	}
+	inv.Status, inv.FinalizedAt, inv.FinalizedBy = "draft", "", ""
+	return nil
+}
+
+func (m *Memory) InvoiceLocked(userID string, start, end civil.Date) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, inv := range m.invoices {
+		if inv.UserID != userID || inv.Status != "finalized" {
+			continue
+		}
+		first := civil.Date{Year: inv.Year, Month: time.Month(inv.Month), Day: 1}
+		if !first.AddMonths(1).AddDays(-1).Before(start) && (end.IsZero() || !first.After(end)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) PriceLocked(productID, userID string, start, end civil.Date) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, inv := range m.invoices {
+		if inv.Status != "finalized" || (userID != "" && inv.UserID != userID) {
+			continue
+		}
+		for _, line := range inv.Lines {
+			date := line.DeliveryDate
+			if line.ProductID == productID && !date.Before(start) && (end.IsZero() || !date.After(end)) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ---- payments ----
+
+func (m *Memory) CreatePayment(p models.Payment) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p.PaymentID = m.newID("payment")
+	p.CreatedAt = m.stamp().Format(stampLayout)
+	m.payments = append(m.payments, p)
+	return p.PaymentID, nil
+}
+
+func (m *Memory) GetPayment(paymentID string) (*models.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.payments {
+		if p.PaymentID == paymentID {
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) ListPayments(userID string, from, to civil.Date) ([]models.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]models.Payment, 0)
+	for _, p := range m.payments {
+		date := p.PaymentDate
+		if (userID == "" || p.UserID == userID) && (from.IsZero() || !date.Before(from)) && (to.IsZero() || !date.After(to)) {
+			list = append(list, p)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].PaymentDate != list[j].PaymentDate {
+			return list[i].PaymentDate.Before(list[j].PaymentDate)
+		}
+		return list[i].CreatedAt < list[j].CreatedAt
+	})
+	return list, nil
+}
+
+func (m *Memory) DeletePayment(paymentID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.payments {
+		if p.PaymentID == paymentID {
+			m.payments = append(m.payments[:i], m.payments[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ---- admins ----
+
+func (m *Memory) AdminRole(adminID string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.admins[adminID]
+	if !ok {
+		return "", false, ErrNotFound
+	}
+	return a.role, a.active, nil
+}
+
+func (m *Memory) AdminCanAccess(adminID, apartmentID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.assignments[adminID][apartmentID], nil
+}
+
+func set(ids []string) map[string]bool {
+	s := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		s[id] = true
+	}
+	return s
+}
+
+func filterDefaults(items []DefaultItem, keep func(DefaultItem) bool) []DefaultItem {
+	var out []DefaultItem
+	for _, it := range items {
+		if keep(it) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func filterMods(mods []Modification, keep func(Modification) bool) []Modification {
+	var out []Modification
+	for _, md := range mods {
+		if keep(md) {
+			out = append(out, md)
+		}
+	}
+	return out
+}
+
+func filterPriceList(list []PriceListEntry, keep func(PriceListEntry) bool) []PriceListEntry {
+	var out []PriceListEntry
+	for _, e := range list {
+		if keep(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func filterHistory(list []models.ProductPriceHistory, keep func(models.ProductPriceHistory) bool) []models.ProductPriceHistory {
+	out := make([]models.ProductPriceHistory, 0)
+	for _, h := range list {
+		if keep(h) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package store_test
+
+import (
+	"backend/civil"
+	"backend/models"
+	"backend/store"
+	"testing"
+	"time"
+)
+
+func TestArchiveAfterDefaultsEnd(t *testing.T) {
+	day := func(d int) civil.Date { return civil.Date{Year: 2025, Month: time.May, Day: d} }
+	tests := []struct {
+		name    string
+		clearOn civil.Date // zero keeps the default order
+		today   civil.Date
+		want    error
+	}{
+		{"default order in force", civil.Date{}, day(10), store.ErrInUse},
+		{"default order cleared from tomorrow", day(11), day(10), store.ErrInUse},
+		{"default order cleared", day(10), day(10), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory().Stores()
+			aptID, _ := st.Apartments.CreateApartment("Lake View")
+			userID, _ := st.Customers.CreateCustomer(models.User{Name: "Asha", ApartmentID: aptID})
+			productID, _ := st.Products.CreateProduct(models.Product{ProductName: "Milk", Unit: "L", CurrentPrice: 30})
+			err := st.Orders.ReplaceDefaults(userID, store.ModeNormal, civil.Date{}, day(1), []store.DefaultItem{{ProductID: productID, Quantity: 1}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.clearOn.IsZero() {
+				if err := st.Orders.ReplaceDefaults(userID, store.ModeNormal, civil.Date{}, tt.clearOn, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := st.Products.ArchiveProduct(productID, tt.today); err != tt.want {
+				t.Errorf("ArchiveProduct: got %v, want %v", err, tt.want)
+			}
+			if err := st.Customers.ArchiveCustomer(userID, tt.today); err != tt.want {
+				t.Errorf("ArchiveCustomer: got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
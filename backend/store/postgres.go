@@ -0,0 +1,1477 @@
+package store
+
+import (
+	"backend/civil"
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres implements every store on top of a database/sql handle.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres returns the stores backed by db.
+func NewPostgres(db *sql.DB) Stores {
+	p := &Postgres{db: db}
+	return Stores{Customers: p, Products: p, Orders: p, Prices: p, Apartments: p, Vacations: p, Invoices: p, Payments: p, Admins: p}
+}
+
+// withTx runs fn in a transaction and commits when it returns nil.
+func (p *Postgres) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ---- customers ----
+
+func (p *Postgres) ListCustomers(f CustomerFilter) ([]models.User, error) {
+	order := "priority_order, user_id"
+	if f.NewestFirst {
+		order = "created_at DESC"
+	}
+	var ids interface{}
+	if f.UserIDs != nil {
+		ids = pq.Array(f.UserIDs)
+	}
+	rows, err := p.db.Query(`
+		SELECT user_id, name, apartment_id, room_number, phone_number, COALESCE(email, ''),
+		       priority_order, is_alternating_order, order_mode, alternating_anchor, created_at, archived_at
+		  FROM users
+		 WHERE ($1::text[] IS NULL OR user_id::text = ANY($1))
+		   AND ($2 = '' OR apartment_id::text = $2)
+		   AND ($3 OR archived_at IS NULL)
+		 ORDER BY `+order, ids, f.ApartmentID, f.IncludeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("store: list customers: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.User, 0)
+	for rows.Next() {
+		var u models.User
+		var archivedAt sql.NullString
+		err := rows.Scan(&u.UserID, &u.Name, &u.ApartmentID, &u.RoomNumber, &u.PhoneNumber, &u.Email,
+			&u.PriorityOrder, &u.IsAlternatingOrder, &u.OrderMode, &u.AlternatingAnchor, &u.CreatedAt, &archivedAt)
+		if err != nil {
+			return nil, fmt.Errorf("store: scan customer: %w", err)
+		}
+		u.Archived, u.ArchivedAt = archivedAt.Valid, archivedAt.String
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) GetCustomer(userID string) (*models.User, error) {
+	list, err := p.ListCustomers(CustomerFilter{UserIDs: []string{userID}, IncludeArchived: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+func (p *Postgres) CreateCustomer(c models.User) (string, error) {
+	var id string
+	err := p.withTx(func(tx *sql.Tx) error {
+		// Append position
+		var last int
+		err := tx.QueryRow("SELECT COALESCE(MAX(priority_order), 0) + 1 FROM users WHERE apartment_id = $1", c.ApartmentID).Scan(&last)
+		if err != nil {
+			return fmt.Errorf("store: last priority: %w", err)
+		}
+		if c.PriorityOrder <= 0 || c.PriorityOrder >= last {
+			c.PriorityOrder = last
+		} else {
+			_, err := tx.Exec(`
+				UPDATE users SET priority_order = priority_order + 1
+				 WHERE apartment_id = $1 AND priority_order >= $2
+			`, c.ApartmentID, c.PriorityOrder)
+			if err != nil {
+				return fmt.Errorf("store: shift priorities: %w", err)
+			}
+		}
+
+		err = tx.QueryRow(`
+			INSERT INTO users (user_id, name, apartment_id, room_number, phone_number, email, priority_order)
+			VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6)
+			RETURNING user_id
+		`, c.Name, c.ApartmentID, c.RoomNumber, c.PhoneNumber, c.Email, c.PriorityOrder).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("store: insert customer: %w", err)
+		}
+		return bumpRoute(tx, c.ApartmentID)
+	})
+	return id, err
+}
+
+func (p *Postgres) CreateCustomers(cs []models.User) error {
+	return p.withTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`
+			INSERT INTO users (user_id, name, apartment_id, room_number, phone_number, email)
+			VALUES (gen_random_uuid(), $1, $2, $3, $4, $5)
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, c := range cs {
+			if _, err := stmt.Exec(c.Name, c.ApartmentID, c.RoomNumber, c.PhoneNumber, c.Email); err != nil {
+				return fmt.Errorf("store: insert customer: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (p *Postgres) UpdateCustomer(userID string, c models.User) error {
+	return p.withTx(func(tx *sql.Tx) error {
+		var current int
+		var apartmentID string
+		err := tx.QueryRow("SELECT priority_order, apartment_id FROM users WHERE user_id::text = $1", userID).Scan(&current, &apartmentID)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		c.ApartmentID = apartmentID
+
+		if c.PriorityOrder < current {
+			_, err = tx.Exec(`
+				UPDATE users SET priority_order = priority_order + 1
+				 WHERE apartment_id = $1 AND priority_order >= $2 AND priority_order < $3 AND user_id::text != $4
+			`, c.ApartmentID, c.PriorityOrder, current, userID)
+		} else if c.PriorityOrder > current {
+			_, err = tx.Exec(`
+				UPDATE users SET priority_order = priority_order - 1
+				 WHERE apartment_id = $1 AND priority_order <= $2 AND priority_order > $3 AND user_id::text != $4
+			`, c.ApartmentID, c.PriorityOrder, current, userID)
+		}
+		if err != nil {
+			return fmt.Errorf("store: reorder customers: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			UPDATE users
+			   SET name = $1, room_number = $2, phone_number = $3, email = $4, priority_order = $5
+			 WHERE user_id::text = $6
+		`, c.Name, c.RoomNumber, c.PhoneNumber, c.Email, c.PriorityOrder, userID)
+		if err != nil {
+			return fmt.Errorf("store: update customer: %w", err)
+		}
+		if c.PriorityOrder != current {
+			return bumpRoute(tx, apartmentID)
+		}
+		return nil
+	})
+}
+
+func (p *Postgres) MoveCustomer(mv CustomerMove) (CustomerMove, error) {
+	err := p.withTx(func(tx *sql.Tx) error {
+		// 1) Where the customer is now
+		err := tx.QueryRow(`
+			SELECT apartment_id, priority_order FROM users WHERE user_id::text = $1 FOR UPDATE
+		`, mv.UserID).Scan(&mv.FromApartmentID, &mv.FromPriority)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// 2) Close the gap in the old apartment
+		_, err = tx.Exec(`
+			UPDATE users SET priority_order = priority_order - 1
+			 WHERE apartment_id = $1 AND priority_order > $2 AND archived_at IS NULL
+		`, mv.FromApartmentID, mv.FromPriority)
+		if err != nil {
+			return fmt.Errorf("store: close priority gap: %w", err)
+		}
+
+		// 3) Make room in the new one, or append
+		var last int
+		err = tx.QueryRow(`
+			SELECT COALESCE(MAX(priority_order), 0) + 1 FROM users WHERE apartment_id::text = $1 AND archived_at IS NULL
+		`, mv.ToApartmentID).Scan(&last)
+		if err != nil {
+			return fmt.Errorf("store: last priority: %w", err)
+		}
+		if mv.ToPriority <= 0 || mv.ToPriority >= last {
+			mv.ToPriority = last
+		} else {
+			_, err := tx.Exec(`
+				UPDATE users SET priority_order = priority_order + 1
+				 WHERE apartment_id::text = $1 AND priority_order >= $2 AND archived_at IS NULL
+			`, mv.ToApartmentID, mv.ToPriority)
+			if err != nil {
+				return fmt.Errorf("store: shift priorities: %w", err)
+			}
+		}
+
+		// 4) Move the customer and record it
+		_, err = tx.Exec(`
+			UPDATE users SET apartment_id = $1, priority_order = $2 WHERE user_id::text = $3
+		`, mv.ToApartmentID, mv.ToPriority, mv.UserID)
+		if err != nil {
+			return fmt.Errorf("store: move customer: %w", err)
+		}
+		err = tx.QueryRow(`
+			INSERT INTO customer_moves (user_id, from_apartment_id, to_apartment_id, from_priority, to_priority, moved_on, admin_id)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::uuid)
+			RETURNING move_id, created_at
+		`, mv.UserID, mv.FromApartmentID, mv.ToApartmentID, mv.FromPriority, mv.ToPriority,
+			mv.MovedOn, mv.AdminID).Scan(&mv.MoveID, &mv.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("store: record customer move: %w", err)
+		}
+		if err := bumpRoute(tx, mv.FromApartmentID); err != nil {
+			return err
+		}
+		return bumpRoute(tx, mv.ToApartmentID)
+	})
+	return mv, err
+}
+
+func (p *Postgres) CustomerMoves(userIDs []string) ([]CustomerMove, error) {
+	rows, err := p.db.Query(`
+		SELECT move_id, user_id, from_apartment_id, to_apartment_id, from_priority, to_priority,
+		       moved_on, COALESCE(admin_id::text, ''), created_at
+		  FROM customer_moves
+		 WHERE user_id::text = ANY($1)
+		 ORDER BY moved_on, created_at
+	`, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("store: load customer moves: %w", err)
+	}
+	defer rows.Close()
+
+	var list []CustomerMove
+	for rows.Next() {
+		var mv CustomerMove
+		err := rows.Scan(&mv.MoveID, &mv.UserID, &mv.FromApartmentID, &mv.ToApartmentID,
+			&mv.FromPriority, &mv.ToPriority, &mv.MovedOn, &mv.AdminID, &mv.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("store: scan customer move: %w", err)
+		}
+		list = append(list, mv)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) ArchiveCustomer(userID string, today civil.Date) error {
+	return p.withTx(func(tx *sql.Tx) error {
+		var apartmentID string
+		var priority int
+		var archived bool
+		err := tx.QueryRow(`
+			SELECT apartment_id, priority_order, archived_at IS NOT NULL FROM users WHERE user_id::text = $1 FOR UPDATE
+		`, userID).Scan(&apartmentID, &priority, &archived)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if archived {
+			return nil
+		}
+		if err := checkUnused(tx, "user_id", userID, today); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE users SET archived_at = NOW(), priority_order = 0 WHERE user_id::text = $1", userID); err != nil {
+			return fmt.Errorf("store: archive customer: %w", err)
+		}
+		_, err = tx.Exec(`
+			UPDATE users SET priority_order = priority_order - 1
+			 WHERE apartment_id = $1 AND priority_order > $2 AND archived_at IS NULL
+		`, apartmentID, priority)
+		if err != nil {
+			return fmt.Errorf("store: close priority gap: %w", err)
+		}
+		return bumpRoute(tx, apartmentID)
+	})
+}
+
+func (p *Postgres) RestoreCustomer(userID string) error {
+	res, err := p.db.Exec(`
+		WITH restored AS (
+			UPDATE users u
+			   SET archived_at = NULL,
+			       priority_order = (SELECT COALESCE(MAX(o.priority_order), 0) + 1 FROM users o
+			                          WHERE o.apartment_id = u.apartment_id AND o.archived_at IS NULL)
+			 WHERE user_id::text = $1 AND archived_at IS NOT NULL
+			RETURNING apartment_id
+		)
+		UPDATE apartments SET route_version = route_version + 1
+		 WHERE apartment_id IN (SELECT apartment_id FROM restored)
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("store: restore customer: %w", err)
+	}
+	return p.checkRestored(res, "users", "user_id", userID)
+}
+
+// checkUnused returns ErrInUse when a default item or a modification, either
+// of them ending on or after today, refers to the row whose column equals id.
+func checkUnused(tx *sql.Tx, column, id string, today civil.Date) error {
+	var used bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM default_order_items
+		                WHERE `+column+`::text = $1 AND (effective_to IS NULL OR effective_to >= $2))
+		    OR EXISTS (SELECT 1 FROM order_modifications WHERE `+column+`::text = $1 AND end_date >= $2)
+		    OR EXISTS (SELECT 1 FROM alternating_order_modifications WHERE `+column+`::text = $1 AND end_date >= $2)
+	`, id, today).Scan(&used)
+	if err != nil {
+		return fmt.Errorf("store: check references: %w", err)
+	}
+	if used {
+		return ErrInUse
+	}
+	return nil
+}
+
+// checkRestored tells a missing row from one that was not archived, which
+// is left as it is.
+func (p *Postgres) checkRestored(res sql.Result, table, column, id string) error {
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	var exists bool
+	err := p.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE `+column+`::text = $1)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) ReorderCustomers(apartmentID string, userIDs []string, version int) (int, error) {
+	err := p.withTx(func(tx *sql.Tx) error {
+		// 1) Lock the apartment and compare versions
+		var current int
+		err := tx.QueryRow(`
+			SELECT route_version FROM apartments WHERE apartment_id::text = $1 FOR UPDATE
+		`, apartmentID).Scan(&current)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if current != version {
+			return ErrStale
+		}
+
+		// 2) The list must be exactly the active customers
+		rows, err := tx.Query(`
+			SELECT user_id::text FROM users WHERE apartment_id::text = $1 AND archived_at IS NULL
+		`, apartmentID)
+		if err != nil {
+			return fmt.Errorf("store: load apartment customers: %w", err)
+		}
+		var active []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("store: scan apartment customer: %w", err)
+			}
+			active = append(active, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if !samePeople(active, userIDs) {
+			return ErrStale
+		}
+
+		// 3) Number them 1..N in the given order
+		_, err = tx.Exec(`
+			UPDATE users u SET priority_order = o.position
+			  FROM unnest($1::text[]) WITH ORDINALITY AS o (user_id, position)
+			 WHERE u.user_id::text = o.user_id
+		`, pq.Array(userIDs))
+		if err != nil {
+			return fmt.Errorf("store: reorder customers: %w", err)
+		}
+		return bumpRoute(tx, apartmentID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
+
+// bumpRoute marks an apartment's delivery order as changed, so reorders
+// made against an older copy of it are refused.
+func bumpRoute(tx *sql.Tx, apartmentID string) error {
+	_, err := tx.Exec(`UPDATE apartments SET route_version = route_version + 1 WHERE apartment_id::text = $1`, apartmentID)
+	if err != nil {
+		return fmt.Errorf("store: bump route version: %w", err)
+	}
+	return nil
+}
+
+// ---- products ----
+
+func (p *Postgres) ListProducts(includeArchived bool) ([]models.Product, error) {
+	return p.queryProducts("$1 OR archived_at IS NULL", includeArchived)
+}
+
+func (p *Postgres) GetProduct(productID string) (*models.Product, error) {
+	list, err := p.queryProducts("product_id::text = $1", productID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+func (p *Postgres) queryProducts(where string, args ...interface{}) ([]models.Product, error) {
+	rows, err := p.db.Query(`
+		SELECT product_id, product_name, unit, current_price, COALESCE(image_url, ''), COALESCE(acronym, ''), archived_at
+		  FROM products
+		 WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: list products: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.Product, 0)
+	for rows.Next() {
+		var pr models.Product
+		var archivedAt sql.NullString
+		if err := rows.Scan(&pr.ProductID, &pr.ProductName, &pr.Unit, &pr.CurrentPrice, &pr.ImageURL, &pr.Acronym, &archivedAt); err != nil {
+			return nil, fmt.Errorf("store: scan product: %w", err)
+		}
+		pr.Archived, pr.ArchivedAt = archivedAt.Valid, archivedAt.String
+		list = append(list, pr)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) CreateProduct(pr models.Product) (string, error) {
+	var id string
+	err := p.db.QueryRow(`
+		INSERT INTO products (product_id, product_name, unit, current_price, image_url, acronym)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5)
+		RETURNING product_id
+	`, pr.ProductName, pr.Unit, pr.CurrentPrice, pr.ImageURL, pr.Acronym).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("store: insert product: %w", err)
+	}
+	return id, nil
+}
+
+func (p *Postgres) UpdateProduct(pr models.Product) error {
+	res, err := p.db.Exec(`
+		UPDATE products
+		   SET product_name = $1, unit = $2, current_price = $3, image_url = $4, acronym = $5
+		 WHERE product_id::text = $6
+	`, pr.ProductName, pr.Unit, pr.CurrentPrice, pr.ImageURL, pr.Acronym, pr.ProductID)
+	if err != nil {
+		return fmt.Errorf("store: update product: %w", err)
+	}
+	return checkAffected(res)
+}
+
+func (p *Postgres) ArchiveProduct(productID string, today civil.Date) error {
+	return p.withTx(func(tx *sql.Tx) error {
+		var archived bool
+		err := tx.QueryRow(`SELECT archived_at IS NOT NULL FROM products WHERE product_id::text = $1 FOR UPDATE`, productID).Scan(&archived)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil || archived {
+			return err
+		}
+		if err := checkUnused(tx, "product_id", productID, today); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE products SET archived_at = NOW() WHERE product_id::text = $1", productID); err != nil {
+			return fmt.Errorf("store: archive product: %w", err)
+		}
+		return nil
+	})
+}
+
+func (p *Postgres) RestoreProduct(productID string) error {
+	res, err := p.db.Exec("UPDATE products SET archived_at = NULL WHERE product_id::text = $1 AND archived_at IS NOT NULL", productID)
+	if err != nil {
+		return fmt.Errorf("store: restore product: %w", err)
+	}
+	return p.checkRestored(res, "products", "product_id", productID)
+}
+
+// ---- prices ----
+
+func (p *Postgres) CurrentPrices() (map[string]float64, error) {
+	rows, err := p.db.Query(`SELECT product_id, current_price FROM products`)
+	if err != nil {
+		return nil, fmt.Errorf("store: load current prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := make(map[string]float64)
+	for rows.Next() {
+		var id string
+		var price float64
+		if err := rows.Scan(&id, &price); err != nil {
+			return nil, fmt.Errorf("store: scan current price: %w", err)
+		}
+		prices[id] = price
+	}
+	return prices, rows.Err()
+}
+
+func (p *Postgres) PriceChanges(productID string) ([]models.ProductPriceHistory, error) {
+	return p.queryPriceChanges("$1 = '' OR product_id::text = $1", productID)
+}
+
+func (p *Postgres) PriceChange(priceID string) (*models.ProductPriceHistory, error) {
+	list, err := p.queryPriceChanges("price_id::text = $1", priceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+func (p *Postgres) queryPriceChanges(where string, args ...interface{}) ([]models.ProductPriceHistory, error) {
+	rows, err := p.db.Query(`
+		SELECT price_id, product_id, old_price, new_price, effective_from, updated_at
+		  FROM product_price_history
+		 WHERE `+where+`
+		 ORDER BY product_id, effective_from ASC, updated_at ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: load price history: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.ProductPriceHistory, 0)
+	for rows.Next() {
+		var h models.ProductPriceHistory
+		if err := rows.Scan(&h.PriceID, &h.ProductID, &h.OldPrice, &h.NewPrice, &h.EffectiveFrom, &h.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("store: scan price history: %w", err)
+		}
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) AddPriceChange(h models.ProductPriceHistory) error {
+	return p.withTx(func(tx *sql.Tx) error {
+		if err := setNextOldPrice(tx, h.ProductID, h.EffectiveFrom, h.NewPrice); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT INTO product_price_history (price_id, product_id, old_price, new_price, effective_from, updated_at)
+			VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW())
+		`, h.ProductID, h.OldPrice, h.NewPrice, h.EffectiveFrom)
+		if err != nil {
+			return fmt.Errorf("store: insert price change: %w", err)
+		}
+		return nil
+	})
+}
+
+// setNextOldPrice sets the old price of the product's earliest change taking
+// effect after from.
+func setNextOldPrice(tx *sql.Tx, productID string, from civil.Date, oldPrice float64) error {
+	_, err := tx.Exec(`
+		UPDATE product_price_history SET old_price = $3
+		 WHERE price_id = (
+			SELECT price_id FROM product_price_history
+			 WHERE product_id::text = $1 AND effective_from > $2::date
+			 ORDER BY effective_from, updated_at
+			 LIMIT 1
+		 )
+	`, productID, from, oldPrice)
+	if err != nil {
+		return fmt.Errorf("store: update next price change: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) PriceListEntries(apartmentID, userID string) ([]PriceListEntry, error) {
+	return p.queryPriceList(`($1 = '' OR apartment_id::text = $1) AND ($2 = '' OR user_id::text = $2)`, apartmentID, userID)
+}
+
+func (p *Postgres) GetPriceListEntry(entryID string) (*PriceListEntry, error) {
+	list, err := p.queryPriceList(`entry_id::text = $1`, entryID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+func (p *Postgres) queryPriceList(where string, args ...interface{}) ([]PriceListEntry, error) {
+	rows, err := p.db.Query(`
+		SELECT entry_id, product_id, COALESCE(apartment_id::text, ''), COALESCE(user_id::text, ''), price,
+		       effective_from, effective_to, created_at
+		  FROM price_list_entries
+		 WHERE `+where+`
+		 ORDER BY effective_from, created_at
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: load price list: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]PriceListEntry, 0)
+	for rows.Next() {
+		var e PriceListEntry
+		err := rows.Scan(&e.EntryID, &e.ProductID, &e.ApartmentID, &e.UserID, &e.Price, &e.EffectiveFrom, &e.EffectiveTo, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("store: scan price list entry: %w", err)
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) CreatePriceListEntry(e PriceListEntry) (string, error) {
+	var id string
+	err := p.db.QueryRow(`
+		INSERT INTO price_list_entries (product_id, apartment_id, user_id, price, effective_from, effective_to)
+		VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, $4, $5, $6::date)
+		RETURNING entry_id
+	`, e.ProductID, e.ApartmentID, e.UserID, e.Price, e.EffectiveFrom, e.EffectiveTo).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("store: insert price list entry: %w", err)
+	}
+	return id, nil
+}
+
+func (p *Postgres) DeletePriceListEntry(entryID string) error {
+	res, err := p.db.Exec(`DELETE FROM price_list_entries WHERE entry_id::text = $1`, entryID)
+	if err != nil {
+		return fmt.Errorf("store: delete price list entry: %w", err)
+	}
+	return checkAffected(res)
+}
+
+func (p *Postgres) DeletePriceChange(priceID string) error {
+	return p.withTx(func(tx *sql.Tx) error {
+		var h models.ProductPriceHistory
+		err := tx.QueryRow(`
+			DELETE FROM product_price_history WHERE price_id::text = $1
+			RETURNING product_id, old_price, effective_from
+		`, priceID).Scan(&h.ProductID, &h.OldPrice, &h.EffectiveFrom)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("store: delete price change: %w", err)
+		}
+		return setNextOldPrice(tx, h.ProductID, h.EffectiveFrom, h.OldPrice)
+	})
+}
+
+// ---- apartments ----
+
+func (p *Postgres) ListApartments(adminID string, includeArchived bool) ([]models.Apartment, error) {
+	return p.queryApartments(`($1 = '' OR apartment_id IN (SELECT apartment_id FROM admin_apartments WHERE admin_id::text = $1))
+		AND ($2 OR archived_at IS NULL)`, adminID, includeArchived)
+}
+
+func (p *Postgres) GetApartment(apartmentID string) (*models.Apartment, error) {
+	list, err := p.queryApartments(`apartment_id::text = $1`, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+func (p *Postgres) queryApartments(where string, args ...interface{}) ([]models.Apartment, error) {
+	rows, err := p.db.Query(`SELECT apartment_id, apartment_name, created_at, archived_at, route_version FROM apartments WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: list apartments: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.Apartment, 0)
+	for rows.Next() {
+		var a models.Apartment
+		var archivedAt sql.NullString
+		if err := rows.Scan(&a.ApartmentID, &a.ApartmentName, &a.CreatedAt, &archivedAt, &a.RouteVersion); err != nil {
+			return nil, fmt.Errorf("store: scan apartment: %w", err)
+		}
+		a.Archived, a.ArchivedAt = archivedAt.Valid, archivedAt.String
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) CreateApartment(name string) (string, error) {
+	var id string
+	err := p.db.QueryRow("INSERT INTO apartments (apartment_id, apartment_name) VALUES (gen_random_uuid(), $1) RETURNING apartment_id", name).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("store: insert apartment: %w", err)
+	}
+	return id, nil
+}
+
+func (p *Postgres) ArchiveApartment(apartmentID string) error {
+	return p.withTx(func(tx *sql.Tx) error {
+		var archived bool
+		err := tx.QueryRow(`SELECT archived_at IS NOT NULL FROM apartments WHERE apartment_id::text = $1 FOR UPDATE`, apartmentID).Scan(&archived)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil || archived {
+			return err
+		}
+		var used bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE apartment_id::text = $1 AND archived_at IS NULL)`, apartmentID).Scan(&used)
+		if err != nil {
+			return fmt.Errorf("store: check apartment customers: %w", err)
+		}
+		if used {
+			return ErrInUse
+		}
+		if _, err := tx.Exec("UPDATE apartments SET archived_at = NOW() WHERE apartment_id::text = $1", apartmentID); err != nil {
+			return fmt.Errorf("store: archive apartment: %w", err)
+		}
+		return nil
+	})
+}
+
+func (p *Postgres) RestoreApartment(apartmentID string) error {
+	res, err := p.db.Exec("UPDATE apartments SET archived_at = NULL WHERE apartment_id::text = $1 AND archived_at IS NOT NULL", apartmentID)
+	if err != nil {
+		return fmt.Errorf("store: restore apartment: %w", err)
+	}
+	return p.checkRestored(res, "apartments", "apartment_id", apartmentID)
+}
+
+// ---- orders ----
+
+func (p *Postgres) Defaults(userIDs []string) ([]DefaultItem, error) {
+	rows, err := p.db.Query(`
+		SELECT user_id, product_id, quantity, COALESCE(recurrence, ''), effective_from, effective_to
+		  FROM default_order_items
+		 WHERE user_id::text = ANY($1)
+		 ORDER BY product_id
+	`, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("store: load defaults: %w", err)
+	}
+	defer rows.Close()
+
+	var items []DefaultItem
+	for rows.Next() {
+		var it DefaultItem
+		if err := rows.Scan(&it.UserID, &it.ProductID, &it.Quantity, &it.Recurrence, &it.EffectiveFrom, &it.EffectiveTo); err != nil {
+			return nil, fmt.Errorf("store: scan default: %w", err)
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+func (p *Postgres) ReplaceDefaults(userID string, mode string, anchor, from civil.Date, items []DefaultItem) error {
+	return p.withTx(func(tx *sql.Tx) error {
+		// Drop the items taking effect on or after from and end the others
+		// the day before; a NULL from drops them all
+		_, err := tx.Exec(`
+			DELETE FROM default_order_items
+			 WHERE user_id::text = $1 AND ($2::date IS NULL OR effective_from >= $2::date)
+		`, userID, from)
+		if err != nil {
+			return fmt.Errorf("store: clear defaults: %w", err)
+		}
+		_, err = tx.Exec(`
+			UPDATE default_order_items
+			   SET effective_to = $2::date - 1
+			 WHERE user_id::text = $1 AND (effective_to IS NULL OR effective_to >= $2::date)
+		`, userID, from)
+		if err != nil {
+			return fmt.Errorf("store: end defaults: %w", err)
+		}
+		for _, it := range items {
+			_, err := tx.Exec(
+				"INSERT INTO default_order_items (user_id, product_id, quantity, recurrence, effective_from) VALUES ($1, $2, $3, NULLIF($4, ''), $5)",
+				userID, it.ProductID, it.Quantity, it.Recurrence, from,
+			)
+			if err != nil {
+				return fmt.Errorf("store: insert default: %w", err)
+			}
+		}
+		res, err := tx.Exec(`
+			UPDATE users
+			   SET order_mode = $1,
+			       is_alternating_order = ($1 = 'alternating'),
+			       alternating_anchor = CASE WHEN $1 = 'alternating' THEN COALESCE($3::date, alternating_anchor) END
+			 WHERE user_id::text = $2
+		`, mode, userID, anchor)
+		if err != nil {
+			return fmt.Errorf("store: update order type: %w", err)
+		}
+		return checkAffected(res)
+	})
+}
+
+func (p *Postgres) Modifications(userIDs []string, start, end civil.Date) ([]Modification, error) {
+	rows, err := p.db.Query(`
+		SELECT modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date, '' AS day_type, false AS alt,
+		       modification_type, created_at
+		  FROM order_modifications
+		 WHERE user_id::text = ANY($1) AND start_date <= $3 AND end_date >= $2
+		UNION ALL
+		SELECT modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date, day_type, true AS alt,
+		       'replace', created_at
+		  FROM alternating_order_modifications
+		 WHERE user_id::text = ANY($1) AND start_date <= $3 AND end_date >= $2
+		UNION ALL
+		SELECT modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date, day_type, alternating,
+		       modification_type, created_at
+		  FROM modification_archive
+		 WHERE user_id::text = ANY($1) AND start_date <= $3 AND end_date >= $2
+		 ORDER BY product_id
+	`, pq.Array(userIDs), start, end)
+	if err != nil {
+		return nil, fmt.Errorf("store: load modifications: %w", err)
+	}
+	return scanModifications(rows)
+}
+
+// modificationRows selects the rows of both modification tables matching
+// where, which may refer to order_id and user_id.
+func (p *Postgres) modificationRows(where string, args ...interface{}) ([]Modification, error) {
+	rows, err := p.db.Query(`
+		SELECT * FROM (
+			SELECT modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date, '' AS day_type, false AS alt,
+			       modification_type, created_at
+			  FROM order_modifications
+			UNION ALL
+			SELECT modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date, day_type, true AS alt,
+			       'replace', created_at
+			  FROM alternating_order_modifications
+		) m
+		 WHERE `+where+`
+		 ORDER BY created_at DESC, order_id, product_id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: load modifications: %w", err)
+	}
+	return scanModifications(rows)
+}
+
+func (p *Postgres) CustomerModifications(userID string) ([]Modification, error) {
+	return p.modificationRows("user_id::text = $1", userID)
+}
+
+func (p *Postgres) ModificationBatch(orderID string) ([]Modification, error) {
+	mods, err := p.modificationRows("order_id::text = $1", orderID)
+	if err == nil && len(mods) == 0 {
+		return nil, ErrNotFound
+	}
+	return mods, err
+}
+
+func scanModifications(rows *sql.Rows) ([]Modification, error) {
+	defer rows.Close()
+
+	var mods []Modification
+	for rows.Next() {
+		var m Modification
+		err := rows.Scan(&m.ModificationID, &m.OrderID, &m.UserID, &m.ProductID, &m.Quantity,
+			&m.StartDate, &m.EndDate, &m.DayType, &m.Alternating, &m.Kind, &m.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("store: scan modification: %w", err)
+		}
+		mods = append(mods, m)
+	}
+	return mods, rows.Err()
+}
+
+func (p *Postgres) AddModifications(mods []Modification) (string, error) {
+	ids, err := p.AddModificationBatches([][]Modification{mods})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+func (p *Postgres) AddModificationBatches(batches [][]Modification) ([]string, error) {
+	var ids []string
+	err := p.withTx(func(tx *sql.Tx) error {
+		for _, mods := range batches {
+			orderID, err := insertBatch(tx, mods)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, orderID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// insertBatch writes mods under a new order id.
+func insertBatch(tx *sql.Tx, mods []Modification) (string, error) {
+	var orderID string
+	if err := tx.QueryRow("SELECT gen_random_uuid()").Scan(&orderID); err != nil {
+		return "", fmt.Errorf("store: generate order id: %w", err)
+	}
+	for _, m := range mods {
+		var err error
+		if m.Alternating {
+			_, err = tx.Exec(`
+				INSERT INTO alternating_order_modifications (
+					modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date, day_type, created_at
+				) VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, NOW())
+			`, orderID, m.UserID, m.ProductID, m.Quantity, m.StartDate, m.EndDate, m.DayType)
+		} else {
+			_, err = tx.Exec(`
+				INSERT INTO order_modifications (
+					modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date, modification_type, created_at
+				) VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, COALESCE(NULLIF($7, ''), 'replace'), NOW())
+			`, orderID, m.UserID, m.ProductID, m.Quantity, m.StartDate, m.EndDate, m.Kind)
+		}
+		if err != nil {
+			return "", fmt.Errorf("store: insert modification: %w", err)
+		}
+	}
+	return orderID, nil
+}
+
+func (p *Postgres) DeleteModificationBatches(orderIDs ...string) error {
+	return p.withTx(func(tx *sql.Tx) error {
+		var n int64
+		for _, table := range []string{"order_modifications", "alternating_order_modifications"} {
+			res, err := tx.Exec(`DELETE FROM `+table+` WHERE order_id::text = ANY($1)`, pq.Array(orderIDs))
+			if err != nil {
+				return fmt.Errorf("store: delete modification batch: %w", err)
+			}
+			affected, _ := res.RowsAffected()
+			n += affected
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// billedMonths holds for a row m whose every month has a finalized invoice.
+const billedMonths = `NOT EXISTS (
+	SELECT 1 FROM generate_series(date_trunc('month', m.start_date), date_trunc('month', m.end_date), INTERVAL '1 month') AS g(month)
+	 WHERE NOT EXISTS (
+		SELECT 1 FROM invoices i
+		 WHERE i.user_id = m.user_id AND i.status = 'finalized'
+		   AND make_date(i.year, i.month, 1) = g.month::date
+	 )
+)`
+
+func (p *Postgres) ArchiveExpiredModifications(before civil.Date) (int64, error) {
+	var n int64
+	err := p.withTx(func(tx *sql.Tx) error {
+		for _, q := range []string{`
+			WITH moved AS (
+				DELETE FROM order_modifications m WHERE m.end_date < $1 AND ` + billedMonths + `
+				RETURNING modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date,
+				          modification_type, created_at
+			)
+			INSERT INTO modification_archive (
+				modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date,
+				alternating, day_type, modification_type, created_at
+			)
+			SELECT modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date,
+			       false, '', modification_type, created_at
+			  FROM moved
+		`, `
+			WITH moved AS (
+				DELETE FROM alternating_order_modifications m WHERE m.end_date < $1 AND ` + billedMonths + `
+				RETURNING modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date,
+				          day_type, created_at
+			)
+			INSERT INTO modification_archive (
+				modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date,
+				alternating, day_type, modification_type, created_at
+			)
+			SELECT modification_id, order_id, user_id, product_id, modified_quantity, start_date, end_date,
+			       true, day_type, 'replace', created_at
+			  FROM moved
+		`} {
+			res, err := tx.Exec(q, before)
+			if err != nil {
+				return fmt.Errorf("store: archive expired modifications: %w", err)
+			}
+			affected, _ := res.RowsAffected()
+			n += affected
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (p *Postgres) RecordCutoffOverride(o CutoffOverride) error {
+	_, err := p.db.Exec(`
+		INSERT INTO cutoff_overrides (admin_id, user_id, start_date, end_date, action, reason, forced)
+		VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, $6, $7)
+	`, o.AdminID, o.UserID, o.StartDate, o.EndDate, o.Action, o.Reason, o.Forced)
+	if err != nil {
+		return fmt.Errorf("store: record cut-off override: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) CutoffOverrides(from, to time.Time) ([]CutoffOverride, error) {
+	rows, err := p.db.Query(`
+		SELECT override_id, COALESCE(admin_id::text, ''), user_id, start_date, end_date, action, reason, forced, created_at
+		  FROM cutoff_overrides
+		 WHERE created_at >= $1 AND created_at < $2
+		 ORDER BY created_at DESC
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("store: load cut-off overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var list []CutoffOverride
+	for rows.Next() {
+		var o CutoffOverride
+		err := rows.Scan(&o.OverrideID, &o.AdminID, &o.UserID, &o.StartDate, &o.EndDate, &o.Action, &o.Reason, &o.Forced, &o.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("store: scan cut-off override: %w", err)
+		}
+		list = append(list, o)
+	}
+	return list, rows.Err()
+}
+
+// ---- vacations ----
+
+func (p *Postgres) Vacations(userIDs []string, start, end civil.Date) ([]Vacation, error) {
+	return p.queryVacations(`user_id::text = ANY($1) AND start_date <= $3 AND end_date >= $2`,
+		pq.Array(userIDs), start, end)
+}
+
+func (p *Postgres) CustomerVacations(userID string) ([]Vacation, error) {
+	return p.queryVacations(`user_id::text = $1`, userID)
+}
+
+func (p *Postgres) GetVacation(vacationID string) (*Vacation, error) {
+	list, err := p.queryVacations(`vacation_id::text = $1`, vacationID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+func (p *Postgres) queryVacations(where string, args ...interface{}) ([]Vacation, error) {
+	rows, err := p.db.Query(`
+		SELECT vacation_id, user_id, start_date, end_date, reason, product_ids::text[], created_at
+		  FROM vacations
+		 WHERE `+where+`
+		 ORDER BY start_date DESC, created_at DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: load vacations: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Vacation
+	for rows.Next() {
+		var v Vacation
+		err := rows.Scan(&v.VacationID, &v.UserID, &v.StartDate, &v.EndDate, &v.Reason, pq.Array(&v.ProductIDs), &v.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("store: scan vacation: %w", err)
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) CreateVacation(v Vacation) (string, error) {
+	var id string
+	err := p.db.QueryRow(`
+		INSERT INTO vacations (user_id, start_date, end_date, reason, product_ids)
+		VALUES ($1, $2, $3, $4, $5::uuid[])
+		RETURNING vacation_id
+	`, v.UserID, v.StartDate, v.EndDate, v.Reason, pq.Array(productIDs(v))).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("store: insert vacation: %w", err)
+	}
+	return id, nil
+}
+
+func (p *Postgres) UpdateVacation(v Vacation) error {
+	res, err := p.db.Exec(`
+		UPDATE vacations SET start_date = $2, end_date = $3, reason = $4, product_ids = $5::uuid[]
+		 WHERE vacation_id::text = $1
+	`, v.VacationID, v.StartDate, v.EndDate, v.Reason, pq.Array(productIDs(v)))
+	if err != nil {
+		return fmt.Errorf("store: update vacation: %w", err)
+	}
+	return checkAffected(res)
+}
+
+func (p *Postgres) DeleteVacation(vacationID string) error {
+	res, err := p.db.Exec(`DELETE FROM vacations WHERE vacation_id::text = $1`, vacationID)
+	if err != nil {
+		return fmt.Errorf("store: delete vacation: %w", err)
+	}
+	return checkAffected(res)
+}
+
+// productIDs returns the paused products of v, never nil, so an empty list
+// is stored as '{}' rather than NULL.
+func productIDs(v Vacation) []string {
+	if v.ProductIDs == nil {
+		return []string{}
+	}
+	return v.ProductIDs
+}
+
+// checkAffected turns an update that matched nothing into ErrNotFound.
+func checkAffected(res sql.Result) error {
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ---- invoices ----
+
+func (p *Postgres) SaveDraft(inv models.Invoice) (string, error) {
+	err := p.withTx(func(tx *sql.Tx) error {
+		var status string
+		err := tx.QueryRow(`
+			SELECT invoice_id, status FROM invoices
+			 WHERE user_id = $1 AND year = $2 AND month = $3
+			 FOR UPDATE
+		`, inv.UserID, inv.Year, inv.Month).Scan(&inv.InvoiceID, &status)
+		switch {
+		case err == sql.ErrNoRows:
+			err = tx.QueryRow(`
+				INSERT INTO invoices (user_id, year, month, status, total_amount)
+				VALUES ($1, $2, $3, 'draft', $4)
+				RETURNING invoice_id
+			`, inv.UserID, inv.Year, inv.Month, inv.TotalAmount).Scan(&inv.InvoiceID)
+			if err != nil {
+				return fmt.Errorf("store: insert invoice: %w", err)
+			}
+		case err != nil:
+			return fmt.Errorf("store: find invoice: %w", err)
+		case status != "draft":
+			return ErrWrongStatus
+		default:
+			_, err = tx.Exec(`
+				UPDATE invoices SET total_amount = $1, generated_at = NOW() WHERE invoice_id = $2
+			`, inv.TotalAmount, inv.InvoiceID)
+			if err != nil {
+				return fmt.Errorf("store: update invoice: %w", err)
+			}
+			if _, err = tx.Exec(`DELETE FROM invoice_lines WHERE invoice_id = $1`, inv.InvoiceID); err != nil {
+				return fmt.Errorf("store: clear invoice lines: %w", err)
+			}
+		}
+
+		stmt, err := tx.Prepare(`
+			INSERT INTO invoice_lines (invoice_id, delivery_date, product_id, quantity, price_per_unit, total_price)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, line := range inv.Lines {
+			_, err := stmt.Exec(inv.InvoiceID, line.DeliveryDate, line.ProductID, line.Quantity, line.PricePerUnit, line.TotalPrice)
+			if err != nil {
+				return fmt.Errorf("store: insert invoice line: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return inv.InvoiceID, nil
+}
+
+func (p *Postgres) GetInvoice(invoiceID string) (*models.Invoice, error) {
+	list, err := p.queryInvoices(`i.invoice_id::text = $1`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	inv := &list[0]
+
+	rows, err := p.db.Query(`
+		SELECT line_id, delivery_date, product_id, quantity, price_per_unit, total_price
+		  FROM invoice_lines
+		 WHERE invoice_id = $1
+		 ORDER BY delivery_date, product_id
+	`, inv.InvoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("store: load invoice lines: %w", err)
+	}
+	defer rows.Close()
+
+	inv.Lines = make([]models.InvoiceLine, 0)
+	for rows.Next() {
+		var line models.InvoiceLine
+		if err := rows.Scan(&line.LineID, &line.DeliveryDate, &line.ProductID, &line.Quantity, &line.PricePerUnit, &line.TotalPrice); err != nil {
+			return nil, fmt.Errorf("store: scan invoice line: %w", err)
+		}
+		inv.Lines = append(inv.Lines, line)
+	}
+	return inv, rows.Err()
+}
+
+func (p *Postgres) ListInvoices(userID string, year, month int) ([]models.Invoice, error) {
+	return p.queryInvoices(`($1 = '' OR i.user_id::text = $1) AND ($2 = 0 OR i.year = $2) AND ($3 = 0 OR i.month = $3)`,
+		userID, year, month)
+}
+
+func (p *Postgres) queryInvoices(where string, args ...interface{}) ([]models.Invoice, error) {
+	rows, err := p.db.Query(`
+		SELECT i.invoice_id, i.user_id, i.year, i.month, i.status, i.total_amount, i.generated_at,
+		       COALESCE(i.finalized_at::text, ''), COALESCE(i.finalized_by::text, '')
+		  FROM invoices i
+		 WHERE `+where+`
+		 ORDER BY i.year DESC, i.month DESC, i.user_id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: list invoices: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.Invoice, 0)
+	for rows.Next() {
+		var inv models.Invoice
+		err := rows.Scan(&inv.InvoiceID, &inv.UserID, &inv.Year, &inv.Month, &inv.Status, &inv.TotalAmount,
+			&inv.GeneratedAt, &inv.FinalizedAt, &inv.FinalizedBy)
+		if err != nil {
+			return nil, fmt.Errorf("store: scan invoice: %w", err)
+		}
+		list = append(list, inv)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) FinalizeInvoice(invoiceID, adminID string) error {
+	res, err := p.db.Exec(`
+		UPDATE invoices SET status = 'finalized', finalized_at = NOW(), finalized_by = NULLIF($2, '')::uuid
+		 WHERE invoice_id::text = $1 AND status = 'draft'
+	`, invoiceID, adminID)
+	if err != nil {
+		return fmt.Errorf("store: finalize invoice: %w", err)
+	}
+	return p.checkTransition(res, invoiceID)
+}
+
+func (p *Postgres) ReopenInvoice(invoiceID string) error {
+	res, err := p.db.Exec(`
+		UPDATE invoices SET status = 'draft', finalized_at = NULL, finalized_by = NULL
+		 WHERE invoice_id::text = $1 AND status = 'finalized'
+	`, invoiceID)
+	if err != nil {
+		return fmt.Errorf("store: reopen invoice: %w", err)
+	}
+	return p.checkTransition(res, invoiceID)
+}
+
+// checkTransition tells a missing invoice from one in the wrong status.
+func (p *Postgres) checkTransition(res sql.Result, invoiceID string) error {
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	var exists bool
+	err := p.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM invoices WHERE invoice_id::text = $1)`, invoiceID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrWrongStatus
+}
+
+func (p *Postgres) InvoiceLocked(userID string, start, end civil.Date) (bool, error) {
+	var locked bool
+	err := p.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM invoices
+			 WHERE user_id::text = $1 AND status = 'finalized'
+			   AND ($3::date IS NULL OR make_date(year, month, 1) <= $3::date)
+			   AND (make_date(year, month, 1) + INTERVAL '1 month' - INTERVAL '1 day')::date >= $2::date
+		)
+	`, userID, start, end).Scan(&locked)
+	if err != nil {
+		return false, fmt.Errorf("store: check invoice lock: %w", err)
+	}
+	return locked, nil
+}
+
+func (p *Postgres) PriceLocked(productID, userID string, start, end civil.Date) (bool, error) {
+	var locked bool
+	err := p.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM invoice_lines l
+			  JOIN invoices i ON i.invoice_id = l.invoice_id
+			 WHERE i.status = 'finalized' AND l.product_id::text = $1
+			   AND ($2 = '' OR i.user_id::text = $2)
+			   AND l.delivery_date >= $3::date AND ($4::date IS NULL OR l.delivery_date <= $4::date)
+		)
+	`, productID, userID, start, end).Scan(&locked)
+	if err != nil {
+		return false, fmt.Errorf("store: check price lock: %w", err)
+	}
+	return locked, nil
+}
+
+// ---- payments ----
+
+func (p *Postgres) CreatePayment(pm models.Payment) (string, error) {
+	var id string
+	err := p.db.QueryRow(`
+		INSERT INTO payments (user_id, invoice_id, amount, payment_date, mode, reference, recorded_by)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, NULLIF($7, '')::uuid)
+		RETURNING payment_id
+	`, pm.UserID, pm.InvoiceID, pm.Amount, pm.PaymentDate, pm.Mode, pm.Reference, pm.RecordedBy).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("store: insert payment: %w", err)
+	}
+	return id, nil
+}
+
+func (p *Postgres) GetPayment(paymentID string) (*models.Payment, error) {
+	list, err := p.queryPayments(`p.payment_id::text = $1`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+func (p *Postgres) ListPayments(userID string, from, to civil.Date) ([]models.Payment, error) {
+	return p.queryPayments(`($1 = '' OR p.user_id::text = $1)
+		AND ($2::date IS NULL OR p.payment_date >= $2::date) AND ($3::date IS NULL OR p.payment_date <= $3::date)`,
+		userID, from, to)
+}
+
+func (p *Postgres) DeletePayment(paymentID string) error {
+	res, err := p.db.Exec(`DELETE FROM payments WHERE payment_id::text = $1`, paymentID)
+	if err != nil {
+		return fmt.Errorf("store: delete payment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) queryPayments(where string, args ...interface{}) ([]models.Payment, error) {
+	rows, err := p.db.Query(`
+		SELECT p.payment_id, p.user_id, COALESCE(p.invoice_id::text, ''), p.amount, p.payment_date,
+		       p.mode, p.reference, COALESCE(p.recorded_by::text, ''), p.created_at
+		  FROM payments p
+		 WHERE `+where+`
+		 ORDER BY p.payment_date, p.created_at
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: list payments: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.Payment, 0)
+	for rows.Next() {
+		var pm models.Payment
+		err := rows.Scan(&pm.PaymentID, &pm.UserID, &pm.InvoiceID, &pm.Amount, &pm.PaymentDate,
+			&pm.Mode, &pm.Reference, &pm.RecordedBy, &pm.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("store: scan payment: %w", err)
+		}
+		list = append(list, pm)
+	}
+	return list, rows.Err()
+}
+
+// ---- admins ----
+
+func (p *Postgres) AdminRole(adminID string) (string, bool, error) {
+	var role string
+	var active bool
+	err := p.db.QueryRow(`SELECT role, is_active FROM admin WHERE admin_id::text = $1`, adminID).Scan(&role, &active)
+	if err == sql.ErrNoRows {
+		return "", false, ErrNotFound
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("store: load admin: %w", err)
+	}
+	return role, active, nil
+}
+
+func (p *Postgres) AdminCanAccess(adminID, apartmentID string) (bool, error) {
+	var ok bool
+	err := p.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM admin_apartments WHERE admin_id::text = $1 AND apartment_id::text = $2)
+	`, adminID, apartmentID).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("store: check apartment access: %w", err)
+	}
+	return ok, nil
+}
+
+// samePeople reports whether a and b hold the same IDs, each exactly once.
+func samePeople(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
+	}
+	return true
+}
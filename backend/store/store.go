@@ -0,0 +1,351 @@
+// Package store hides the database behind one interface per kind of data,
+// so handlers and the order, pricing and billing packages can run against
+// Postgres in production and against memory in tests.
+package store
+
+import (
+	"backend/civil"
+	"backend/models"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist.
+	ErrNotFound = errors.New("store: not found")
+	// ErrInUse is returned when archiving a row that current or future
+	// orders still depend on.
+	ErrInUse = errors.New("store: still in use")
+	// ErrStale is returned when a change was made against an older version
+	// of the data than the one stored.
+	ErrStale = errors.New("store: stale version")
+	// ErrWrongStatus is returned when an invoice is not in the status the
+	// change needs, such as finalizing one that is already finalized.
+	ErrWrongStatus = errors.New("store: wrong status")
+)
+
+// Stores bundles every store the application needs.
+type Stores struct {
+	Customers  CustomerStore
+	Products   ProductStore
+	Orders     OrderStore
+	Prices     PriceStore
+	Apartments ApartmentStore
+	Vacations  VacationStore
+	Invoices   InvoiceStore
+	Payments   PaymentStore
+	Admins     AdminStore
+}
+
+// CustomerMove records a customer moving to another apartment. The customer
+// belongs to FromApartmentID before MovedOn and to ToApartmentID from then on.
+type CustomerMove struct {
+	MoveID          string
+	UserID          string
+	FromApartmentID string
+	ToApartmentID   string
+	FromPriority    int
+	ToPriority      int
+	MovedOn         civil.Date
+	AdminID         string
+	CreatedAt       time.Time
+}
+
+// CustomerFilter selects customers. The zero value matches everyone.
+type CustomerFilter struct {
+	UserIDs     []string // nil matches any customer, an empty slice none
+	ApartmentID string
+	// NewestFirst sorts by creation time instead of delivery order.
+	NewestFirst bool
+	// IncludeArchived also matches archived customers.
+	IncludeArchived bool
+}
+
+// CustomerStore manages customers and their delivery order (priority_order)
+// within an apartment.
+type CustomerStore interface {
+	ListCustomers(f CustomerFilter) ([]models.User, error)
+	GetCustomer(userID string) (*models.User, error)
+	// CreateCustomer inserts at c.PriorityOrder, shifting the customers
+	// after it, or appends when the position is out of range.
+	CreateCustomer(c models.User) (string, error)
+	// CreateCustomers inserts many customers without touching priorities.
+	CreateCustomers(cs []models.User) error
+	// UpdateCustomer saves c and moves it to c.PriorityOrder within its
+	// apartment, shifting the customers in between. The apartment itself is
+	// left alone; MoveCustomer changes it.
+	UpdateCustomer(userID string, c models.User) error
+	// MoveCustomer moves mv.UserID to position mv.ToPriority of apartment
+	// mv.ToApartmentID, closing the gap it leaves behind; an out-of-range
+	// position appends. The move is recorded and returned with its From
+	// fields set.
+	MoveCustomer(mv CustomerMove) (CustomerMove, error)
+	// CustomerMoves lists the moves of the given customers, oldest first.
+	CustomerMoves(userIDs []string) ([]CustomerMove, error)
+	// ArchiveCustomer takes the customer out of the delivery order, closing
+	// the gap it leaves. It returns ErrInUse while the customer has a default
+	// order or a modification ending on or after today.
+	ArchiveCustomer(userID string, today civil.Date) error
+	// RestoreCustomer brings an archived customer back at the end of its
+	// apartment's delivery order.
+	RestoreCustomer(userID string) error
+	// ReorderCustomers numbers the apartment's active customers 1..N in the
+	// order of userIDs and returns the new route version. It returns
+	// ErrStale when version is no longer the apartment's route version or
+	// userIDs are not exactly its active customers.
+	ReorderCustomers(apartmentID string, userIDs []string, version int) (int, error)
+}
+
+// ProductStore manages the product catalogue.
+type ProductStore interface {
+	// ListProducts lists the active products, and the archived ones too
+	// when includeArchived is set.
+	ListProducts(includeArchived bool) ([]models.Product, error)
+	GetProduct(productID string) (*models.Product, error)
+	CreateProduct(p models.Product) (string, error)
+	UpdateProduct(p models.Product) error
+	// ArchiveProduct retires a product. It returns ErrInUse while a default
+	// order or a modification ending on or after today still has it.
+	ArchiveProduct(productID string, today civil.Date) error
+	RestoreProduct(productID string) error
+}
+
+// PriceStore holds current prices and the history of price changes.
+type PriceStore interface {
+	// CurrentPrices returns each product's stored price, which only applies
+	// while the product has no price change (see package pricing).
+	CurrentPrices() (map[string]float64, error)
+	// PriceChanges lists one product's changes, or every product's when
+	// productID is empty, ordered by product and effective date.
+	PriceChanges(productID string) ([]models.ProductPriceHistory, error)
+	// PriceChange returns one change, or ErrNotFound.
+	PriceChange(priceID string) (*models.ProductPriceHistory, error)
+	// AddPriceChange records a change; the next later change of the product
+	// then starts from its new price.
+	AddPriceChange(h models.ProductPriceHistory) error
+	// DeletePriceChange cancels a change and hands its old price on to the
+	// next later change of the product, or returns ErrNotFound.
+	DeletePriceChange(priceID string) error
+
+	// PriceListEntries lists the entries of one apartment and/or customer;
+	// empty arguments match any. Entries come ordered by effective date.
+	PriceListEntries(apartmentID, userID string) ([]PriceListEntry, error)
+	// GetPriceListEntry returns one entry, or ErrNotFound.
+	GetPriceListEntry(entryID string) (*PriceListEntry, error)
+	CreatePriceListEntry(e PriceListEntry) (string, error)
+	// DeletePriceListEntry removes an entry, or returns ErrNotFound.
+	DeletePriceListEntry(entryID string) error
+}
+
+// PriceListEntry overrides a product's base price for one apartment or one
+// customer (exactly one of ApartmentID and UserID is set) from EffectiveFrom
+// through EffectiveTo inclusive. A zero EffectiveTo leaves it open-ended.
+type PriceListEntry struct {
+	EntryID       string
+	ProductID     string
+	ApartmentID   string
+	UserID        string
+	Price         float64
+	EffectiveFrom civil.Date
+	EffectiveTo   civil.Date
+	CreatedAt     time.Time
+}
+
+// ApartmentStore manages apartments.
+type ApartmentStore interface {
+	// ListApartments lists every active apartment, or only those assigned
+	// to adminID when it is not empty, and the archived ones too when
+	// includeArchived is set.
+	ListApartments(adminID string, includeArchived bool) ([]models.Apartment, error)
+	GetApartment(apartmentID string) (*models.Apartment, error)
+	CreateApartment(name string) (string, error)
+	// ArchiveApartment retires an apartment. It returns ErrInUse while the
+	// apartment has active customers.
+	ArchiveApartment(apartmentID string) error
+	RestoreApartment(apartmentID string) error
+}
+
+// Default order modes. Alternating items are delivered on ODD or EVEN days,
+// weekly items on one weekday (MON..SUN). Every item is stored as a
+// recurrence rule; the mode only tells how the order is edited.
+const (
+	ModeNormal      = "normal"
+	ModeAlternating = "alternating"
+	ModeWeekly      = "weekly"
+)
+
+// Weekdays are the day types of weekly default items, Monday first.
+var Weekdays = []string{"MON", "TUE", "WED", "THU", "FRI", "SAT", "SUN"}
+
+// ValidMode reports whether mode is a default order mode.
+func ValidMode(mode string) bool {
+	return mode == ModeNormal || mode == ModeAlternating || mode == ModeWeekly
+}
+
+// DefaultItem is one line of a customer's default order. It is due on the
+// days its Recurrence rule allows, or every day without one. Alternating
+// items are every second day from the customer's anchor, weekly items every
+// week on their weekday. An item is only in force over [EffectiveFrom,
+// EffectiveTo], so changing the default order leaves earlier days as they
+// were.
+type DefaultItem struct {
+	UserID        string
+	ProductID     string
+	Quantity      float64
+	Recurrence    string     // RRULE subset, see package recurrence
+	EffectiveFrom civil.Date // zero: since the customer's first day
+	EffectiveTo   civil.Date // zero: until changed
+}
+
+// Modification types. A replace batch stands in for the whole day, an extra
+// batch adds its quantities on top of what the day resolves to and an
+// override batch sets the quantity of its products only.
+const (
+	KindReplace  = "replace"
+	KindExtra    = "extra"
+	KindOverride = "override"
+)
+
+// Modification is one row of order_modifications or, when Alternating,
+// alternating_order_modifications. Rows written together share an OrderID.
+// Alternating rows are always replacements.
+type Modification struct {
+	ModificationID string
+	OrderID        string
+	UserID         string
+	ProductID      string
+	Quantity       float64
+	StartDate      civil.Date
+	EndDate        civil.Date
+	Alternating    bool
+	DayType        string
+	Kind           string // empty means KindReplace
+	CreatedAt      time.Time
+}
+
+// OrderStore holds default orders and dated modifications.
+type OrderStore interface {
+	// Defaults returns the default items of the customers, earlier ones
+	// no longer in force included.
+	Defaults(userIDs []string) ([]DefaultItem, error)
+	// ReplaceDefaults swaps a customer's default order for items as of from:
+	// items in force before it end the day before, later ones are dropped. A
+	// zero from replaces the whole history. It records the order's mode, and
+	// the anchor an alternating customer's ODD/EVEN days count from; a zero
+	// anchor keeps the stored one. Leaving alternating mode clears the anchor.
+	ReplaceDefaults(userID string, mode string, anchor, from civil.Date, items []DefaultItem) error
+	// Modifications returns every row of the customers overlapping [start, end],
+	// archived rows included.
+	Modifications(userIDs []string, start, end civil.Date) ([]Modification, error)
+	// CustomerModifications returns every row of one customer, newest batch
+	// first.
+	CustomerModifications(userID string) ([]Modification, error)
+	// ModificationBatch returns the rows sharing orderID, or ErrNotFound.
+	ModificationBatch(orderID string) ([]Modification, error)
+	// AddModifications stores mods as one batch under a new order id.
+	AddModifications(mods []Modification) (string, error)
+	// AddModificationBatches stores each element of batches as its own
+	// batch, all of them or none, and returns their order ids. The batches
+	// share one creation time.
+	AddModificationBatches(batches [][]Modification) ([]string, error)
+	// DeleteModificationBatches removes every row of the batches at once, or
+	// returns ErrNotFound when there is none.
+	DeleteModificationBatches(orderIDs ...string) error
+	// ArchiveExpiredModifications moves rows of both kinds that ended before
+	// the date into the archive. A row is kept while any month it touches has
+	// no finalized invoice for its customer, since that bill may still change.
+	ArchiveExpiredModifications(before civil.Date) (int64, error)
+	// RecordCutoffOverride stores a change made after the order cut-off.
+	RecordCutoffOverride(o CutoffOverride) error
+	// CutoffOverrides lists the overrides recorded in [from, to), newest first.
+	CutoffOverrides(from, to time.Time) ([]CutoffOverride, error)
+}
+
+// CutoffOverride records a change to deliveries after the order cut-off.
+// Forced ones were pushed through by an admin with a reason; the others were
+// let through and flagged.
+type CutoffOverride struct {
+	OverrideID string
+	AdminID    string
+	UserID     string
+	StartDate  civil.Date
+	EndDate    civil.Date
+	Action     string // the request, e.g. "POST /orders/modify"
+	Reason     string
+	Forced     bool
+	CreatedAt  time.Time
+}
+
+// Vacation pauses a customer's deliveries from StartDate to EndDate
+// inclusive: every product, or only ProductIDs when it is not empty.
+type Vacation struct {
+	VacationID string
+	UserID     string
+	StartDate  civil.Date
+	EndDate    civil.Date
+	Reason     string
+	ProductIDs []string
+	CreatedAt  time.Time
+}
+
+// VacationStore holds customer vacations.
+type VacationStore interface {
+	// Vacations returns the vacations of the customers overlapping [start, end].
+	Vacations(userIDs []string, start, end civil.Date) ([]Vacation, error)
+	// CustomerVacations returns every vacation of one customer, latest start first.
+	CustomerVacations(userID string) ([]Vacation, error)
+	GetVacation(vacationID string) (*Vacation, error)
+	CreateVacation(v Vacation) (string, error)
+	// UpdateVacation saves the dates, reason and products of v.
+	UpdateVacation(v Vacation) error
+	DeleteVacation(vacationID string) error
+}
+
+// InvoiceStore keeps stored monthly invoices and answers whether they lock
+// a change.
+type InvoiceStore interface {
+	// SaveDraft stores inv and its lines as the draft invoice of the
+	// customer's month, replacing a previous draft, and returns its id. It
+	// returns ErrWrongStatus when that month's invoice is finalized.
+	SaveDraft(inv models.Invoice) (string, error)
+	// GetInvoice returns an invoice with its lines.
+	GetInvoice(invoiceID string) (*models.Invoice, error)
+	// ListInvoices returns invoices without their lines, latest month first.
+	// Empty filters match everything.
+	ListInvoices(userID string, year, month int) ([]models.Invoice, error)
+	// FinalizeInvoice freezes a draft. It returns ErrWrongStatus when the
+	// invoice is already finalized.
+	FinalizeInvoice(invoiceID, adminID string) error
+	// ReopenInvoice turns a finalized invoice back into a draft. It returns
+	// ErrWrongStatus when the invoice is a draft.
+	ReopenInvoice(invoiceID string) error
+	// InvoiceLocked reports whether a finalized invoice of the customer
+	// covers any day of [start, end]. A zero end leaves the range open.
+	InvoiceLocked(userID string, start, end civil.Date) (bool, error)
+	// PriceLocked reports whether a finalized invoice bills the product on a
+	// day of [start, end], for userID or for anyone when userID is empty. A
+	// zero end leaves the range open.
+	PriceLocked(productID, userID string, start, end civil.Date) (bool, error)
+}
+
+// PaymentStore keeps the money received from customers.
+type PaymentStore interface {
+	// CreatePayment stores p as given and returns its id.
+	CreatePayment(p models.Payment) (string, error)
+	GetPayment(paymentID string) (*models.Payment, error)
+	// ListPayments returns the payments of userID, or of everyone when it is
+	// empty, dated in [from, to], oldest first. Zero dates leave that side
+	// open.
+	ListPayments(userID string, from, to civil.Date) ([]models.Payment, error)
+	DeletePayment(paymentID string) error
+}
+
+// AdminStore looks up admin accounts for authentication. Creating and
+// editing accounts still goes through Server.DB.
+type AdminStore interface {
+	// AdminRole returns the admin's current role and whether the account is
+	// active, or ErrNotFound.
+	AdminRole(adminID string) (role string, active bool, err error)
+	// AdminCanAccess reports whether the apartment is assigned to the admin.
+	AdminCanAccess(adminID, apartmentID string) (bool, error)
+}
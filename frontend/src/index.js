@@ -4,6 +4,28 @@ import './index.css';
 import App from './App';
 //import reportWebVitals from './reportWebVitals';
 import { ChakraProvider } from "@chakra-ui/react";
+import axios from "axios";
+
+// Send the admin token with every API call
+axios.interceptors.request.use((config) => {
+  const token = localStorage.getItem("token");
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+  return config;
+});
+
+// Expired or invalid token: back to the login screen
+axios.interceptors.response.use(
+  (response) => response,
+  (error) => {
+    if (error.response && error.response.status === 401 && localStorage.getItem("token")) {
+      localStorage.removeItem("token");
+      window.location.assign("/");
+    }
+    return Promise.reject(error);
+  }
+);
 
 const root = ReactDOM.createRoot(document.getElementById('root'));
 root.render(
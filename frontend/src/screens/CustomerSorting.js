@@ -1,4 +1,4 @@
-import React, { useState, useEffect } from "react";
+import React, { useState, useEffect, useCallback } from "react";
 import axios from "axios";
 import Select from "react-select";
 import {
@@ -20,6 +20,7 @@ const CustomerSorting = () => {
     const [apartments, setApartments] = useState([]);
     const [selectedApartment, setSelectedApartment] = useState(null);
     const [customers, setCustomers] = useState([]);
+    const [routeVersion, setRouteVersion] = useState(null);
     const [isEditing, setIsEditing] = useState(false);
     const [draggedCustomer, setDraggedCustomer] = useState(null);
     const toast = useToast();
@@ -43,22 +44,24 @@ const CustomerSorting = () => {
         fetchApartments();
     }, [toast]);
 
-    // Fetch Customers Based on Selected Apartment
-    useEffect(() => {
+    // Fetch Customers Based on Selected Apartment, with the route version to save against
+    const fetchCustomers = useCallback(async () => {
         if (!selectedApartment) return;
-        const fetchCustomers = async () => {
-            try {
-                const response = await axios.get(
-                    `${CONFIG.API_BASE_URL}/apartcustomers?apartment_id=${selectedApartment.value}`
-                );
-                setCustomers(response.data);
-            } catch (error) {
-                toast({ title: "Error fetching customers", status: "error" });
-            }
-        };
-        fetchCustomers();
+        try {
+            const response = await axios.get(
+                `${CONFIG.API_BASE_URL}/route-order?apartment_id=${selectedApartment.value}`
+            );
+            setCustomers(response.data.customers || []);
+            setRouteVersion(response.data.version);
+        } catch (error) {
+            toast({ title: "Error fetching customers", status: "error" });
+        }
     }, [selectedApartment, toast]);
 
+    useEffect(() => {
+        fetchCustomers();
+    }, [fetchCustomers]);
+
     // Handle Drag Start
     const handleDragStart = (index) => {
         setDraggedCustomer(index);
@@ -95,17 +98,23 @@ const CustomerSorting = () => {
         }
 
         try {
-            await axios.put(`${CONFIG.API_BASE_URL}/update-priorities`, {
+            const response = await axios.put(`${CONFIG.API_BASE_URL}/update-priorities`, {
                 apartment_id: selectedApartment.value,
-                customers: customers.map((customer) => ({
-                    user_id: customer.user_id,
-                    priority_order: customer.priority_order,
-                })),
+                version: routeVersion,
+                user_ids: customers.map((customer) => customer.user_id),
             });
+            setRouteVersion(response.data.version);
 
             toast({ title: "Priorities updated successfully!", status: "success" });
             setIsEditing(false); // Exit edit mode after saving
         } catch (error) {
+            if (error.response?.status === 409) {
+                // Someone else changed the order meanwhile; show theirs
+                toast({ title: "The order was changed by someone else. Reloaded the latest order.", status: "warning" });
+                setIsEditing(false);
+                fetchCustomers();
+                return;
+            }
             toast({ title: "Error updating priorities", status: "error" });
         }
     };